@@ -0,0 +1,73 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"simple-file-server/lib/client"
+	"simple-file-server/lib/console"
+	"text/tabwriter"
+	"time"
+)
+
+var clientFlags struct {
+	Server string
+	Token  string
+	Output string
+}
+
+func envOr(key string, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// addClientFlags registers the flags shared by every command talking to a remote server
+func addClientFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&clientFlags.Server, "server", envOr("SFS_SERVER", "http://127.0.0.1:60088"), "server url, env SFS_SERVER")
+	cmd.Flags().StringVar(&clientFlags.Token, "token", os.Getenv("SFS_TOKEN"), "admin api token, env SFS_TOKEN")
+	cmd.Flags().StringVarP(&clientFlags.Output, "output", "o", "json", "output format, json or table")
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+}
+
+func newClient() (*client.Client, error) {
+	if clientFlags.Output != "json" && clientFlags.Output != "table" {
+		return nil, errors.New("output must be json or table")
+	}
+	if clientFlags.Token == "" {
+		return nil, outputError(errors.New("token is required, use --token or SFS_TOKEN"))
+	}
+	return client.New(clientFlags.Server, clientFlags.Token), nil
+}
+
+// output prints data as console JSON, or as a table rendered by table
+func output(data interface{}, table func(w io.Writer)) {
+	if clientFlags.Output == "table" {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		table(tw)
+		tw.Flush()
+		return
+	}
+	console.GenerateSuccessData(data)
+}
+
+func outputError(err error) error {
+	if clientFlags.Output != "table" {
+		console.GenerateError(err.Error())
+	}
+	return err
+}
+
+func formatTime(unix int64) string {
+	return time.Unix(unix, 0).Format("2006-01-02 15:04:05")
+}
+
+func printKeyValue(w io.Writer, pairs ...interface{}) {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		fmt.Fprintf(w, "%v\t%v\n", pairs[i], pairs[i+1])
+	}
+}
@@ -0,0 +1,48 @@
+package command
+
+import (
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+func init() {
+	addClientFlags(getCmd)
+	RootCmd.AddCommand(getCmd)
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get <remote-path> [local-file]",
+	Short: "download a file from the server, to stdout when local-file is omitted or -",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		if len(args) == 1 || args[1] == "-" {
+			if err := c.Get(args[0], os.Stdout); err != nil {
+				return outputError(err)
+			}
+			return nil
+		}
+		tmpPath := args[1] + ".sfs-download"
+		out, err := os.Create(tmpPath)
+		if err != nil {
+			return outputError(err)
+		}
+		err = c.Get(args[0], out)
+		out.Close()
+		if err == nil {
+			err = os.Rename(tmpPath, args[1])
+		}
+		if err != nil {
+			os.Remove(tmpPath)
+			return outputError(err)
+		}
+		output(map[string]any{"filePath": args[1]}, func(w io.Writer) {
+			printKeyValue(w, "downloaded", args[1])
+		})
+		return nil
+	},
+}
@@ -0,0 +1,31 @@
+package command
+
+import (
+	"github.com/spf13/cobra"
+	"io"
+)
+
+func init() {
+	addClientFlags(hasCmd)
+	RootCmd.AddCommand(hasCmd)
+}
+
+var hasCmd = &cobra.Command{
+	Use:   "has <remote-path>",
+	Short: "check whether a file exists on the server",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		exists, err := c.Has(args[0])
+		if err != nil {
+			return outputError(err)
+		}
+		output(exists, func(w io.Writer) {
+			printKeyValue(w, args[0], exists)
+		})
+		return nil
+	},
+}
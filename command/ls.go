@@ -0,0 +1,47 @@
+package command
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"io"
+	"simple-file-server/lib/common"
+)
+
+var lsRecursive bool
+
+func init() {
+	addClientFlags(lsCmd)
+	lsCmd.Flags().BoolVarP(&lsRecursive, "recursive", "r", false, "list recursively")
+	RootCmd.AddCommand(lsCmd)
+}
+
+var lsCmd = &cobra.Command{
+	Use:   "ls [remote-path]",
+	Short: "list files on the server",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		remotePath := ""
+		if len(args) > 0 {
+			remotePath = args[0]
+		}
+		items, err := c.List(remotePath, lsRecursive)
+		if err != nil {
+			return outputError(err)
+		}
+		output(items, func(w io.Writer) {
+			fmt.Fprintln(w, "TYPE\tSIZE\tMODIFIED\tPATH")
+			for _, item := range items {
+				kind, size := "file", common.FormatBytes(uint64(item.Size))
+				if item.IsDir {
+					kind, size = "dir", "-"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", kind, size, formatTime(item.Mtime), item.Path)
+			}
+		})
+		return nil
+	},
+}
@@ -0,0 +1,30 @@
+package command
+
+import (
+	"github.com/spf13/cobra"
+	"io"
+)
+
+func init() {
+	addClientFlags(mvCmd)
+	RootCmd.AddCommand(mvCmd)
+}
+
+var mvCmd = &cobra.Command{
+	Use:   "mv <from> <to>",
+	Short: "move a file on the server",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		if err := c.Move(args[0], args[1]); err != nil {
+			return outputError(err)
+		}
+		output(map[string]any{"from": args[0], "to": args[1]}, func(w io.Writer) {
+			printKeyValue(w, "moved", args[0]+" -> "+args[1])
+		})
+		return nil
+	},
+}
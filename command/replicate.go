@@ -0,0 +1,83 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"io"
+	"simple-file-server/global"
+	"simple-file-server/lib/client"
+	"simple-file-server/lib/common"
+	"simple-file-server/lib/config"
+)
+
+var replicateFlags struct {
+	Replica  string
+	Compare  string
+	NoDelete bool
+	DryRun   bool
+	Parallel int
+	Output   string
+}
+
+func init() {
+	replicateCmd.Flags().StringVar(&configPath, "config", envOr("SFS_CONFIG", config.DefaultPath), "config file, json, yaml or toml, env SFS_CONFIG")
+	replicateCmd.Flags().StringVar(&replicateFlags.Replica, "replica", "", "only reconcile the replica with this name")
+	replicateCmd.Flags().StringVar(&replicateFlags.Compare, "compare", syncCompareSizeMtime, "how to detect changed files, size-mtime or hash")
+	replicateCmd.Flags().BoolVar(&replicateFlags.NoDelete, "no-delete", false, "keep files that only exist on the replica")
+	replicateCmd.Flags().BoolVar(&replicateFlags.DryRun, "dry-run", false, "only print what would be done")
+	replicateCmd.Flags().IntVar(&replicateFlags.Parallel, "parallel", 4, "number of files transferred in parallel")
+	replicateCmd.Flags().StringVarP(&clientFlags.Output, "output", "o", "json", "output format, json or table")
+	replicateCmd.SilenceUsage = true
+	replicateCmd.SilenceErrors = true
+	RootCmd.AddCommand(replicateCmd)
+}
+
+var replicateCmd = &cobra.Command{
+	Use:   "replicate",
+	Short: "catch up replicas with a full scan of the data directory",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config.Init(configPath)
+		replicas := global.Config().Replication.Replicas
+		if len(replicas) == 0 {
+			return outputError(errors.New("no replicas configured"))
+		}
+		result := map[string][]SyncAction{}
+		failed := 0
+		for _, replica := range replicas {
+			if replicateFlags.Replica != "" && replica.Name != replicateFlags.Replica {
+				continue
+			}
+			actions, err := Sync(client.New(replica.Url, replica.Token), SyncOptions{
+				LocalDir:  global.Config().DataDir,
+				Direction: syncDirectionUpload,
+				Compare:   replicateFlags.Compare,
+				Delete:    !replicateFlags.NoDelete,
+				DryRun:    replicateFlags.DryRun,
+				Parallel:  replicateFlags.Parallel,
+			})
+			if err != nil {
+				return outputError(fmt.Errorf("%s: %w", replica.Name, err))
+			}
+			for _, action := range actions {
+				if action.Error != "" {
+					failed++
+				}
+			}
+			result[replica.Name] = actions
+		}
+		output(result, func(w io.Writer) {
+			fmt.Fprintln(w, "REPLICA\tACTION\tSIZE\tPATH\tERROR")
+			for name, actions := range result {
+				for _, action := range actions {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, action.Action, common.FormatBytes(uint64(action.Size)), action.Path, action.Error)
+				}
+			}
+		})
+		if failed > 0 {
+			return fmt.Errorf("%d actions failed", failed)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,33 @@
+package command
+
+import (
+	"github.com/spf13/cobra"
+	"io"
+)
+
+var rmRecursive bool
+
+func init() {
+	addClientFlags(rmCmd)
+	rmCmd.Flags().BoolVarP(&rmRecursive, "recursive", "r", false, "delete non-empty directories")
+	RootCmd.AddCommand(rmCmd)
+}
+
+var rmCmd = &cobra.Command{
+	Use:   "rm <remote-path>",
+	Short: "delete a file or directory on the server",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		if err := c.Delete(args[0], rmRecursive); err != nil {
+			return outputError(err)
+		}
+		output(map[string]any{"path": args[0]}, func(w io.Writer) {
+			printKeyValue(w, "deleted", args[0])
+		})
+		return nil
+	},
+}
@@ -2,15 +2,24 @@ package command
 
 import (
 	"github.com/spf13/cobra"
+	"simple-file-server/global"
+	"simple-file-server/lib/config"
+	"simple-file-server/lib/log"
 	"simple-file-server/server"
 )
 
-func init() {}
+var configPath string
+
+func init() {
+	RootCmd.Flags().StringVar(&configPath, "config", envOr("SFS_CONFIG", config.DefaultPath), "config file, json, yaml or toml, env SFS_CONFIG")
+}
 
 var RootCmd = &cobra.Command{
 	Use:   "simple-file-server",
 	Short: "simple file server , support upload ( multipart ), url download",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		config.Init(configPath)
+		log.Init(global.Config().Log)
 		server.Start()
 		return nil
 	},
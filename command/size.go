@@ -0,0 +1,32 @@
+package command
+
+import (
+	"github.com/spf13/cobra"
+	"io"
+	"simple-file-server/lib/common"
+)
+
+func init() {
+	addClientFlags(sizeCmd)
+	RootCmd.AddCommand(sizeCmd)
+}
+
+var sizeCmd = &cobra.Command{
+	Use:   "size <remote-path>",
+	Short: "get the size of a file on the server",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		size, err := c.Size(args[0])
+		if err != nil {
+			return outputError(err)
+		}
+		output(map[string]any{"size": size}, func(w io.Writer) {
+			printKeyValue(w, args[0], common.FormatBytes(uint64(size)))
+		})
+		return nil
+	},
+}
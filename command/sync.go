@@ -0,0 +1,339 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"simple-file-server/lib/client"
+	"simple-file-server/lib/common"
+	"simple-file-server/lib/files"
+	"simple-file-server/lib/throttle"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	syncDirectionUpload   = "upload"
+	syncDirectionDownload = "download"
+	syncCompareSizeMtime  = "size-mtime"
+	syncCompareHash       = "hash"
+)
+
+var syncFlags struct {
+	Direction string
+	Compare   string
+	Delete    bool
+	DryRun    bool
+	Include   []string
+	Exclude   []string
+	BwLimit   string
+	Parallel  int
+}
+
+func init() {
+	addClientFlags(syncCmd)
+	syncCmd.Flags().StringVar(&syncFlags.Direction, "direction", syncDirectionUpload, "upload (local to server) or download (server to local)")
+	syncCmd.Flags().StringVar(&syncFlags.Compare, "compare", syncCompareSizeMtime, "how to detect changed files, size-mtime or hash")
+	syncCmd.Flags().BoolVar(&syncFlags.Delete, "delete", false, "delete files missing from the source on the destination")
+	syncCmd.Flags().BoolVar(&syncFlags.DryRun, "dry-run", false, "only print what would be done")
+	syncCmd.Flags().StringArrayVar(&syncFlags.Include, "include", nil, "only sync paths matching this glob, repeatable")
+	syncCmd.Flags().StringArrayVar(&syncFlags.Exclude, "exclude", nil, "skip paths matching this glob, repeatable")
+	syncCmd.Flags().StringVar(&syncFlags.BwLimit, "bwlimit", "", "bandwidth limit per second for all transfers, e.g. 10M")
+	syncCmd.Flags().IntVar(&syncFlags.Parallel, "parallel", 4, "number of files transferred in parallel")
+	RootCmd.AddCommand(syncCmd)
+}
+
+type syncFile struct {
+	Size  int64
+	Mtime int64
+}
+
+type SyncAction struct {
+	Action string `json:"action"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Error  string `json:"error,omitempty"`
+}
+
+// SyncOptions describes one mirror run between a local directory and a remote prefix
+type SyncOptions struct {
+	LocalDir  string
+	Prefix    string
+	Direction string
+	Compare   string
+	Delete    bool
+	DryRun    bool
+	Include   []string
+	Exclude   []string
+	Parallel  int
+	Limiter   *throttle.Limiter
+}
+
+var syncCmd = &cobra.Command{
+	Use:   "sync <local-dir> <remote-prefix>",
+	Short: "mirror a local directory and a remote prefix",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		options := SyncOptions{
+			LocalDir:  args[0],
+			Prefix:    args[1],
+			Direction: syncFlags.Direction,
+			Compare:   syncFlags.Compare,
+			Delete:    syncFlags.Delete,
+			DryRun:    syncFlags.DryRun,
+			Include:   syncFlags.Include,
+			Exclude:   syncFlags.Exclude,
+			Parallel:  syncFlags.Parallel,
+		}
+		if syncFlags.BwLimit != "" {
+			limit, err := common.ParseBytes(syncFlags.BwLimit)
+			if err != nil {
+				return outputError(err)
+			}
+			if limit > 0 {
+				options.Limiter = throttle.NewLimiter(limit, 0)
+			}
+		}
+		actions, err := Sync(c, options)
+		if err != nil {
+			return outputError(err)
+		}
+		failed := 0
+		for _, action := range actions {
+			if action.Error != "" {
+				failed++
+			}
+		}
+		output(map[string]any{
+			"dryRun":  options.DryRun,
+			"actions": actions,
+			"failed":  failed,
+		}, func(w io.Writer) {
+			fmt.Fprintln(w, "ACTION\tSIZE\tPATH\tERROR")
+			for _, action := range actions {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", action.Action, common.FormatBytes(uint64(action.Size)), action.Path, action.Error)
+			}
+		})
+		if failed > 0 {
+			return fmt.Errorf("%d of %d actions failed", failed, len(actions))
+		}
+		return nil
+	},
+}
+
+// Sync compares both sides and uploads, downloads or deletes files until they match
+func Sync(c *client.Client, options SyncOptions) ([]SyncAction, error) {
+	if options.Direction != syncDirectionUpload && options.Direction != syncDirectionDownload {
+		return nil, errors.New("direction must be upload or download")
+	}
+	if options.Compare != syncCompareSizeMtime && options.Compare != syncCompareHash {
+		return nil, errors.New("compare must be size-mtime or hash")
+	}
+	options.Prefix = strings.Trim(options.Prefix, "/")
+	if options.Direction == syncDirectionDownload {
+		if err := os.MkdirAll(options.LocalDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	local, err := listLocal(options.LocalDir)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := listRemote(c, options.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	source, destination := local, remote
+	transfer, remove := "upload", "delete-remote"
+	if options.Direction == syncDirectionDownload {
+		source, destination = remote, local
+		transfer, remove = "download", "delete-local"
+	}
+	var actions []SyncAction
+	for rel, file := range source {
+		if !syncIncluded(options, rel) {
+			continue
+		}
+		if _, ok := destination[rel]; ok && !syncChanged(c, options, rel, local[rel], remote[rel]) {
+			continue
+		}
+		actions = append(actions, SyncAction{Action: transfer, Path: rel, Size: file.Size})
+	}
+	if options.Delete {
+		for rel, file := range destination {
+			if _, ok := source[rel]; !ok && syncIncluded(options, rel) {
+				actions = append(actions, SyncAction{Action: remove, Path: rel, Size: file.Size})
+			}
+		}
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].Path < actions[j].Path
+	})
+	if options.DryRun || len(actions) == 0 {
+		if actions == nil {
+			actions = []SyncAction{}
+		}
+		return actions, nil
+	}
+	parallel := options.Parallel
+	if parallel <= 0 {
+		parallel = 1
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := runSyncAction(c, options, actions[i], remote[actions[i].Path]); err != nil {
+					actions[i].Error = err.Error()
+				}
+			}
+		}()
+	}
+	for i := range actions {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return actions, nil
+}
+
+func runSyncAction(c *client.Client, options SyncOptions, action SyncAction, remote syncFile) error {
+	localPath := filepath.Join(options.LocalDir, filepath.FromSlash(action.Path))
+	remotePath := path.Join(options.Prefix, action.Path)
+	switch action.Action {
+	case "upload":
+		uploadOptions := client.DefaultUploadOptions()
+		uploadOptions.KeepMtime = true
+		uploadOptions.Wrap = func(r io.Reader) io.Reader {
+			return throttle.Reader(r, options.Limiter)
+		}
+		return c.UploadFile(localPath, remotePath, uploadOptions)
+	case "download":
+		if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+			return err
+		}
+		tmpPath := localPath + ".sfs-download"
+		out, err := os.Create(tmpPath)
+		if err != nil {
+			return err
+		}
+		err = c.Get(remotePath, throttle.Writer(out, options.Limiter))
+		out.Close()
+		if err == nil {
+			mtime := time.Unix(remote.Mtime, 0)
+			os.Chtimes(tmpPath, mtime, mtime)
+			err = os.Rename(tmpPath, localPath)
+		}
+		if err != nil {
+			os.Remove(tmpPath)
+		}
+		return err
+	case "delete-remote":
+		return c.Delete(remotePath, false)
+	case "delete-local":
+		if err := os.Remove(localPath); err != nil {
+			return err
+		}
+		files.PruneEmptyDirs(filepath.Dir(localPath), options.LocalDir)
+	}
+	return nil
+}
+
+func syncChanged(c *client.Client, options SyncOptions, rel string, local syncFile, remote syncFile) bool {
+	if local.Size != remote.Size {
+		return true
+	}
+	if options.Compare == syncCompareHash {
+		hashes, err := c.Hash(path.Join(options.Prefix, rel))
+		if err != nil {
+			return true
+		}
+		return hashes.Md5 != files.FileMd5(filepath.Join(options.LocalDir, filepath.FromSlash(rel)))
+	}
+	return local.Mtime != remote.Mtime
+}
+
+func syncIncluded(options SyncOptions, rel string) bool {
+	if len(options.Include) > 0 && !globMatchAny(options.Include, rel) {
+		return false
+	}
+	return !globMatchAny(options.Exclude, rel)
+}
+
+// globMatchAny matches rel against patterns, patterns without a slash also match the base name
+// and a trailing "/**" matches everything below a directory
+func globMatchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "/**") && strings.HasPrefix(rel, strings.TrimSuffix(pattern, "**")) {
+			return true
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func listLocal(root string) (map[string]syncFile, error) {
+	result := map[string]syncFile{}
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+	err := filepath.Walk(root, func(p string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || strings.HasSuffix(p, ".sfs-upload") || strings.HasSuffix(p, ".sfs-download") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		result[filepath.ToSlash(rel)] = syncFile{Size: info.Size(), Mtime: info.ModTime().Unix()}
+		return nil
+	})
+	return result, err
+}
+
+func listRemote(c *client.Client, prefix string) (map[string]syncFile, error) {
+	result := map[string]syncFile{}
+	items, err := c.List(prefix, true)
+	if err != nil {
+		if client.IsNotFound(err) {
+			// the prefix does not exist yet
+			return result, nil
+		}
+		return nil, err
+	}
+	for _, item := range items {
+		if item.IsDir {
+			continue
+		}
+		rel := item.Path
+		if prefix != "" {
+			rel = strings.TrimPrefix(rel, prefix+"/")
+		}
+		result[rel] = syncFile{Size: item.Size, Mtime: item.Mtime}
+	}
+	return result, nil
+}
@@ -0,0 +1,105 @@
+package command
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"simple-file-server/lib/client"
+	"testing"
+	"time"
+)
+
+func TestGlobMatchAny(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		rel      string
+		want     bool
+	}{
+		{[]string{"*.txt"}, "a.txt", true},
+		{[]string{"*.txt"}, "dir/sub/a.txt", true},
+		{[]string{"dir/*.txt"}, "dir/a.txt", true},
+		{[]string{"dir/*.txt"}, "dir/sub/a.txt", false},
+		{[]string{"dir/**"}, "dir/sub/a.txt", true},
+		{[]string{"dir/**"}, "dir2/a.txt", false},
+		{[]string{"*.jpg", "*.png"}, "a.png", true},
+		{[]string{"*.jpg"}, "a.txt", false},
+		{nil, "a.txt", false},
+	}
+	for _, tt := range tests {
+		if got := globMatchAny(tt.patterns, tt.rel); got != tt.want {
+			t.Errorf("globMatchAny(%q, %q) = %v, want %v", tt.patterns, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestSyncDryRun(t *testing.T) {
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	localDir := t.TempDir()
+	for name, content := range map[string]string{"new.txt": "new", "same.txt": "same", "changed.txt": "changed", "skip.log": "log"} {
+		fullPath := filepath.Join(localDir, name)
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(fullPath, mtime, mtime)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Path string `json:"path"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Path != "/_admin/list" {
+			t.Errorf("dry run called %s", r.URL.Path)
+		}
+		if req.Path != "backup" {
+			w.Write([]byte(`{"code": -1, "msg": "File not found"}`))
+			return
+		}
+		items := []client.ListItem{
+			{Path: "backup/same.txt", Size: 4, Mtime: mtime.Unix()},
+			{Path: "backup/changed.txt", Size: 3, Mtime: mtime.Unix()},
+			{Path: "backup/gone.txt", Size: 4, Mtime: mtime.Unix()},
+			{Path: "backup/dir", IsDir: true},
+		}
+		data, _ := json.Marshal(map[string]interface{}{"code": 0, "data": items})
+		w.Write(data)
+	}))
+	defer server.Close()
+	c := client.New(server.URL, "token")
+
+	options := SyncOptions{
+		LocalDir:  localDir,
+		Prefix:    "/backup/",
+		Direction: syncDirectionUpload,
+		Compare:   syncCompareSizeMtime,
+		Delete:    true,
+		DryRun:    true,
+		Exclude:   []string{"*.log"},
+	}
+	actions, err := Sync(c, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SyncAction{
+		{Action: "upload", Path: "changed.txt", Size: 7},
+		{Action: "delete-remote", Path: "gone.txt", Size: 4},
+		{Action: "upload", Path: "new.txt", Size: 3},
+	}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("actions = %+v, want %+v", actions, want)
+	}
+
+	// a prefix missing on the server is empty
+	options.Prefix = "other"
+	actions, err = Sync(c, options)
+	if err != nil || len(actions) != 3 {
+		t.Errorf("sync to a missing prefix = %+v, %v", actions, err)
+	}
+
+	options.Direction = "sideways"
+	if _, err := Sync(c, options); err == nil {
+		t.Error("an invalid direction returned no error")
+	}
+}
@@ -0,0 +1,61 @@
+package command
+
+import (
+	"github.com/spf13/cobra"
+	"io"
+	"simple-file-server/lib/client"
+)
+
+var uploadFlags struct {
+	MultipartThreshold int64
+	PartSize           int64
+	Parallel           int
+	Retries            int
+	NoResume           bool
+	ContentType        string
+}
+
+func init() {
+	defaults := client.DefaultUploadOptions()
+	addClientFlags(uploadCmd)
+	uploadCmd.Flags().Int64Var(&uploadFlags.MultipartThreshold, "multipart-threshold", defaults.MultipartThreshold, "use multipart upload for files larger than this many bytes")
+	uploadCmd.Flags().Int64Var(&uploadFlags.PartSize, "part-size", defaults.PartSize, "multipart part size in bytes")
+	uploadCmd.Flags().IntVar(&uploadFlags.Parallel, "parallel", defaults.Parallel, "number of parts uploaded in parallel")
+	uploadCmd.Flags().IntVar(&uploadFlags.Retries, "retries", defaults.Retries, "attempts per request")
+	uploadCmd.Flags().BoolVar(&uploadFlags.NoResume, "no-resume", false, "do not resume an interrupted multipart upload")
+	uploadCmd.Flags().StringVar(&uploadFlags.ContentType, "content-type", "", "content type, detected from the extension by default")
+	RootCmd.AddCommand(uploadCmd)
+}
+
+func uploadOptions(localPath string) client.UploadOptions {
+	options := client.UploadOptions{
+		MultipartThreshold: uploadFlags.MultipartThreshold,
+		PartSize:           uploadFlags.PartSize,
+		Parallel:           uploadFlags.Parallel,
+		Retries:            uploadFlags.Retries,
+		ContentType:        uploadFlags.ContentType,
+	}
+	if !uploadFlags.NoResume {
+		options.StateFile = localPath + ".sfs-upload"
+	}
+	return options
+}
+
+var uploadCmd = &cobra.Command{
+	Use:   "upload <local-file> <remote-path>",
+	Short: "upload a file to the server",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		if err := c.UploadFile(args[0], args[1], uploadOptions(args[0])); err != nil {
+			return outputError(err)
+		}
+		output(map[string]any{"filePath": args[1]}, func(w io.Writer) {
+			printKeyValue(w, "uploaded", args[1])
+		})
+		return nil
+	},
+}
@@ -3,11 +3,32 @@ package global
 import (
 	"github.com/robfig/cron/v3"
 	"simple-file-server/lib/defs"
+	"sync"
+	"sync/atomic"
 )
 
 var (
-	CONFIG defs.Config
+	config atomic.Pointer[defs.Config]
 	CRON   *cron.Cron
 
-	CronIDMonitor cron.EntryID
+	// CronIDLock guards the job ids below, which a reload replaces while the health check reads them
+	CronIDLock        sync.RWMutex
+	CronIDMonitor     cron.EntryID
+	CronIDScrubber    cron.EntryID
+	CronIDReplication cron.EntryID
+	CronIDWebhook     cron.EntryID
 )
+
+// Config returns the current config. A reload replaces it as a whole and never modifies it, so code that needs
+// several settings to agree keeps the returned snapshot instead of calling Config again.
+func Config() *defs.Config {
+	if c := config.Load(); c != nil {
+		return c
+	}
+	return &defs.Config{}
+}
+
+// SetConfig publishes c to the requests started from now on
+func SetConfig(c defs.Config) {
+	config.Store(&c)
+}
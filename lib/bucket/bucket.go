@@ -0,0 +1,136 @@
+package bucket
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"simple-file-server/global"
+	"simple-file-server/lib/defs"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// buckets caches the definitions of Dir, every change goes through Save or Delete
+var buckets = struct {
+	sync.Mutex
+	dir string
+	m   map[string]defs.Bucket
+}{}
+
+// Dir holds one json file per bucket
+func Dir() string {
+	return filepath.Join(global.Config().TempDir, "Buckets")
+}
+
+// load reads Dir on first use and again when tempDir changed on reload, the caller holds the lock
+func load() map[string]defs.Bucket {
+	dir := Dir()
+	if buckets.m != nil && buckets.dir == dir {
+		return buckets.m
+	}
+	buckets.dir = dir
+	buckets.m = map[string]defs.Bucket{}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var b defs.Bucket
+		if json.Unmarshal(data, &b) == nil && b.Name != "" {
+			buckets.m[b.Name] = b
+		}
+	}
+	return buckets.m
+}
+
+// List returns every bucket sorted by name
+func List() []defs.Bucket {
+	buckets.Lock()
+	defer buckets.Unlock()
+	list := []defs.Bucket{}
+	for _, b := range load() {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func Get(name string) (defs.Bucket, bool) {
+	buckets.Lock()
+	defer buckets.Unlock()
+	b, ok := load()[name]
+	return b, ok
+}
+
+// Save creates or replaces the definition of b
+func Save(b defs.Bucket) error {
+	buckets.Lock()
+	defer buckets.Unlock()
+	m := load()
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(b, "", "  ")
+	file := filepath.Join(Dir(), b.Name+".json")
+	tmpFile := file + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile, file); err != nil {
+		return err
+	}
+	m[b.Name] = b
+	return nil
+}
+
+// Delete removes the definition of a bucket, its files stay in DataDir
+func Delete(name string) error {
+	buckets.Lock()
+	defer buckets.Unlock()
+	m := load()
+	if err := os.Remove(filepath.Join(Dir(), name+".json")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(m, name)
+	return nil
+}
+
+// ByHost returns the bucket served at the root of host
+func ByHost(host string) (defs.Bucket, bool) {
+	buckets.Lock()
+	defer buckets.Unlock()
+	for _, b := range load() {
+		if b.Host != "" && strings.EqualFold(b.Host, host) {
+			return b, true
+		}
+	}
+	return defs.Bucket{}, false
+}
+
+// ByToken returns the bucket and the name of the bucket token token
+func ByToken(token string) (defs.Bucket, string, bool) {
+	buckets.Lock()
+	defer buckets.Unlock()
+	for _, b := range load() {
+		for _, t := range b.Tokens {
+			if t.Token == token {
+				return b, t.Name, true
+			}
+		}
+	}
+	return defs.Bucket{}, "", false
+}
+
+// ForPath returns the bucket rel, a slash separated path relative to DataDir, is in
+func ForPath(rel string) (defs.Bucket, bool) {
+	name, _, _ := strings.Cut(strings.TrimPrefix(rel, "/"), "/")
+	if name == "" {
+		return defs.Bucket{}, false
+	}
+	return Get(name)
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"simple-file-server/lib/defs"
+	"simple-file-server/lib/files"
+)
+
+type ArchiveOptions struct {
+	Paths []string `json:"paths,omitempty"`
+	// Prefix adds every file whose path starts with it, like "images/2024-"
+	Prefix string `json:"prefix,omitempty"`
+	// Format is files.ArchiveFormatZip (default) or files.ArchiveFormatTarGz
+	Format string `json:"format,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// Archive streams the paths and prefix of options into w as one archive
+func (c *Client) Archive(options ArchiveOptions, w io.Writer) error {
+	body, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest("POST", "/_admin/archive", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.stream(req, w)
+}
+
+// Extract uploads the archive r and extracts it below target, format "" is taken from fileName.
+// Entries extracted before a failure are returned with the error.
+func (c *Client) Extract(target string, fileName string, r io.Reader, format string, overwrite bool) ([]files.ExtractResult, error) {
+	fields := map[string]string{"target": target}
+	if format != "" {
+		fields["format"] = format
+	}
+	if overwrite {
+		fields["overwrite"] = "true"
+	}
+	var data struct {
+		Entries []files.ExtractResult `json:"entries"`
+	}
+	err := c.PostFile("/_admin/extract", fields, fileName, r, "", &data)
+	if clientErr, ok := err.(*Error); ok && clientErr.Code == defs.CodeExtractFailed {
+		json.Unmarshal(clientErr.Data, &data)
+	}
+	return data.Entries, err
+}
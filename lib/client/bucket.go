@@ -0,0 +1,53 @@
+package client
+
+import (
+	"simple-file-server/lib/defs"
+)
+
+type BucketToken struct {
+	Name   string            `json:"name"`
+	Upload defs.UploadLimits `json:"upload"`
+}
+
+type BucketWebhook struct {
+	Url        string   `json:"url"`
+	Events     []string `json:"events"`
+	MaxRetries int      `json:"maxRetries"`
+}
+
+// BucketInfo is a bucket as the server returns it, without token values and the webhook secret
+type BucketInfo struct {
+	Name        string            `json:"name"`
+	Host        string            `json:"host"`
+	Tokens      []BucketToken     `json:"tokens"`
+	Quota       int64             `json:"quota"`
+	Usage       int64             `json:"usage"`
+	AccessRules []defs.AccessRule `json:"accessRules"`
+	Webhook     BucketWebhook     `json:"webhook"`
+	CreatedAt   int64             `json:"createdAt"`
+}
+
+// BucketCreate creates bucket, or updates the definition of an existing bucket with the same name
+func (c *Client) BucketCreate(bucket defs.Bucket) (BucketInfo, error) {
+	var info BucketInfo
+	err := c.Call("/_admin/bucket/create", bucket, &info)
+	return info, err
+}
+
+func (c *Client) BucketList() ([]BucketInfo, error) {
+	var list []BucketInfo
+	req, err := c.newRequest("GET", "/_admin/bucket/list", nil)
+	if err != nil {
+		return nil, err
+	}
+	err = c.do(req, &list)
+	return list, err
+}
+
+// BucketDelete removes the bucket, its directory is kept as a plain directory unless deleteData is set
+func (c *Client) BucketDelete(name string, deleteData bool) error {
+	return c.Call("/_admin/bucket/delete", map[string]interface{}{
+		"name":       name,
+		"deleteData": deleteData,
+	}, nil)
+}
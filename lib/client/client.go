@@ -0,0 +1,452 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"simple-file-server/lib/defs"
+	"strings"
+	"time"
+)
+
+// Error is returned when the server answers with a non zero code
+type Error struct {
+	Code int
+	// Name is the stable name of Code like "NOT_FOUND", empty for servers before the error catalog
+	Name      string
+	Msg       string
+	Status    int
+	RequestId string
+	// Data is the data of the response, like the entries of a failed extract
+	Data json.RawMessage
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// IsNotFound reports whether err is the server saying the path does not exist
+func IsNotFound(err error) bool {
+	var clientErr *Error
+	if !errors.As(err, &clientErr) {
+		return false
+	}
+	if clientErr.Code == defs.CodeNotFound {
+		return true
+	}
+	// older servers answer -1 with the message only
+	return clientErr.Code == -1 && (clientErr.Msg == "File not found" || clientErr.Msg == "Source file not found")
+}
+
+type Client struct {
+	Server string
+	Token  string
+	// Ticket, when set, is sent as the upload-ticket header instead of Token, see UploadTicket
+	Ticket     string
+	HttpClient *http.Client
+}
+
+func New(server string, token string) *Client {
+	return &Client{
+		Server:     strings.TrimRight(server, "/"),
+		Token:      token,
+		HttpClient: &http.Client{},
+	}
+}
+
+type ListItem struct {
+	Path  string `json:"path"`
+	Name  string `json:"name"`
+	IsDir bool   `json:"isDir"`
+	Size  int64  `json:"size"`
+	Mtime int64  `json:"mtime"`
+}
+
+type MultipartPart struct {
+	PartNumber int   `json:"partNumber"`
+	Size       int64 `json:"size"`
+}
+
+type MultipartStatus struct {
+	UploadID   string          `json:"uploadId"`
+	FilePath   string          `json:"filePath"`
+	TotalParts int             `json:"totalParts"`
+	TotalSize  int64           `json:"totalSize"`
+	Parts      []MultipartPart `json:"parts"`
+}
+
+func (c *Client) newRequest(method string, endpoint string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, c.Server+endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.Ticket != "" {
+		req.Header.Set("upload-ticket", c.Ticket)
+	} else {
+		req.Header.Set("admin-api-token", c.Token)
+	}
+	req.Header.Set("User-Agent", "simple-file-server")
+	return req, nil
+}
+
+func (c *Client) do(req *http.Request, data interface{}) error {
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(resp, body, data)
+}
+
+// decodeResponse unwraps the {code, msg, data} envelope of body into data
+func decodeResponse(resp *http.Response, body []byte, data interface{}) error {
+	var res struct {
+		Code      int             `json:"code"`
+		Error     string          `json:"error"`
+		Msg       string          `json:"msg"`
+		Data      json.RawMessage `json:"data"`
+		RequestId string          `json:"requestId"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return fmt.Errorf("http status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if res.Code != 0 {
+		return &Error{Code: res.Code, Name: res.Error, Msg: res.Msg, Status: resp.StatusCode, RequestId: res.RequestId, Data: res.Data}
+	}
+	if data != nil && len(res.Data) > 0 {
+		return json.Unmarshal(res.Data, data)
+	}
+	return nil
+}
+
+// Call posts body as JSON to endpoint and decodes the response data into data
+func (c *Client) Call(endpoint string, body interface{}, data interface{}) error {
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest("POST", endpoint, bytes.NewReader(requestBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, data)
+}
+
+// PostFile posts fields and the content of file as a multipart form, streaming the body
+func (c *Client) PostFile(endpoint string, fields map[string]string, fileName string, file io.Reader, contentType string, data interface{}) error {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		for k, v := range fields {
+			if err := mw.WriteField(k, v); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		header := make(map[string][]string)
+		header["Content-Disposition"] = []string{fmt.Sprintf(`form-data; name="file"; filename=%q`, fileName)}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header["Content-Type"] = []string{contentType}
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(part, file); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(mw.Close())
+	}()
+	req, err := c.newRequest("POST", endpoint, pr)
+	if err != nil {
+		pr.Close()
+		return err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	err = c.do(req, data)
+	pr.Close()
+	return err
+}
+
+func (c *Client) Ping() error {
+	req, err := c.newRequest("GET", "/_admin/ping", nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, nil)
+}
+
+// Upload uploads r in a single request, mtime in unix seconds is kept by the server when non zero
+func (c *Client) Upload(remotePath string, r io.Reader, contentType string, mtime int64) error {
+	fields := map[string]string{
+		"filePath": remotePath,
+	}
+	if mtime > 0 {
+		fields["mtime"] = fmt.Sprint(mtime)
+	}
+	return c.PostFile("/_admin/upload", fields, pathBase(remotePath), r, contentType, nil)
+}
+
+func (c *Client) MultipartInit(remotePath string, totalParts int, totalSize int64, contentType string, mtime int64) (string, error) {
+	var data struct {
+		UploadID string `json:"uploadId"`
+	}
+	err := c.Call("/_admin/upload/multipart_init", map[string]interface{}{
+		"filePath":    remotePath,
+		"totalParts":  totalParts,
+		"totalSize":   totalSize,
+		"contentType": contentType,
+		"mtime":       mtime,
+	}, &data)
+	return data.UploadID, err
+}
+
+func (c *Client) MultipartUpload(uploadID string, partNumber int, r io.Reader) error {
+	return c.PostFile("/_admin/upload/multipart_upload", map[string]string{
+		"uploadId":   uploadID,
+		"partNumber": fmt.Sprint(partNumber),
+	}, fmt.Sprintf("part%d", partNumber), r, "", nil)
+}
+
+func (c *Client) MultipartStatus(uploadID string) (MultipartStatus, error) {
+	var status MultipartStatus
+	err := c.Call("/_admin/upload/multipart_status", map[string]string{"uploadId": uploadID}, &status)
+	return status, err
+}
+
+func (c *Client) MultipartEnd(uploadID string) error {
+	return c.Call("/_admin/upload/multipart_end", map[string]string{"uploadId": uploadID}, nil)
+}
+
+func (c *Client) MultipartAbort(uploadID string) error {
+	return c.Call("/_admin/upload/abort", map[string]string{"uploadId": uploadID}, nil)
+}
+
+// stream copies the body of a successful response to w, errors are decoded like the ones of do
+func (c *Client) stream(req *http.Request, w io.Writer) error {
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err, ok := decodeResponse(resp, body, nil).(*Error); ok {
+			return err
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			// not an envelope, e.g. the empty 404 of an old server or of a public file
+			return &Error{Code: defs.CodeNotFound, Name: "NOT_FOUND", Msg: "File not found", Status: resp.StatusCode, RequestId: resp.Header.Get("X-Request-Id")}
+		}
+		return fmt.Errorf("http status %d", resp.StatusCode)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Get streams the content of remotePath into w
+func (c *Client) Get(remotePath string, w io.Writer) error {
+	req, err := c.newRequest("GET", "/_admin/get?path="+url.QueryEscape(remotePath), nil)
+	if err != nil {
+		return err
+	}
+	return c.stream(req, w)
+}
+
+// GetPublic streams a file as served to browsers, urlPath may carry a query like the url returned by Sign
+func (c *Client) GetPublic(urlPath string, w io.Writer) error {
+	req, err := http.NewRequest("GET", c.Server+"/"+strings.TrimLeft(urlPath, "/"), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "simple-file-server")
+	return c.stream(req, w)
+}
+
+// GetZip streams the directory remotePath into w as a zip archive
+func (c *Client) GetZip(remotePath string, w io.Writer) error {
+	req, err := c.newRequest("GET", "/_admin/get?zip=true&path="+url.QueryEscape(remotePath), nil)
+	if err != nil {
+		return err
+	}
+	return c.stream(req, w)
+}
+
+func (c *Client) Has(remotePath string) (bool, error) {
+	var exists bool
+	err := c.Call("/_admin/has", map[string]string{"path": remotePath}, &exists)
+	return exists, err
+}
+
+func (c *Client) Size(remotePath string) (int64, error) {
+	var data struct {
+		Size int64 `json:"size"`
+	}
+	err := c.Call("/_admin/size", map[string]string{"path": remotePath}, &data)
+	return data.Size, err
+}
+
+type Hashes struct {
+	Path    string `json:"path"`
+	Md5     string `json:"md5"`
+	Sha1    string `json:"sha1"`
+	Sha256  string `json:"sha256"`
+	Crc32c  string `json:"crc32c"`
+	Size    int64  `json:"size"`
+	Cached  bool   `json:"cached"`
+	Corrupt bool   `json:"corrupt"`
+}
+
+func (c *Client) Hash(remotePath string) (Hashes, error) {
+	var hashes Hashes
+	err := c.Call("/_admin/hash", map[string]string{"path": remotePath}, &hashes)
+	return hashes, err
+}
+
+// Rehash reads the file again instead of using the cached hashes, a changed content is reported as Corrupt
+func (c *Client) Rehash(remotePath string) (Hashes, error) {
+	var hashes Hashes
+	err := c.Call("/_admin/hash", map[string]interface{}{"path": remotePath, "refresh": true}, &hashes)
+	return hashes, err
+}
+
+func (c *Client) List(remotePath string, recursive bool) ([]ListItem, error) {
+	var items []ListItem
+	err := c.Call("/_admin/list", map[string]interface{}{
+		"path":      remotePath,
+		"recursive": recursive,
+	}, &items)
+	return items, err
+}
+
+func (c *Client) Delete(remotePath string, recursive bool) error {
+	return c.Call("/_admin/delete", map[string]interface{}{
+		"path":      remotePath,
+		"recursive": recursive,
+	}, nil)
+}
+
+// DeleteDryRun returns the paths a recursive delete of remotePath would remove
+func (c *Client) DeleteDryRun(remotePath string) ([]string, error) {
+	var data struct {
+		Paths []string `json:"paths"`
+	}
+	err := c.Call("/_admin/delete", map[string]interface{}{
+		"path":      remotePath,
+		"recursive": true,
+		"dryRun":    true,
+	}, &data)
+	return data.Paths, err
+}
+
+func (c *Client) Move(from string, to string) error {
+	return c.Call("/_admin/move", map[string]string{
+		"from": from,
+		"to":   to,
+	}, nil)
+}
+
+func (c *Client) Mkdir(remotePath string) error {
+	return c.Call("/_admin/mkdir", map[string]string{"path": remotePath}, nil)
+}
+
+// Ticket is a one-time upload ticket, see UploadTicket
+type Ticket struct {
+	Ticket    string `json:"ticket"`
+	Prefix    string `json:"prefix"`
+	MaxSize   int64  `json:"maxSize"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// UploadTicket mints a one-time ticket browsers can upload below prefix with, ttl is in seconds
+func (c *Client) UploadTicket(prefix string, maxSize int64, ttl int64) (Ticket, error) {
+	var ticket Ticket
+	err := c.Call("/_admin/upload/ticket", map[string]interface{}{"prefix": prefix, "maxSize": maxSize, "ttl": ttl}, &ticket)
+	return ticket, err
+}
+
+type PolicyOptions struct {
+	KeyPrefix   string `json:"keyPrefix"`
+	MinSize     int64  `json:"minSize,omitempty"`
+	MaxSize     int64  `json:"maxSize,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	// Redirect is where the browser is sent after the upload, with the key appended
+	Redirect      string `json:"redirect,omitempty"`
+	SuccessStatus int    `json:"successStatus,omitempty"`
+	Ttl           int64  `json:"ttl,omitempty"`
+}
+
+type Policy struct {
+	Policy     string `json:"policy"`
+	Signature  string `json:"signature"`
+	Expiration int64  `json:"expiration"`
+}
+
+// UploadPolicy signs a policy HTML forms can upload to /_upload with, see PostUpload
+func (c *Client) UploadPolicy(options PolicyOptions) (Policy, error) {
+	var policy Policy
+	err := c.Call("/_admin/upload/policy", options, &policy)
+	return policy, err
+}
+
+// PostUpload uploads like an HTML form with a signed policy, it returns the key the file is saved as
+func (c *Client) PostUpload(key string, policy Policy, fileName string, r io.Reader, contentType string) (string, error) {
+	var data struct {
+		Key string `json:"key"`
+	}
+	fields := map[string]string{
+		"key":       key,
+		"policy":    policy.Policy,
+		"signature": policy.Signature,
+	}
+	if contentType != "" {
+		fields["Content-Type"] = contentType
+	}
+	err := c.PostFile("/_upload", fields, fileName, r, contentType, &data)
+	return data.Key, err
+}
+
+// Sign returns the url path with query of a private file, valid for ttl seconds
+func (c *Client) Sign(remotePath string, ttl int64) (string, error) {
+	var data struct {
+		Url string `json:"url"`
+	}
+	err := c.Call("/_admin/sign", map[string]interface{}{"path": remotePath, "ttl": ttl}, &data)
+	return data.Url, err
+}
+
+// retry runs fn up to attempts times with a growing delay between attempts
+func retry(attempts int, fn func() error) error {
+	var err error
+	for i := 0; i < attempts; i++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		// the server rejected the request, only overload and internal errors are worth another attempt
+		if clientErr, ok := err.(*Error); ok && clientErr.Status < http.StatusInternalServerError && clientErr.Code != defs.CodeTooManyRequests {
+			return err
+		}
+		time.Sleep(time.Duration(i+1) * time.Second)
+	}
+	return err
+}
+
+func pathBase(p string) string {
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[i+1:]
+	}
+	return p
+}
@@ -0,0 +1,250 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"simple-file-server/lib/defs"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("admin-api-token") != "token" {
+			t.Errorf("%s was called without the token", r.URL.Path)
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte(`{"code": 0, "msg": "ok", "data": {"echo": "` + body["value"] + `"}}`))
+		case "/fail":
+			w.WriteHeader(404)
+			w.Write([]byte(`{"code": 1015, "msg": "File not found", "data": {}}`))
+		default:
+			w.WriteHeader(502)
+			w.Write([]byte("bad gateway"))
+		}
+	}))
+	defer server.Close()
+	c := New(server.URL+"/", "token")
+
+	var data struct {
+		Echo string `json:"echo"`
+	}
+	if err := c.Call("/ok", map[string]string{"value": "hello"}, &data); err != nil || data.Echo != "hello" {
+		t.Errorf("Call(/ok) = %v, %+v", err, data)
+	}
+
+	err := c.Call("/fail", nil, nil)
+	var clientErr *Error
+	if !errors.As(err, &clientErr) || clientErr.Code != 1015 || clientErr.Msg != "File not found" {
+		t.Errorf("Call(/fail) = %#v, want an *Error with code 1015", err)
+	}
+
+	if err := c.Call("/html", nil, nil); err == nil || errors.As(err, &clientErr) {
+		t.Errorf("Call(/html) = %v, want a plain error", err)
+	}
+}
+
+// fakeUploadServer keeps the files and parts uploaded through the admin upload endpoints
+type fakeUploadServer struct {
+	sync.Mutex
+	files    map[string]string
+	parts    map[int]string
+	filePath string
+	inits    int
+}
+
+func (s *fakeUploadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	var body map[string]interface{}
+	if r.Header.Get("Content-Type") == "application/json" {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	switch r.URL.Path {
+	case "/_admin/upload":
+		file, _, _ := r.FormFile("file")
+		content, _ := io.ReadAll(file)
+		s.files[r.FormValue("filePath")] = string(content)
+	case "/_admin/upload/multipart_init":
+		s.inits++
+		s.filePath = body["filePath"].(string)
+		s.parts = map[int]string{}
+		w.Write([]byte(`{"code": 0, "data": {"uploadId": "u1"}}`))
+		return
+	case "/_admin/upload/multipart_upload":
+		file, _, _ := r.FormFile("file")
+		content, _ := io.ReadAll(file)
+		partNumber, _ := strconv.Atoi(r.FormValue("partNumber"))
+		s.parts[partNumber] = string(content)
+	case "/_admin/upload/multipart_status":
+		status := MultipartStatus{UploadID: "u1", FilePath: s.filePath}
+		for partNumber, content := range s.parts {
+			status.Parts = append(status.Parts, MultipartPart{PartNumber: partNumber, Size: int64(len(content))})
+		}
+		data, _ := json.Marshal(map[string]interface{}{"code": 0, "data": status})
+		w.Write(data)
+		return
+	case "/_admin/upload/multipart_end":
+		numbers := []int{}
+		for partNumber := range s.parts {
+			numbers = append(numbers, partNumber)
+		}
+		sort.Ints(numbers)
+		content := ""
+		for _, partNumber := range numbers {
+			content += s.parts[partNumber]
+		}
+		s.files[s.filePath] = content
+	}
+	w.Write([]byte(`{"code": 0, "data": {}}`))
+}
+
+func TestUploadFile(t *testing.T) {
+	fake := &fakeUploadServer{files: map[string]string{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	c := New(server.URL, "token")
+	localPath := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(localPath, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.UploadFile(localPath, "small/a.txt", DefaultUploadOptions()); err != nil {
+		t.Fatal(err)
+	}
+	options := UploadOptions{MultipartThreshold: 4, PartSize: 4, Parallel: 2, Retries: 1}
+	if err := c.UploadFile(localPath, "large/a.txt", options); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"small/a.txt": "0123456789", "large/a.txt": "0123456789"}
+	if !reflect.DeepEqual(fake.files, want) {
+		t.Errorf("uploaded %q, want %q", fake.files, want)
+	}
+	if got := []string{fake.parts[1], fake.parts[2], fake.parts[3]}; !reflect.DeepEqual(got, []string{"0123", "4567", "89"}) {
+		t.Errorf("parts are %q", got)
+	}
+}
+
+func TestUploadFileResume(t *testing.T) {
+	// part 1 is complete and kept, part 3 is short and sent again
+	fake := &fakeUploadServer{files: map[string]string{}, filePath: "a.txt", parts: map[int]string{1: "kept", 3: "8"}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	c := New(server.URL, "token")
+	localPath := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(localPath, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(localPath)
+	options := UploadOptions{MultipartThreshold: 4, PartSize: 4, Retries: 1, StateFile: filepath.Join(t.TempDir(), "state.json")}
+	saveUploadState(options.StateFile, uploadState{UploadID: "u1", RemotePath: "a.txt", Size: 10, Mtime: info.ModTime().UnixNano(), PartSize: 4})
+
+	if err := c.UploadFile(localPath, "a.txt", options); err != nil {
+		t.Fatal(err)
+	}
+	if fake.inits != 0 || fake.files["a.txt"] != "kept456789" {
+		t.Errorf("resumed upload made %d inits and stored %q", fake.inits, fake.files["a.txt"])
+	}
+	if _, err := os.Stat(options.StateFile); !os.IsNotExist(err) {
+		t.Error("the state file was kept after the upload")
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&Error{Code: defs.CodeNotFound, Name: "NOT_FOUND", Msg: "Directory not found"}, true},
+		// servers before the error catalog answer -1 with the message only
+		{&Error{Code: -1, Msg: "File not found"}, true},
+		{&Error{Code: -1, Msg: "Invalid request"}, false},
+		{&Error{Code: defs.CodeInvalidRequest, Msg: "File not found"}, false},
+		{fmt.Errorf("list: %w", &Error{Code: defs.CodeNotFound}), true},
+		{errors.New("File not found"), false},
+	}
+	for _, tt := range tests {
+		if got := IsNotFound(tt.err); got != tt.want {
+			t.Errorf("IsNotFound(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestServerReports(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_admin/scrub":
+			w.Write([]byte(`{"code": 0, "msg": "ok", "data": {"checked": 3, "corrupt": ["a.txt"]}}`))
+		case "/_admin/replication":
+			w.Write([]byte(`{"code": 0, "msg": "ok", "data": {"r1": {"queued": 2, "failed": 1}}}`))
+		case "/_admin/openapi.json":
+			w.Write([]byte(`{"openapi": "3.0.3"}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+	c := New(server.URL, "token")
+
+	if report, err := c.ScrubReport(); err != nil || report.Checked != 3 || !reflect.DeepEqual(report.Corrupt, []string{"a.txt"}) {
+		t.Errorf("ScrubReport = %+v, %v", report, err)
+	}
+	if backlog, err := c.Replication(); err != nil || backlog["r1"] != (Backlog{Queued: 2, Failed: 1}) {
+		t.Errorf("Replication = %+v, %v", backlog, err)
+	}
+	if doc, err := c.OpenApi(); err != nil || string(doc) != `{"openapi": "3.0.3"}` {
+		t.Errorf("OpenApi = %s, %v", doc, err)
+	}
+
+	c = New(server.URL+"/missing", "token")
+	if _, err := c.OpenApi(); err == nil {
+		t.Error("OpenApi of a missing document returned no error")
+	}
+}
+
+func TestHealthAndReady(t *testing.T) {
+	ready := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/_admin/health":
+			w.WriteHeader(503)
+			w.Write([]byte(`{"code": 1027, "msg": "unhealthy", "data": {"status": "fail", "checks": {"dataDir": {"status": "fail"}}}}`))
+		case r.URL.Path == "/_admin/ready" && ready:
+			w.Write([]byte(`{"code": 0, "msg": "ok", "data": {"ready": true, "failed": []}}`))
+		case r.URL.Path == "/_admin/ready":
+			w.WriteHeader(503)
+			w.Write([]byte(`{"code": 1027, "msg": "not ready", "data": {"ready": false, "failed": ["dataDir"]}}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+	c := New(server.URL, "token")
+
+	report, err := c.Health()
+	if clientErr, ok := err.(*Error); !ok || clientErr.Code != defs.CodeNotReady {
+		t.Fatalf("Health error = %v", err)
+	}
+	if report.Status != defs.HealthFail || report.Checks.DataDir.Status != defs.HealthFail {
+		t.Errorf("Health report = %+v", report)
+	}
+	if ok, failed, err := c.Ready(); err != nil || !ok || len(failed) != 0 {
+		t.Errorf("Ready = %v %v %v", ok, failed, err)
+	}
+	ready = false
+	if ok, failed, err := c.Ready(); err != nil || ok || !reflect.DeepEqual(failed, []string{"dataDir"}) {
+		t.Errorf("not Ready = %v %v %v", ok, failed, err)
+	}
+}
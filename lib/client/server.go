@@ -0,0 +1,82 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"simple-file-server/lib/defs"
+)
+
+type ScrubReport struct {
+	StartedAt  int64    `json:"startedAt"`
+	FinishedAt int64    `json:"finishedAt"`
+	Checked    int      `json:"checked"`
+	Failed     int      `json:"failed"`
+	Corrupt    []string `json:"corrupt"`
+}
+
+type Backlog struct {
+	Queued int `json:"queued"`
+	Failed int `json:"failed"`
+}
+
+// ScrubReport returns the result of the last integrity scrub
+func (c *Client) ScrubReport() (ScrubReport, error) {
+	var report ScrubReport
+	req, err := c.newRequest("GET", "/_admin/scrub", nil)
+	if err != nil {
+		return report, err
+	}
+	err = c.do(req, &report)
+	return report, err
+}
+
+// Replication returns the backlog of every replica by name
+func (c *Client) Replication() (map[string]Backlog, error) {
+	var backlog map[string]Backlog
+	req, err := c.newRequest("GET", "/_admin/replication", nil)
+	if err != nil {
+		return nil, err
+	}
+	err = c.do(req, &backlog)
+	return backlog, err
+}
+
+// OpenApi returns the OpenAPI document of the server as JSON
+func (c *Client) OpenApi() ([]byte, error) {
+	req, err := c.newRequest("GET", "/_admin/openapi.json", nil)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = c.stream(req, &buf)
+	return buf.Bytes(), err
+}
+
+// Health returns the health report, with a *Error of code defs.CodeNotReady when a check fails
+func (c *Client) Health() (defs.HealthReport, error) {
+	var report defs.HealthReport
+	req, err := c.newRequest("GET", "/_admin/health", nil)
+	if err != nil {
+		return report, err
+	}
+	err = c.do(req, &report)
+	if clientErr, ok := err.(*Error); ok && clientErr.Code == defs.CodeNotReady {
+		json.Unmarshal(clientErr.Data, &report)
+	}
+	return report, err
+}
+
+// Ready reports whether the server can store files, failed names the checks that keep it from being ready
+func (c *Client) Ready() (bool, []string, error) {
+	var report defs.ReadyReport
+	req, err := c.newRequest("GET", "/_admin/ready", nil)
+	if err != nil {
+		return false, nil, err
+	}
+	err = c.do(req, &report)
+	if clientErr, ok := err.(*Error); ok && clientErr.Code == defs.CodeNotReady {
+		json.Unmarshal(clientErr.Data, &report)
+		return false, report.Failed, nil
+	}
+	return report.Ready, report.Failed, err
+}
@@ -0,0 +1,185 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type UploadOptions struct {
+	// files larger than MultipartThreshold are uploaded in parts of PartSize bytes
+	MultipartThreshold int64
+	PartSize           int64
+	Parallel           int
+	Retries            int
+	// StateFile keeps the upload id so an interrupted multipart upload can be resumed, "" disables resuming
+	StateFile   string
+	ContentType string
+	// KeepMtime sends the modification time of the local file to the server
+	KeepMtime bool
+	// Wrap is applied to every body read from the local file, e.g. to throttle bandwidth
+	Wrap func(r io.Reader) io.Reader
+}
+
+func DefaultUploadOptions() UploadOptions {
+	return UploadOptions{
+		MultipartThreshold: 64 * 1024 * 1024,
+		PartSize:           16 * 1024 * 1024,
+		Parallel:           4,
+		Retries:            3,
+	}
+}
+
+type uploadState struct {
+	UploadID   string `json:"uploadId"`
+	RemotePath string `json:"remotePath"`
+	Size       int64  `json:"size"`
+	Mtime      int64  `json:"mtime"`
+	PartSize   int64  `json:"partSize"`
+}
+
+// UploadFile uploads a local file, switching to a parallel multipart upload for large files
+func (c *Client) UploadFile(localPath string, remotePath string, options UploadOptions) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if options.ContentType == "" {
+		options.ContentType = mime.TypeByExtension(filepath.Ext(localPath))
+	}
+	if options.Retries <= 0 {
+		options.Retries = 1
+	}
+	if options.Wrap == nil {
+		options.Wrap = func(r io.Reader) io.Reader { return r }
+	}
+	if options.PartSize <= 0 || info.Size() <= options.MultipartThreshold {
+		return retry(options.Retries, func() error {
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			return c.Upload(remotePath, options.Wrap(file), options.ContentType, uploadMtime(info, options))
+		})
+	}
+	return c.uploadMultipart(file, info, remotePath, options)
+}
+
+func (c *Client) uploadMultipart(file *os.File, info os.FileInfo, remotePath string, options UploadOptions) error {
+	size := info.Size()
+	totalParts := int((size + options.PartSize - 1) / options.PartSize)
+	state := uploadState{
+		RemotePath: remotePath,
+		Size:       size,
+		Mtime:      info.ModTime().UnixNano(),
+		PartSize:   options.PartSize,
+	}
+	done := map[int]bool{}
+	if saved, ok := loadUploadState(options.StateFile); ok &&
+		saved.RemotePath == state.RemotePath && saved.Size == state.Size &&
+		saved.Mtime == state.Mtime && saved.PartSize == state.PartSize {
+		if status, err := c.MultipartStatus(saved.UploadID); err == nil {
+			state.UploadID = saved.UploadID
+			for _, part := range status.Parts {
+				if part.Size == partLength(size, options.PartSize, part.PartNumber) {
+					done[part.PartNumber] = true
+				}
+			}
+		}
+	}
+	if state.UploadID == "" {
+		uploadID, err := c.MultipartInit(remotePath, totalParts, size, options.ContentType, uploadMtime(info, options))
+		if err != nil {
+			return err
+		}
+		state.UploadID = uploadID
+		saveUploadState(options.StateFile, state)
+	}
+	parallel := options.Parallel
+	if parallel <= 0 {
+		parallel = 1
+	}
+	parts := make(chan int)
+	errs := make(chan error, totalParts)
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for partNumber := range parts {
+				offset := int64(partNumber-1) * options.PartSize
+				length := partLength(size, options.PartSize, partNumber)
+				err := retry(options.Retries, func() error {
+					section := io.NewSectionReader(file, offset, length)
+					return c.MultipartUpload(state.UploadID, partNumber, options.Wrap(section))
+				})
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	for partNumber := 1; partNumber <= totalParts; partNumber++ {
+		if !done[partNumber] {
+			parts <- partNumber
+		}
+	}
+	close(parts)
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return err
+	}
+	if err := c.MultipartEnd(state.UploadID); err != nil {
+		return err
+	}
+	if options.StateFile != "" {
+		os.Remove(options.StateFile)
+	}
+	return nil
+}
+
+func uploadMtime(info os.FileInfo, options UploadOptions) int64 {
+	if !options.KeepMtime {
+		return 0
+	}
+	return info.ModTime().Unix()
+}
+
+func partLength(size int64, partSize int64, partNumber int) int64 {
+	offset := int64(partNumber-1) * partSize
+	if offset+partSize > size {
+		return size - offset
+	}
+	return partSize
+}
+
+func loadUploadState(path string) (uploadState, bool) {
+	var state uploadState
+	if path == "" {
+		return state, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state, false
+	}
+	if err := json.Unmarshal(data, &state); err != nil || state.UploadID == "" {
+		return state, false
+	}
+	return state, true
+}
+
+func saveUploadState(path string, state uploadState) {
+	if path == "" {
+		return
+	}
+	data, _ := json.Marshal(state)
+	os.WriteFile(path, data, 0644)
+}
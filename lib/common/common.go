@@ -2,6 +2,8 @@ package common
 
 import (
 	"bytes"
+	crand "crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +11,7 @@ import (
 	"net/http"
 	"simple-file-server/lib/defs"
 	"simple-file-server/lib/result"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -41,6 +44,29 @@ func FormatBytes(bytes uint64) string {
 	}
 }
 
+// ParseBytes parses sizes like "512", "10K", "1.5MB" or "2G" into bytes
+func ParseBytes(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(str, "B")
+	unit := b
+	switch {
+	case strings.HasSuffix(str, "K"):
+		unit = kb
+	case strings.HasSuffix(str, "M"):
+		unit = mb
+	case strings.HasSuffix(str, "G"):
+		unit = gb
+	}
+	if unit != b {
+		str = str[:len(str)-1]
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return int64(value * float64(unit)), nil
+}
+
 func RandomString(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	b := make([]byte, length)
@@ -50,6 +76,23 @@ func RandomString(length int) string {
 	return string(b)
 }
 
+// IsAlphanumeric reports whether s is a non empty string of the characters RandomString uses
+func IsAlphanumeric(s string) bool {
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// SecureToken returns a random hex string of 2*n characters for secrets like tickets
+func SecureToken(n int) string {
+	b := make([]byte, n)
+	crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 func Request(url string, data interface{}) defs.Response {
 	requestBody, err := json.Marshal(data)
 	if err != nil {
@@ -83,7 +126,7 @@ func Request(url string, data interface{}) defs.Response {
 	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		return result.GenerateError("http status error: " + string(resp.StatusCode) + " " + string(body))
+		return result.GenerateError("http status error: " + strconv.Itoa(resp.StatusCode) + " " + string(body))
 	}
 	var res defs.Response
 	err = json.Unmarshal(body, &res)
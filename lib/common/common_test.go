@@ -0,0 +1,43 @@
+package common
+
+import "testing"
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    int64
+		wantErr bool
+	}{
+		{"512", 512, false},
+		{"512B", 512, false},
+		{"10K", 10 * 1024, false},
+		{"10kb", 10 * 1024, false},
+		{" 1.5MB ", 1536 * 1024, false},
+		{"2G", 2 * 1024 * 1024 * 1024, false},
+		{"0", 0, false},
+		{"", 0, true},
+		{"ten", 0, true},
+		{"-1M", 0, true},
+		{"1T", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBytes(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseBytes(%q) = %d, %v, want %d, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestIsAlphanumeric(t *testing.T) {
+	for s, want := range map[string]bool{
+		RandomString(32): true,
+		"abcXYZ019":      true,
+		"":               false,
+		"../a":           false,
+		"a-b":            false,
+	} {
+		if got := IsAlphanumeric(s); got != want {
+			t.Errorf("IsAlphanumeric(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
@@ -2,54 +2,211 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pelletier/go-toml/v2"
 	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"net"
 	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
 	"simple-file-server/global"
+	"simple-file-server/lib/common"
 	"simple-file-server/lib/defs"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-func Init() {
-	config := getConfig()
-	global.CONFIG = config
+const DefaultPath = "config.json"
+
+// DefaultToken is the token older versions wrote into the default config file
+const DefaultToken = "xxx"
+
+var bucketNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Path is the config file loaded by Init and Reload
+var Path = DefaultPath
+
+func Init(path string) {
+	if path != "" {
+		Path = path
+	}
+	config, err := getConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	global.SetConfig(config)
 	log.WithFields(log.Fields{
-		"port": config.Port,
+		"config": Path,
+		"port":   config.Port,
 	}).Info("config")
 }
 
-func getConfig() defs.Config {
-	_, err := os.Stat("config.json")
-	if os.IsNotExist(err) {
-		file, err := os.Create("config.json")
-		if err != nil {
-			log.Fatal(err)
-		}
-		file.Chmod(0700)
-		defer file.Close()
-		config := defs.Config{
-			Debug:    false,
-			ApiToken: "xxx",
-			Port:     60088,
-			DataDir:  "./data",
-			TempDir:  "./temp",
+// Reload re-reads the config file and applies every setting that does not need a restart
+func Reload() error {
+	config, err := getConfig()
+	if err != nil {
+		return err
+	}
+	current := global.Config()
+	if config.Port != current.Port || config.Listen != current.Listen || config.AdminListen != current.AdminListen ||
+		config.DataDir != current.DataDir || config.TempDir != current.TempDir {
+		log.Warn("listen address, dataDir and tempDir changes need a restart, keeping the current values")
+	}
+	config.Port = current.Port
+	config.Listen = current.Listen
+	config.AdminListen = current.AdminListen
+	config.DataDir = current.DataDir
+	config.TempDir = current.TempDir
+	global.SetConfig(config)
+	if config.Debug {
+		log.SetLevel(log.DebugLevel)
+	} else {
+		log.SetLevel(log.InfoLevel)
+	}
+	log.Info("config reloaded")
+	return nil
+}
+
+func getConfig() (defs.Config, error) {
+	config := defs.Config{}
+	if _, err := os.Stat(Path); os.IsNotExist(err) {
+		if Path != DefaultPath {
+			return config, fmt.Errorf("config file %s not found", Path)
 		}
-		encoder := json.NewEncoder(file)
-		encoder.SetIndent("", "    ")
-		err = encoder.Encode(config)
-		if err != nil {
-			log.Fatal(err)
+		if err := createDefault(Path); err != nil {
+			return config, err
 		}
-		log.Info("Default config file created at config.json")
 	}
-	file, err := os.Open("config.json")
+	if err := decodeFile(Path, &config); err != nil {
+		return config, fmt.Errorf("config file %s: %w", Path, err)
+	}
+	if err := applyEnv(&config); err != nil {
+		return config, err
+	}
+	applyDefaults(&config)
+	if err := Validate(config); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// createDefault writes a config file with a random admin token
+func createDefault(path string) error {
+	file, err := os.Create(path)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	file.Chmod(0600)
 	defer file.Close()
-	decoder := json.NewDecoder(file)
-	config := defs.Config{}
-	err = decoder.Decode(&config)
+	config := defs.Config{
+		Debug:    false,
+		ApiToken: common.RandomString(32),
+		Port:     60088,
+		DataDir:  "./data",
+		TempDir:  "./temp",
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(config); err != nil {
+		return err
+	}
+	log.Info("Default config file created at ", path, ", find the generated apiToken there")
+	return nil
+}
+
+// decodeFile reads json, yaml or toml depending on the extension, always using the json field names.
+// Unknown keys are logged and ignored, so a config written for a newer or older version still loads.
+func decodeFile(path string, config *defs.Config) error {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	var values map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+		if err == nil {
+			data, err = json.Marshal(values)
+		}
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+		if err == nil {
+			data, err = json.Marshal(values)
+		}
+	default:
+		err = json.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return err
+	}
+	for _, key := range unknownKeys(values, reflect.TypeOf(*config), "") {
+		log.Warnf("config file %s: unknown key %s is ignored", path, key)
+	}
+	return nil
+}
+
+// unknownKeys returns the json paths in value that no field of t takes, matching names like encoding/json does
+func unknownKeys(value interface{}, t reflect.Type, prefix string) []string {
+	var keys []string
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if t.Kind() == reflect.Map {
+			for key, item := range v {
+				keys = append(keys, unknownKeys(item, t.Elem(), prefix+key+".")...)
+			}
+			break
+		}
+		if t.Kind() != reflect.Struct {
+			break
+		}
+		for key, item := range v {
+			field, ok := jsonField(t, key)
+			if !ok {
+				keys = append(keys, prefix+key)
+				continue
+			}
+			keys = append(keys, unknownKeys(item, field.Type, prefix+key+".")...)
+		}
+	case []interface{}:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			break
+		}
+		for i, item := range v {
+			keys = append(keys, unknownKeys(item, t.Elem(), fmt.Sprintf("%s%d.", prefix, i))...)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func applyDefaults(config *defs.Config) {
+	if config.Port == 0 {
+		config.Port = 60088
 	}
 	if config.TempDir == "" {
 		config.TempDir = "./temp"
@@ -57,5 +214,357 @@ func getConfig() defs.Config {
 	if config.DataDir == "" {
 		config.DataDir = "./data"
 	}
-	return config
+	if config.Log.Dir == "" {
+		config.Log.Dir = "./log"
+	}
+	if config.AccessLog == "" {
+		config.AccessLog = filepath.Join(config.Log.Dir, "access.log")
+	}
+	if config.AdminAccessLog == "" {
+		config.AdminAccessLog = filepath.Join(config.Log.Dir, "admin-access.log")
+	}
+	if config.Log.MaxAge == 0 {
+		config.Log.MaxAge = 28
+	}
+	if config.Log.Format == "" {
+		config.Log.Format = defs.LogFormatText
+	}
+	if config.Log.AccessFormat == "" {
+		config.Log.AccessFormat = defs.LogFormatText
+	}
+	if config.MonitorInterval == 0 {
+		config.MonitorInterval = 60 * 10
+	}
+	if config.ShutdownTimeout == 0 {
+		config.ShutdownTimeout = 30
+	}
+	if config.ExtractMaxSize == 0 {
+		config.ExtractMaxSize = 1024 * 1024 * 1024
+	}
+	if config.ExtractMaxEntries == 0 {
+		config.ExtractMaxEntries = 10000
+	}
+	if config.TicketMaxTtl == 0 {
+		config.TicketMaxTtl = 3600
+	}
+	if config.PostPolicy.MaxTtl == 0 {
+		config.PostPolicy.MaxTtl = 86400
+	}
+	if config.Replication.Interval == 0 {
+		config.Replication.Interval = 5
+	}
+	if config.WebhookInterval == 0 {
+		config.WebhookInterval = 5
+	}
+	if config.Health.MinFreeSpace == 0 {
+		config.Health.MinFreeSpace = 100 * 1024 * 1024
+	}
+	if config.Health.MinFreeInodes == 0 {
+		config.Health.MinFreeInodes = 1000
+	}
+	if config.Health.MaxBacklog == 0 {
+		config.Health.MaxBacklog = 1000
+	}
+	if config.Proxy.NegativeTtl == 0 {
+		config.Proxy.NegativeTtl = 60
+	}
+	if config.Proxy.Timeout == 0 {
+		config.Proxy.Timeout = 30
+	}
+	for i, replica := range config.Replication.Replicas {
+		if replica.Name == "" {
+			config.Replication.Replicas[i].Name = fmt.Sprintf("replica%d", i+1)
+		}
+	}
+}
+
+// Validate checks config and reports every problem found at once
+func Validate(config defs.Config) error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	if config.Listen == "" && (config.Port < 1 || config.Port > 65535) {
+		add("port must be between 1 and 65535, got %d", config.Port)
+	}
+	if config.ApiToken == "" {
+		add("apiToken must not be empty")
+	} else if config.ApiToken == DefaultToken && !config.AllowDefaultToken {
+		add("apiToken is the default %q, change it or set allowDefaultToken to true", DefaultToken)
+	}
+	if filepath.Clean(config.DataDir) == filepath.Clean(config.TempDir) {
+		add("dataDir and tempDir must be different directories")
+	} else if isSubDir(config.DataDir, config.TempDir) {
+		add("tempDir must not be inside dataDir, its content would be served publicly")
+	}
+	if config.AdminSocketMode != "" {
+		if _, err := parseMode(config.AdminSocketMode); err != nil {
+			add("adminSocketMode must be an octal file mode like 0660, got %q", config.AdminSocketMode)
+		}
+	}
+	if (config.Tls.CertFile == "") != (config.Tls.KeyFile == "") {
+		add("tls.certFile and tls.keyFile must be set together")
+	}
+	if config.Tls.ClientCaFile != "" && config.Tls.CertFile == "" {
+		add("tls.clientCaFile needs tls.certFile and tls.keyFile")
+	}
+	for name, value := range map[string]int64{
+		"monitorInterval":      config.MonitorInterval,
+		"log.maxAge":           int64(config.Log.MaxAge),
+		"shutdownTimeout":      config.ShutdownTimeout,
+		"extractMaxSize":       config.ExtractMaxSize,
+		"extractMaxEntries":    int64(config.ExtractMaxEntries),
+		"replication.interval": config.Replication.Interval,
+		"webhookInterval":      config.WebhookInterval,
+		"ticketMaxTtl":         config.TicketMaxTtl,
+		"postPolicy.maxTtl":    config.PostPolicy.MaxTtl,
+		"proxy.negativeTtl":    config.Proxy.NegativeTtl,
+		"proxy.timeout":        config.Proxy.Timeout,
+		"health.minFreeSpace":  config.Health.MinFreeSpace,
+		"health.minFreeInodes": config.Health.MinFreeInodes,
+		"health.maxBacklog":    int64(config.Health.MaxBacklog),
+	} {
+		if value <= 0 {
+			add("%s must be greater than 0, got %d", name, value)
+		}
+	}
+	if config.Log.Format != defs.LogFormatText && config.Log.Format != defs.LogFormatJson {
+		add("log.format must be text or json, got %q", config.Log.Format)
+	}
+	switch config.Log.AccessFormat {
+	case defs.LogFormatText, defs.LogFormatJson, defs.LogFormatCombined:
+	default:
+		add("log.accessFormat must be text, json or combined, got %q", config.Log.AccessFormat)
+	}
+	for _, proxy := range config.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			add("trustedProxies %q is not an ip or cidr", proxy)
+		}
+	}
+	for name, value := range map[string]int64{
+		"scrubInterval":           config.ScrubInterval,
+		"replication.maxRetries":  int64(config.Replication.MaxRetries),
+		"proxy.ttl":               config.Proxy.Ttl,
+		"proxy.maxSize":           config.Proxy.MaxSize,
+		"rateLimit.perIp":         config.RateLimit.PerIp,
+		"rateLimit.perIpBurst":    config.RateLimit.PerIpBurst,
+		"rateLimit.perToken":      config.RateLimit.PerToken,
+		"rateLimit.perTokenBurst": config.RateLimit.PerTokenBurst,
+		"bandwidth.perConnection": config.Bandwidth.PerConnection,
+		"bandwidth.global":        config.Bandwidth.Global,
+		"bandwidth.burst":         config.Bandwidth.Burst,
+	} {
+		if value < 0 {
+			add("%s must not be negative, got %d", name, value)
+		}
+	}
+	for name, rule := range map[string]defs.CorsRule{"cors.public": config.Cors.Public, "cors.admin": config.Cors.Admin} {
+		if rule.MaxAge < 0 {
+			add("%s.maxAge must not be negative, got %d", name, rule.MaxAge)
+		}
+		for _, origin := range rule.AllowOrigins {
+			if origin == "*" && rule.AllowCredentials {
+				add("%s.allowOrigins \"*\" can not be combined with allowCredentials, list the origins", name)
+			} else if origin != "*" && !isHttpUrl(origin) {
+				add("%s.allowOrigins %q must be \"*\" or start with http:// or https://", name, origin)
+			}
+		}
+	}
+	if config.PostPolicy.Secret != "" && len(config.PostPolicy.Secret) < 16 {
+		add("postPolicy.secret must be at least 16 characters")
+	}
+	for i, rule := range config.AccessRules {
+		if err := ValidateAccessRule(rule); err != nil {
+			add("accessRules[%d]: %s", i, err)
+		}
+		if rule.Mode == defs.AccessPrivate && config.SignSecret == "" {
+			add("accessRules[%d] is private, signSecret must be set", i)
+		}
+	}
+	if config.SignSecret != "" && len(config.SignSecret) < 16 {
+		add("signSecret must be at least 16 characters")
+	}
+	for i, site := range config.Sites {
+		if site.Host == "" && site.Prefix == "" {
+			add("sites[%d] needs a host or a prefix", i)
+		}
+		if site.Host != "" && site.Root == "" && site.Prefix == "" {
+			add("sites[%d].root must be set for a host site, it would serve the whole dataDir", i)
+		}
+		for j, redirect := range site.Redirects {
+			if !strings.HasPrefix(redirect.From, "/") || redirect.To == "" {
+				add("sites[%d].redirects[%d] needs from starting with / and to", i, j)
+			}
+			switch redirect.Status {
+			case 0, 301, 302, 303, 307, 308:
+			default:
+				add("sites[%d].redirects[%d].status must be a redirect status, got %d", i, j, redirect.Status)
+			}
+		}
+		for j, rule := range site.CacheControl {
+			if _, err := path.Match(rule.Pattern, ""); err != nil || rule.Pattern == "" {
+				add("sites[%d].cacheControl[%d].pattern %q is invalid", i, j, rule.Pattern)
+			}
+		}
+	}
+	for i, rule := range config.Headers {
+		if rule.Pattern == "" && len(rule.Extensions) == 0 {
+			add("headers[%d] needs a pattern or extensions", i)
+		}
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			add("headers[%d].pattern %q is invalid", i, rule.Pattern)
+		}
+		if rule.Expires < 0 {
+			add("headers[%d].expires must not be negative", i)
+		}
+		for name := range rule.Headers {
+			if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+				add("headers[%d].headers %q is not a valid header name", i, name)
+			}
+		}
+	}
+	tokenNames := map[string]bool{"admin": true}
+	for i, token := range config.Tokens {
+		if token.Name == "" || tokenNames[token.Name] || strings.Contains(token.Name, "/") {
+			add("tokens[%d].name must be set, unique, without / and not \"admin\"", i)
+		}
+		tokenNames[token.Name] = true
+		if token.Token == "" || token.Token == config.ApiToken {
+			add("tokens[%d].token must be set and differ from apiToken", i)
+		}
+		validateUploadLimits(fmt.Sprintf("tokens[%d].upload", i), token.Upload, add)
+	}
+	validateUploadLimits("upload", config.Upload, add)
+	for i, rule := range config.UploadRules {
+		if rule.Prefix == "" {
+			add("uploadRules[%d].prefix must not be empty", i)
+		}
+		validateUploadLimits(fmt.Sprintf("uploadRules[%d].limits", i), rule.Limits, add)
+	}
+	names := map[string]bool{}
+	for i, replica := range config.Replication.Replicas {
+		if !isHttpUrl(replica.Url) {
+			add("replication.replicas[%d].url must start with http:// or https://", i)
+		}
+		if replica.Token == "" {
+			add("replication.replicas[%d].token must not be empty", i)
+		}
+		if names[replica.Name] {
+			add("replication.replicas[%d].name %q is used twice", i, replica.Name)
+		}
+		names[replica.Name] = true
+	}
+	if config.Proxy.Upstream != "" && !isHttpUrl(config.Proxy.Upstream) {
+		add("proxy.upstream must start with http:// or https://")
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New("invalid config: " + strings.Join(problems, "; "))
+}
+
+// ValidateAccessRule checks a rule from accessRules or an .access file
+func ValidateAccessRule(rule defs.AccessRule) error {
+	var problems []string
+	switch rule.Mode {
+	case "", defs.AccessPublic, defs.AccessPrivate, defs.AccessToken:
+	default:
+		problems = append(problems, fmt.Sprintf("mode must be public, private or token, got %q", rule.Mode))
+	}
+	if rule.Mode == defs.AccessToken && len(rule.Tokens) == 0 {
+		problems = append(problems, "mode token needs tokens")
+	}
+	for _, ip := range rule.AllowIps {
+		if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil {
+			problems = append(problems, fmt.Sprintf("allowIps %q is not an ip or cidr", ip))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, ", "))
+}
+
+// ValidateBucket checks a bucket definition against config, uniqueness among buckets is up to the caller
+func ValidateBucket(bucket defs.Bucket, config defs.Config) error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	if !bucketNamePattern.MatchString(bucket.Name) {
+		add("name must be 1 to 63 lowercase letters, digits, - or _, starting with a letter or digit")
+	}
+	if bucket.Quota < 0 {
+		add("quota must not be negative")
+	}
+	tokenNames := map[string]bool{}
+	for i, token := range bucket.Tokens {
+		if token.Name == "" || tokenNames[token.Name] || strings.Contains(token.Name, "/") {
+			add("tokens[%d].name must be set, unique and without /", i)
+		}
+		tokenNames[token.Name] = true
+		if len(token.Token) < 16 || token.Token == config.ApiToken {
+			add("tokens[%d].token must be at least 16 characters and differ from apiToken", i)
+		}
+		for _, t := range config.Tokens {
+			if t.Token == token.Token {
+				add("tokens[%d].token is already used by tokens of the config", i)
+			}
+		}
+		validateUploadLimits(fmt.Sprintf("tokens[%d].upload", i), token.Upload, add)
+	}
+	for i, rule := range bucket.AccessRules {
+		if err := ValidateAccessRule(rule); err != nil {
+			add("accessRules[%d]: %s", i, err)
+		}
+		if rule.Mode == defs.AccessPrivate && config.SignSecret == "" {
+			add("accessRules[%d] is private, signSecret must be set", i)
+		}
+	}
+	if bucket.Webhook.Url != "" && !isHttpUrl(bucket.Webhook.Url) {
+		add("webhook.url must start with http:// or https://")
+	}
+	if bucket.Webhook.MaxRetries < 0 {
+		add("webhook.maxRetries must not be negative")
+	}
+	for _, event := range bucket.Webhook.Events {
+		switch event {
+		case "upload", "mkdir", "move", "delete":
+		default:
+			add("webhook.events %q must be upload, mkdir, move or delete", event)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+func validateUploadLimits(name string, limits defs.UploadLimits, add func(format string, args ...interface{})) {
+	if limits.MaxSize < 0 || limits.MaxPartSize < 0 || limits.MaxParts < 0 || limits.MaxTotalSize < 0 {
+		add("%s sizes and maxParts must not be negative", name)
+	}
+	for _, pattern := range append(limits.AllowMimeTypes, limits.DenyMimeTypes...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			add("%s mime type pattern %q is invalid", name, pattern)
+		}
+	}
+}
+
+func parseMode(mode string) (uint64, error) {
+	return strconv.ParseUint(mode, 8, 32)
+}
+
+func isHttpUrl(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+func isSubDir(parent string, child string) bool {
+	parentAbs, err1 := filepath.Abs(parent)
+	childAbs, err2 := filepath.Abs(child)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	rel, err := filepath.Rel(parentAbs, childAbs)
+	return err == nil && rel != "." && !strings.HasPrefix(rel, "..")
 }
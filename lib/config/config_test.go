@@ -0,0 +1,184 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"simple-file-server/global"
+	"simple-file-server/lib/defs"
+	"strings"
+	"testing"
+)
+
+// inTempDir runs the test in an empty directory, so config.json is written there
+func inTempDir(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	previous := *global.Config()
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		global.SetConfig(previous)
+	})
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	inTempDir(t)
+	global.SetConfig(defs.Config{ApiToken: "admintoken-123456", Port: 60088, DataDir: "./data", TempDir: "./temp", MonitorInterval: 600})
+	writeConfig(t, "config.json", `{"apiToken": "othertoken-123456", "port": 8080, "dataDir": "./other", "tempDir": "./tmp", "monitorInterval": 60}`)
+
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	config := global.Config()
+	if config.ApiToken != "othertoken-123456" || config.MonitorInterval != 60 {
+		t.Errorf("reloaded apiToken %q and monitorInterval %d were not applied", config.ApiToken, config.MonitorInterval)
+	}
+	// the port and the directories need a restart
+	if config.Port != 60088 || config.DataDir != "./data" || config.TempDir != "./temp" {
+		t.Errorf("reload changed port %d, dataDir %q and tempDir %q", config.Port, config.DataDir, config.TempDir)
+	}
+
+	// a broken file keeps the running config
+	writeConfig(t, "config.json", `{"apiToken": `)
+	if err := Reload(); err == nil {
+		t.Error("Reload of a broken file returned no error")
+	}
+	if global.Config().ApiToken != "othertoken-123456" {
+		t.Error("a failed reload replaced the config")
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for path, want := range map[string]string{
+		"port":                 "SFS_PORT",
+		"apiToken":             "SFS_API_TOKEN",
+		"tls.certFile":         "SFS_TLS_CERT_FILE",
+		"replication.replicas": "SFS_REPLICATION_REPLICAS",
+	} {
+		if got := EnvName(path); got != want {
+			t.Errorf("EnvName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("SFS_PORT", "9000")
+	t.Setenv("SFS_DEBUG", "true")
+	t.Setenv("SFS_TLS_CERT_FILE", "cert.pem")
+	t.Setenv("SFS_REPLICATION_REPLICAS", `[{"url": "http://replica:60088", "token": "x"}]`)
+	config := defs.Config{Port: 60088}
+	if err := applyEnv(&config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Port != 9000 || !config.Debug || config.Tls.CertFile != "cert.pem" ||
+		len(config.Replication.Replicas) != 1 || config.Replication.Replicas[0].Url != "http://replica:60088" {
+		t.Errorf("applyEnv = %+v", config)
+	}
+
+	t.Setenv("SFS_PORT", "abc")
+	if err := applyEnv(&config); err == nil || !strings.Contains(err.Error(), "SFS_PORT") {
+		t.Errorf("applyEnv with an invalid port = %v, want an error naming SFS_PORT", err)
+	}
+}
+
+func TestDecodeFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.json": `{"port": 8080, "tls": {"certFile": "cert.pem", "unknownTls": 1}, "unknown": true}`,
+		"config.yaml": "port: 8080\ntls:\n  certFile: cert.pem\n  unknownTls: 1\nunknown: true\n",
+		"config.toml": "port = 8080\nunknown = true\n[tls]\ncertFile = \"cert.pem\"\nunknownTls = 1\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		writeConfig(t, path, content)
+		var config defs.Config
+		if err := decodeFile(path, &config); err != nil {
+			t.Errorf("decodeFile(%s): %v", name, err)
+			continue
+		}
+		if config.Port != 8080 || config.Tls.CertFile != "cert.pem" {
+			t.Errorf("decodeFile(%s) = %+v", name, config)
+		}
+	}
+
+	var values map[string]interface{}
+	json.Unmarshal([]byte(files["config.json"]), &values)
+	if keys := unknownKeys(values, reflect.TypeOf(defs.Config{}), ""); !reflect.DeepEqual(keys, []string{"tls.unknownTls", "unknown"}) {
+		t.Errorf("unknownKeys = %q", keys)
+	}
+
+	path := filepath.Join(dir, "broken.yaml")
+	writeConfig(t, path, "port: [")
+	if err := decodeFile(path, &defs.Config{}); err == nil {
+		t.Error("decodeFile of a broken file returned no error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() defs.Config {
+		config := defs.Config{ApiToken: "admintoken-123456"}
+		applyDefaults(&config)
+		return config
+	}
+	if err := Validate(valid()); err != nil {
+		t.Fatalf("Validate of the defaults: %v", err)
+	}
+	for name, change := range map[string]func(*defs.Config){
+		"empty apiToken":      func(c *defs.Config) { c.ApiToken = "" },
+		"default apiToken":    func(c *defs.Config) { c.ApiToken = DefaultToken },
+		"port out of range":   func(c *defs.Config) { c.Port = 70000 },
+		"same directories":    func(c *defs.Config) { c.TempDir = c.DataDir + "/" },
+		"tempDir in dataDir":  func(c *defs.Config) { c.TempDir = filepath.Join(c.DataDir, "temp") },
+		"certFile alone":      func(c *defs.Config) { c.Tls.CertFile = "cert.pem" },
+		"invalid socket mode": func(c *defs.Config) { c.AdminSocketMode = "rw" },
+		"negative interval":   func(c *defs.Config) { c.ScrubInterval = -1 },
+	} {
+		config := valid()
+		change(&config)
+		if err := Validate(config); err == nil {
+			t.Errorf("Validate with %s returned no error", name)
+		}
+	}
+
+	// every problem is reported at once
+	config := valid()
+	config.ApiToken = ""
+	config.Port = 0
+	err := Validate(config)
+	if err == nil || !strings.Contains(err.Error(), "apiToken") || !strings.Contains(err.Error(), "port") {
+		t.Errorf("Validate = %v, want both problems", err)
+	}
+}
+
+func TestAccessLogDefaults(t *testing.T) {
+	config := defs.Config{Log: defs.LogConfig{Dir: "/var/log/sfs"}}
+	applyDefaults(&config)
+	if config.AccessLog != filepath.Join("/var/log/sfs", "access.log") || config.AdminAccessLog != filepath.Join("/var/log/sfs", "admin-access.log") {
+		t.Errorf("access logs default to %q and %q", config.AccessLog, config.AdminAccessLog)
+	}
+	if config.Log.AccessFormat != defs.LogFormatText {
+		t.Errorf("log.accessFormat defaults to %q", config.Log.AccessFormat)
+	}
+
+	config = defs.Config{AccessLog: "stdout", Log: defs.LogConfig{AccessFormat: "xml"}}
+	applyDefaults(&config)
+	if config.AccessLog != "stdout" || config.AdminAccessLog != filepath.Join("log", "admin-access.log") {
+		t.Errorf("access logs are %q and %q", config.AccessLog, config.AdminAccessLog)
+	}
+	config.ApiToken = "admintoken-123456"
+	if err := Validate(config); err == nil || !strings.Contains(err.Error(), "log.accessFormat") {
+		t.Errorf("Validate of access format xml = %v", err)
+	}
+}
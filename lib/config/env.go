@@ -0,0 +1,90 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"simple-file-server/lib/defs"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const envPrefix = "SFS_"
+
+// EnvName returns the environment variable overriding the field at the json path, e.g. tls.certFile -> SFS_TLS_CERT_FILE
+func EnvName(jsonPath string) string {
+	var b strings.Builder
+	b.WriteString(envPrefix)
+	for i, r := range jsonPath {
+		switch {
+		case r == '.':
+			b.WriteRune('_')
+		case unicode.IsUpper(r) && i > 0 && jsonPath[i-1] != '.':
+			b.WriteRune('_')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// applyEnv overrides config fields from SFS_* environment variables, lists and maps are given as JSON
+func applyEnv(config *defs.Config) error {
+	return applyEnvStruct(reflect.ValueOf(config).Elem(), "")
+}
+
+func applyEnvStruct(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		path := prefix + name
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnvStruct(v.Field(i), path+"."); err != nil {
+				return err
+			}
+			continue
+		}
+		env := EnvName(path)
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		if err := setValue(v.Field(i), value); err != nil {
+			return fmt.Errorf("environment variable %s: %w", env, err)
+		}
+	}
+	return nil
+}
+
+func setValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		v.SetInt(n)
+	default:
+		target := reflect.New(v.Type())
+		if err := json.Unmarshal([]byte(value), target.Interface()); err != nil {
+			return fmt.Errorf("invalid JSON value: %w", err)
+		}
+		v.Set(target.Elem())
+	}
+	return nil
+}
@@ -15,8 +15,37 @@ func Run() {
 		cron.WithLocation(nyc),
 		cron.WithChain(cron.Recover(cron.DefaultLogger)),
 		cron.WithChain(cron.DelayIfStillRunning(cron.DefaultLogger)))
-	if err := module.StartMonitor(false, 60*10); err != nil {
+	global.CronIDLock.Lock()
+	defer global.CronIDLock.Unlock()
+	if err := module.StartMonitor(false, global.Config().MonitorInterval); err != nil {
 		log.Errorf("can not add monitor corn job: %s", err.Error())
 	}
+	if err := module.StartScrubber(false, global.Config().ScrubInterval); err != nil {
+		log.Errorf("can not add scrubber corn job: %s", err.Error())
+	}
+	if err := module.StartReplication(false, global.Config().Replication.Interval); err != nil {
+		log.Errorf("can not add replication corn job: %s", err.Error())
+	}
+	if err := module.StartWebhooks(false, global.Config().WebhookInterval); err != nil {
+		log.Errorf("can not add webhook corn job: %s", err.Error())
+	}
 	global.CRON.Start()
 }
+
+// Reload reschedules the jobs with the intervals of the current config
+func Reload() {
+	global.CronIDLock.Lock()
+	defer global.CronIDLock.Unlock()
+	if err := module.StartMonitor(true, global.Config().MonitorInterval); err != nil {
+		log.Errorf("can not add monitor corn job: %s", err.Error())
+	}
+	if err := module.StartScrubber(true, global.Config().ScrubInterval); err != nil {
+		log.Errorf("can not add scrubber corn job: %s", err.Error())
+	}
+	if err := module.StartReplication(true, global.Config().Replication.Interval); err != nil {
+		log.Errorf("can not add replication corn job: %s", err.Error())
+	}
+	if err := module.StartWebhooks(true, global.Config().WebhookInterval); err != nil {
+		log.Errorf("can not add webhook corn job: %s", err.Error())
+	}
+}
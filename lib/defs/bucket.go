@@ -0,0 +1,29 @@
+package defs
+
+// Bucket is a named root directory DataDir/<Name> with its own tokens, quota, access rules and webhook.
+// Buckets are created with the _admin/bucket routes and stored in TempDir/Buckets.
+type Bucket struct {
+	Name string `json:"name"`
+	// Host serves the bucket at the root of a host name, the bucket is always served below /<Name>/ too
+	Host string `json:"host"`
+	// Tokens may call the _admin routes, their paths are relative to the bucket
+	Tokens []TokenConfig `json:"tokens"`
+	// Quota is the most bytes the bucket may hold, 0 is unlimited
+	Quota int64 `json:"quota"`
+	// AccessRules work like accessRules of the config, their prefixes are relative to the bucket
+	AccessRules []AccessRule  `json:"accessRules"`
+	Webhook     WebhookConfig `json:"webhook"`
+	CreatedAt   int64         `json:"createdAt"`
+}
+
+// WebhookConfig posts the changes of a bucket to Url
+type WebhookConfig struct {
+	// Url receives a json POST per change, "" disables the webhook
+	Url string `json:"url"`
+	// Secret signs the body with HMAC-SHA256 in the X-Sfs-Signature header
+	Secret string `json:"secret"`
+	// Events lists the ops to send: upload, mkdir, move and delete, empty sends all
+	Events []string `json:"events"`
+	// MaxRetries moves an event to the failed queue after this many attempts, 0 retries forever
+	MaxRetries int `json:"maxRetries"`
+}
@@ -5,7 +5,256 @@ type Config struct {
 
 	Port     int    `json:"port"`
 	ApiToken string `json:"apiToken"`
+	// AllowDefaultToken allows starting with the well known default apiToken
+	AllowDefaultToken bool `json:"allowDefaultToken"`
+	// Tokens are extra admin tokens, each with its own upload limits on top of Upload
+	Tokens []TokenConfig `json:"tokens"`
+	// Listen is the public address, defaults to ":Port"
+	Listen string `json:"listen"`
+	// AdminListen serves the _admin routes on a separate address like "127.0.0.1:60089" or "unix:/run/sfs.sock"
+	AdminListen     string `json:"adminListen"`
+	AdminSocketMode string `json:"adminSocketMode"`
+
+	AccessLog      string    `json:"accessLog"`
+	AdminAccessLog string    `json:"adminAccessLog"`
+	Log            LogConfig `json:"log"`
+	// TrustedProxies lists the proxy ips or cidrs whose X-Forwarded-For and X-Real-IP headers give the client ip
+	TrustedProxies []string `json:"trustedProxies"`
+
+	Tls TlsConfig `json:"tls"`
 
 	TempDir string `json:"tempDir"`
 	DataDir string `json:"dataDir"`
+
+	// seconds between two runs of the temp dir monitor
+	MonitorInterval int64 `json:"monitorInterval"`
+	// seconds in-flight requests get to finish on shutdown
+	ShutdownTimeout int64 `json:"shutdownTimeout"`
+
+	ExtractMaxSize    int64 `json:"extractMaxSize"`
+	ExtractMaxEntries int   `json:"extractMaxEntries"`
+
+	ScrubInterval int64 `json:"scrubInterval"`
+
+	// Upload limits every upload, UploadRules add limits for path prefixes
+	Upload      UploadLimits `json:"upload"`
+	UploadRules []UploadRule `json:"uploadRules"`
+
+	// TicketMaxTtl is the longest lifetime in seconds of an upload ticket
+	TicketMaxTtl int64      `json:"ticketMaxTtl"`
+	Cors         CorsConfig `json:"cors"`
+	// AccessRules restrict public serving by path prefix, .access files in DataDir can do the same per directory
+	AccessRules []AccessRule `json:"accessRules"`
+	// SignSecret signs the urls of private paths, see _admin/sign
+	SignSecret string `json:"signSecret"`
+	// Indexes turns directory index pages on or off below prefixes, they are off by default
+	Indexes []IndexConfig `json:"indexes"`
+	// Sites host static websites by Host header or path prefix
+	Sites []SiteConfig `json:"sites"`
+	// Headers set response headers of public files by glob or extension, every matching rule applies in order
+	Headers []HeaderRule `json:"headers"`
+	// PostPolicy enables signed HTML form uploads to the public /_upload endpoint
+	PostPolicy PostPolicyConfig `json:"postPolicy"`
+
+	RateLimit RateLimitConfig `json:"rateLimit"`
+	Bandwidth BandwidthConfig `json:"bandwidth"`
+
+	Replication ReplicationConfig `json:"replication"`
+	Proxy       ProxyConfig       `json:"proxy"`
+
+	// WebhookInterval is the number of seconds between two runs of the bucket webhook queues
+	WebhookInterval int64 `json:"webhookInterval"`
+
+	Health HealthConfig `json:"health"`
+}
+
+type TokenConfig struct {
+	Name   string       `json:"name"`
+	Token  string       `json:"token"`
+	Upload UploadLimits `json:"upload"`
+}
+
+// UploadLimits bounds uploads, 0 and empty lists mean no limit
+type UploadLimits struct {
+	// MaxSize is the largest single upload in bytes
+	MaxSize int64 `json:"maxSize"`
+	// MaxPartSize, MaxParts and MaxTotalSize bound multipart uploads
+	MaxPartSize  int64 `json:"maxPartSize"`
+	MaxParts     int   `json:"maxParts"`
+	MaxTotalSize int64 `json:"maxTotalSize"`
+	// extensions like ".jpg", matched case insensitively
+	AllowExtensions []string `json:"allowExtensions"`
+	DenyExtensions  []string `json:"denyExtensions"`
+	// mime types like "image/png" or "image/*", checked against the type sniffed from the content
+	AllowMimeTypes []string `json:"allowMimeTypes"`
+	DenyMimeTypes  []string `json:"denyMimeTypes"`
+}
+
+type UploadRule struct {
+	Prefix string       `json:"prefix"`
+	Limits UploadLimits `json:"limits"`
+}
+
+const (
+	AccessPublic  = "public"
+	AccessPrivate = "private"
+	AccessToken   = "token"
+)
+
+// AccessRule is an entry of accessRules, or the content of an .access file without the prefix
+type AccessRule struct {
+	Prefix string `json:"prefix,omitempty"`
+	// Mode is public (default), private (signed url required) or token
+	Mode string `json:"mode"`
+	// Tokens are accepted in the access-token header or the token query parameter in token mode
+	Tokens []string `json:"tokens"`
+	// AllowIps lists ips or cidrs like "10.0.0.0/8", empty allows every ip
+	AllowIps []string `json:"allowIps"`
+	// AllowReferers lists referer hosts like "example.com" or "*.example.com", empty allows every referer
+	AllowReferers []string `json:"allowReferers"`
+	// AllowEmptyReferer lets requests without a Referer pass AllowReferers, like direct visits
+	AllowEmptyReferer bool `json:"allowEmptyReferer"`
+}
+
+type IndexConfig struct {
+	Prefix  string `json:"prefix"`
+	Enabled bool   `json:"enabled"`
+}
+
+type SiteConfig struct {
+	// Host matches the Host header without the port, Prefix the start of the url path, a site needs at least one
+	Host   string `json:"host"`
+	Prefix string `json:"prefix"`
+	// Root is the directory in DataDir the site is served from, defaults to Prefix
+	Root string `json:"root"`
+	// IndexDocument is served for directories, defaults to index.html
+	IndexDocument string `json:"indexDocument"`
+	// ErrorDocument is a page in Root served with status 404 for missing files
+	ErrorDocument string `json:"errorDocument"`
+	// Spa serves the root IndexDocument for every missing file, for client side routing
+	Spa          bool           `json:"spa"`
+	Redirects    []RedirectRule `json:"redirects"`
+	CacheControl []CacheRule    `json:"cacheControl"`
+}
+
+// RedirectRule redirects From, a site path like "/old" or "/old/*", to To where * is the rest of the path
+type RedirectRule struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Status defaults to 301
+	Status int `json:"status"`
+}
+
+// CacheRule sets Cache-Control for site paths matching Pattern, like "*.html" or "assets/*", the first match wins
+type CacheRule struct {
+	Pattern string `json:"pattern"`
+	Value   string `json:"value"`
+}
+
+// HeaderRule matches public files by Pattern, a glob like "*.html" or "assets/*", or by Extensions like ".js"
+type HeaderRule struct {
+	Pattern    string   `json:"pattern"`
+	Extensions []string `json:"extensions"`
+	// CacheControl is the Cache-Control value, Expires the number of seconds until the Expires date
+	CacheControl string `json:"cacheControl"`
+	Expires      int64  `json:"expires"`
+	// Attachment makes browsers download the file under its own name
+	Attachment            bool   `json:"attachment"`
+	ContentSecurityPolicy string `json:"contentSecurityPolicy"`
+	// Headers are set as given, "" removes a header set by an earlier rule
+	Headers map[string]string `json:"headers"`
+}
+
+type PostPolicyConfig struct {
+	// Secret signs the policies, "" disables form uploads
+	Secret string `json:"secret"`
+	// MaxTtl is the longest lifetime in seconds of a policy
+	MaxTtl int64 `json:"maxTtl"`
+}
+
+type CorsConfig struct {
+	Public CorsRule `json:"public"`
+	Admin  CorsRule `json:"admin"`
+}
+
+type CorsRule struct {
+	// AllowOrigins lists origins like "https://app.example.com", "*" allows any, empty disables CORS
+	AllowOrigins []string `json:"allowOrigins"`
+	// AllowMethods defaults to GET, HEAD and POST
+	AllowMethods []string `json:"allowMethods"`
+	// AllowHeaders defaults to the headers asked for by the preflight request
+	AllowHeaders     []string `json:"allowHeaders"`
+	ExposeHeaders    []string `json:"exposeHeaders"`
+	AllowCredentials bool     `json:"allowCredentials"`
+	// MaxAge is the number of seconds browsers may cache a preflight response
+	MaxAge int64 `json:"maxAge"`
+}
+
+// RateLimitConfig limits requests to the _admin routes, a rate of 0 disables the limit
+type RateLimitConfig struct {
+	// PerIp is the number of requests per second a client ip may send, with bursts of up to PerIpBurst
+	PerIp      int64 `json:"perIp"`
+	PerIpBurst int64 `json:"perIpBurst"`
+	// PerToken is the number of requests per second each token may send, with bursts of up to PerTokenBurst
+	PerToken      int64 `json:"perToken"`
+	PerTokenBurst int64 `json:"perTokenBurst"`
+}
+
+// BandwidthConfig caps public downloads in bytes per second, 0 is unlimited
+type BandwidthConfig struct {
+	PerConnection int64 `json:"perConnection"`
+	Global        int64 `json:"global"`
+	// Burst is the number of bytes sent at full speed before a cap applies, defaults to one second worth
+	Burst int64 `json:"burst"`
+}
+
+const (
+	LogFormatText     = "text"
+	LogFormatJson     = "json"
+	LogFormatCombined = "combined"
+)
+
+type LogConfig struct {
+	// Dir holds the app log, one file per day named like 2006-01-02.log
+	Dir string `json:"dir"`
+	// MaxAge is the number of days app log files are kept
+	MaxAge int `json:"maxAge"`
+	// Format of the app log, text or json
+	Format string `json:"format"`
+	// AccessFormat of accessLog and adminAccessLog, text, json or combined
+	AccessFormat string `json:"accessFormat"`
+}
+
+type ProxyConfig struct {
+	// Upstream is the origin url missing files are fetched from, "" disables the proxy cache
+	Upstream string `json:"upstream"`
+	// Ttl is the number of seconds a fetched file is served without refetching, 0 keeps it forever
+	Ttl int64 `json:"ttl"`
+	// NegativeTtl is the number of seconds a 404 from the upstream is remembered
+	NegativeTtl int64 `json:"negativeTtl"`
+	// MaxSize is the total size in bytes of fetched files the monitor keeps, least recently used first out
+	MaxSize int64 `json:"maxSize"`
+	Timeout int64 `json:"timeout"`
+}
+
+type TlsConfig struct {
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// ClientCaFile enables mutual TLS for the _admin routes
+	ClientCaFile string `json:"clientCaFile"`
+	DisableHttp2 bool   `json:"disableHttp2"`
+}
+
+type ReplicationConfig struct {
+	Replicas []ReplicaConfig `json:"replicas"`
+	// Interval is the number of seconds between two runs of the outbound queue
+	Interval int64 `json:"interval"`
+	// MaxRetries moves an event to the failed queue after this many attempts, 0 retries forever
+	MaxRetries int `json:"maxRetries"`
+}
+
+type ReplicaConfig struct {
+	Name  string `json:"name"`
+	Url   string `json:"url"`
+	Token string `json:"token"`
 }
@@ -0,0 +1,81 @@
+package defs
+
+// health statuses, from best to worst
+const (
+	HealthOk   = "ok"
+	HealthWarn = "warn"
+	HealthFail = "fail"
+)
+
+// HealthConfig sets when _admin/health and _admin/ready report a problem
+type HealthConfig struct {
+	// MinFreeSpace is the number of free bytes dataDir and tempDir need, below it the server is not ready
+	MinFreeSpace int64 `json:"minFreeSpace"`
+	// MinFreeInodes is the number of free inodes they need, file systems without inode counts are not checked
+	MinFreeInodes int64 `json:"minFreeInodes"`
+	// MaxBacklog is the number of queued replication or webhook events above which health warns
+	MaxBacklog int `json:"maxBacklog"`
+}
+
+type HealthReport struct {
+	Status    string       `json:"status"`
+	Version   string       `json:"version"`
+	StartedAt int64        `json:"startedAt"`
+	Uptime    int64        `json:"uptime"`
+	Platform  PlatformInfo `json:"platform"`
+	Checks    HealthChecks `json:"checks"`
+}
+
+type PlatformInfo struct {
+	Os         string `json:"os"`
+	Arch       string `json:"arch"`
+	Family     string `json:"family"`
+	Hostname   string `json:"hostname"`
+	GoVersion  string `json:"goVersion"`
+	NumCpu     int    `json:"numCpu"`
+	Goroutines int    `json:"goroutines"`
+}
+
+type HealthChecks struct {
+	DataDir     DirHealth     `json:"dataDir"`
+	TempDir     DirHealth     `json:"tempDir"`
+	Cron        CronHealth    `json:"cron"`
+	Replication BacklogHealth `json:"replication"`
+	Webhook     BacklogHealth `json:"webhook"`
+}
+
+type DirHealth struct {
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	Path        string `json:"path"`
+	Writable    bool   `json:"writable"`
+	Free        uint64 `json:"free"`
+	Total       uint64 `json:"total"`
+	InodesFree  uint64 `json:"inodesFree"`
+	InodesTotal uint64 `json:"inodesTotal"`
+}
+
+type CronHealth struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Jobs are the scheduled jobs by name with their previous and next run in unix seconds
+	Jobs map[string]CronJobHealth `json:"jobs"`
+}
+
+type CronJobHealth struct {
+	Prev int64 `json:"prev"`
+	Next int64 `json:"next"`
+}
+
+type BacklogHealth struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Backlog is the number of queued and failed events by replica or bucket name
+	Backlog map[string]map[string]int `json:"backlog"`
+}
+
+// ReadyReport is the answer of _admin/ready, Failed names the checks that keep the server from being ready
+type ReadyReport struct {
+	Ready  bool     `json:"ready"`
+	Failed []string `json:"failed"`
+}
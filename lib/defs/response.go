@@ -1,7 +1,91 @@
 package defs
 
+import "net/http"
+
 type Response struct {
-	Code int         `json:"code"`
-	Msg  string      `json:"msg"`
-	Data interface{} `json:"data"`
+	Code int `json:"code"`
+	// Error is the stable name of Code like "NOT_FOUND", empty on success
+	Error string      `json:"error,omitempty"`
+	Msg   string      `json:"msg"`
+	Data  interface{} `json:"data"`
+	// RequestId is also in the X-Request-Id header and the access log
+	RequestId string `json:"requestId,omitempty"`
+}
+
+// error codes, -1 is the code of errors without a more specific one
+const (
+	CodeFileTooLarge          = 1001
+	CodePartTooLarge          = 1002
+	CodeTooManyParts          = 1003
+	CodeTotalSizeTooLarge     = 1004
+	CodeExtensionNotAllowed   = 1005
+	CodeContentTypeNotAllowed = 1006
+	CodeTooManyRequests       = 1007
+	CodeInvalidTicket         = 1008
+	CodePathNotAllowed        = 1009
+	CodeInvalidPolicy         = 1010
+	CodePolicyExpired         = 1011
+	CodePolicyViolation       = 1012
+	CodeQuotaExceeded         = 1013
+	CodeInvalidRequest        = 1014
+	CodeInvalidToken          = 1015
+	CodeNotFound              = 1016
+	CodeUploadNotFound        = 1017
+	CodeAlreadyExists         = 1018
+	CodeDirectoryNotEmpty     = 1019
+	CodeNotADirectory         = 1020
+	CodeForbidden             = 1021
+	CodeNotConfigured         = 1022
+	CodeUnsupportedFormat     = 1023
+	CodeIncompleteUpload      = 1024
+	CodeExtractFailed         = 1025
+	CodeInternalError         = 1026
+	CodeNotReady              = 1027
+)
+
+// ErrorCode is an entry of the error code catalog, Name and Status never change for a Code
+type ErrorCode struct {
+	Code   int    `json:"code"`
+	Name   string `json:"name"`
+	Status int    `json:"status"`
+}
+
+var ErrorCodes = []ErrorCode{
+	{CodeFileTooLarge, "FILE_TOO_LARGE", http.StatusRequestEntityTooLarge},
+	{CodePartTooLarge, "PART_TOO_LARGE", http.StatusRequestEntityTooLarge},
+	{CodeTooManyParts, "TOO_MANY_PARTS", http.StatusRequestEntityTooLarge},
+	{CodeTotalSizeTooLarge, "TOTAL_SIZE_TOO_LARGE", http.StatusRequestEntityTooLarge},
+	{CodeExtensionNotAllowed, "EXTENSION_NOT_ALLOWED", http.StatusUnsupportedMediaType},
+	{CodeContentTypeNotAllowed, "CONTENT_TYPE_NOT_ALLOWED", http.StatusUnsupportedMediaType},
+	{CodeTooManyRequests, "TOO_MANY_REQUESTS", http.StatusTooManyRequests},
+	{CodeInvalidTicket, "INVALID_TICKET", http.StatusUnauthorized},
+	{CodePathNotAllowed, "PATH_NOT_ALLOWED", http.StatusForbidden},
+	{CodeInvalidPolicy, "INVALID_POLICY", http.StatusForbidden},
+	{CodePolicyExpired, "POLICY_EXPIRED", http.StatusForbidden},
+	{CodePolicyViolation, "POLICY_VIOLATION", http.StatusForbidden},
+	{CodeQuotaExceeded, "QUOTA_EXCEEDED", http.StatusRequestEntityTooLarge},
+	{CodeInvalidRequest, "INVALID_REQUEST", http.StatusBadRequest},
+	{CodeInvalidToken, "INVALID_TOKEN", http.StatusUnauthorized},
+	{CodeNotFound, "NOT_FOUND", http.StatusNotFound},
+	{CodeUploadNotFound, "UPLOAD_NOT_FOUND", http.StatusNotFound},
+	{CodeAlreadyExists, "ALREADY_EXISTS", http.StatusConflict},
+	{CodeDirectoryNotEmpty, "DIRECTORY_NOT_EMPTY", http.StatusConflict},
+	{CodeNotADirectory, "NOT_A_DIRECTORY", http.StatusConflict},
+	{CodeForbidden, "FORBIDDEN", http.StatusForbidden},
+	{CodeNotConfigured, "NOT_CONFIGURED", http.StatusNotImplemented},
+	{CodeUnsupportedFormat, "UNSUPPORTED_FORMAT", http.StatusUnsupportedMediaType},
+	{CodeIncompleteUpload, "INCOMPLETE_UPLOAD", http.StatusConflict},
+	{CodeExtractFailed, "EXTRACT_FAILED", http.StatusUnprocessableEntity},
+	{CodeInternalError, "INTERNAL_ERROR", http.StatusInternalServerError},
+	{CodeNotReady, "NOT_READY", http.StatusServiceUnavailable},
+}
+
+// LookupErrorCode returns the catalog entry of code, unknown codes like -1 are a generic 400 "ERROR"
+func LookupErrorCode(code int) ErrorCode {
+	for _, errorCode := range ErrorCodes {
+		if errorCode.Code == code {
+			return errorCode
+		}
+	}
+	return ErrorCode{Code: code, Name: "ERROR", Status: http.StatusBadRequest}
 }
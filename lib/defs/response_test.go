@@ -0,0 +1,23 @@
+package defs
+
+import "testing"
+
+func TestErrorCodes(t *testing.T) {
+	codes := map[int]bool{}
+	names := map[string]bool{}
+	for _, errorCode := range ErrorCodes {
+		if codes[errorCode.Code] || names[errorCode.Name] {
+			t.Errorf("%d %s is in the catalog twice", errorCode.Code, errorCode.Name)
+		}
+		codes[errorCode.Code], names[errorCode.Name] = true, true
+		if errorCode.Status < 400 || errorCode.Status > 599 {
+			t.Errorf("%s has status %d", errorCode.Name, errorCode.Status)
+		}
+	}
+	if got := LookupErrorCode(CodeNotFound); got.Name != "NOT_FOUND" || got.Status != 404 {
+		t.Errorf("LookupErrorCode(CodeNotFound) = %+v", got)
+	}
+	if got := LookupErrorCode(-1); got.Code != -1 || got.Name != "ERROR" || got.Status != 400 {
+		t.Errorf("LookupErrorCode(-1) = %+v, want a generic 400", got)
+	}
+}
@@ -1,8 +1,37 @@
 package errors
 
+// BusinessError is an error answered to the client with Code from the catalog in defs, see response.GenerateBusinessError
 type BusinessError struct {
-	Msg    string
+	Code int
+	Msg  string
+	// Detail is sent as the data of the response
 	Detail interface{}
-	Map    map[string]interface{}
-	Err    error
+	// Map holds extra fields for the log line
+	Map map[string]interface{}
+	// Err is the cause, it is logged but never sent to the client
+	Err error
+}
+
+func New(code int, msg string) *BusinessError {
+	return &BusinessError{Code: code, Msg: msg}
+}
+
+func Wrap(code int, msg string, err error) *BusinessError {
+	return &BusinessError{Code: code, Msg: msg, Err: err}
+}
+
+func (e *BusinessError) WithDetail(detail interface{}) *BusinessError {
+	e.Detail = detail
+	return e
+}
+
+func (e *BusinessError) Error() string {
+	if e.Err != nil {
+		return e.Msg + ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+func (e *BusinessError) Unwrap() error {
+	return e.Err
 }
@@ -0,0 +1,148 @@
+package files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ArchiveFormatZip   = "zip"
+	ArchiveFormatTar   = "tar"
+	ArchiveFormatTarGz = "tar.gz"
+)
+
+// ArchiveFormat detects the archive format from a file name, or returns ""
+func ArchiveFormat(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return ArchiveFormatZip
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return ArchiveFormatTarGz
+	case strings.HasSuffix(name, ".tar"):
+		return ArchiveFormatTar
+	}
+	return ""
+}
+
+type ArchiveWriter interface {
+	// Add writes the file or directory at path as entry name
+	Add(path string, name string, info fs.FileInfo) error
+	Close() error
+}
+
+func NewArchiveWriter(w io.Writer, format string) (ArchiveWriter, error) {
+	switch format {
+	case ArchiveFormatZip:
+		return &zipArchiveWriter{zw: zip.NewWriter(w)}, nil
+	case ArchiveFormatTar:
+		return &tarArchiveWriter{tw: tar.NewWriter(w)}, nil
+	case ArchiveFormatTarGz:
+		gw := gzip.NewWriter(w)
+		return &tarArchiveWriter{tw: tar.NewWriter(gw), gw: gw}, nil
+	}
+	return nil, errors.New("unsupported archive format: " + format)
+}
+
+type zipArchiveWriter struct {
+	zw *zip.Writer
+}
+
+func (a *zipArchiveWriter) Add(path string, name string, info fs.FileInfo) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+		_, err = a.zw.CreateHeader(header)
+		return err
+	}
+	header.Method = zip.Deflate
+	entry, err := a.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	return copyFileTo(entry, path)
+}
+
+func (a *zipArchiveWriter) Close() error {
+	return a.zw.Close()
+}
+
+type tarArchiveWriter struct {
+	tw *tar.Writer
+	gw *gzip.Writer
+}
+
+func (a *tarArchiveWriter) Add(path string, name string, info fs.FileInfo) error {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	if err := a.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return nil
+	}
+	return copyFileTo(a.tw, path)
+}
+
+func (a *tarArchiveWriter) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	if a.gw != nil {
+		return a.gw.Close()
+	}
+	return nil
+}
+
+func copyFileTo(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// AddTree walks root and adds every regular file and directory, naming entries relative to base
+func AddTree(aw ArchiveWriter, root string, base string) error {
+	return filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		return aw.Add(path, filepath.ToSlash(rel), info)
+	})
+}
+
+// WriteZip streams root as a zip archive, naming entries relative to base
+func WriteZip(w io.Writer, root string, base string) error {
+	aw, _ := NewArchiveWriter(w, ArchiveFormatZip)
+	if err := AddTree(aw, root, base); err != nil {
+		return err
+	}
+	return aw.Close()
+}
@@ -0,0 +1,234 @@
+package files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrExtractTooLarge       = errors.New("archive exceeds the maximum extracted size")
+	ErrExtractTooManyEntries = errors.New("archive exceeds the maximum number of entries")
+)
+
+const (
+	ExtractStatusOk       = "ok"
+	ExtractStatusSkipped  = "skipped"
+	ExtractStatusExists   = "exists"
+	ExtractStatusRejected = "rejected"
+	ExtractStatusError    = "error"
+)
+
+// headSize is how much of a file Check gets to see, enough to detect its content type
+const headSize = 512
+
+type ExtractOptions struct {
+	MaxSize    int64
+	MaxEntries int
+	Overwrite  bool
+	// Check is called for every regular file once it is unpacked, with its path relative to target, its size and
+	// its first bytes. An error rejects the file and keeps the one it would have replaced.
+	Check func(path string, size int64, head []byte) error
+}
+
+type ExtractResult struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	IsDir  bool   `json:"isDir"`
+	Size   int64  `json:"size"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type extractor struct {
+	target  string
+	options ExtractOptions
+	written int64
+	entries int
+	results []ExtractResult
+}
+
+// entryPath resolves an archive entry inside target, rejecting entries that would escape it
+func (e *extractor) entryPath(name string) (string, bool) {
+	name = filepath.FromSlash(strings.TrimSuffix(name, "/"))
+	if name == "" || filepath.IsAbs(name) || strings.HasPrefix(name, `\`) {
+		return "", false
+	}
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if part == ".." {
+			return "", false
+		}
+	}
+	return filepath.Join(e.target, name), true
+}
+
+func (e *extractor) add(result ExtractResult) {
+	e.results = append(e.results, result)
+}
+
+// extract writes one entry and returns a non nil error when extraction must stop
+func (e *extractor) extract(name string, isDir bool, isRegular bool, r func() (io.ReadCloser, error)) error {
+	e.entries++
+	if e.options.MaxEntries > 0 && e.entries > e.options.MaxEntries {
+		return ErrExtractTooManyEntries
+	}
+	result := ExtractResult{Name: name}
+	dest, ok := e.entryPath(name)
+	if !ok {
+		result.Status = ExtractStatusError
+		result.Error = "illegal path"
+		e.add(result)
+		return nil
+	}
+	rel, _ := filepath.Rel(e.target, dest)
+	result.Path = filepath.ToSlash(rel)
+	result.IsDir = isDir
+	if isDir {
+		if err := os.MkdirAll(dest, 0755); err != nil {
+			result.Status = ExtractStatusError
+			result.Error = err.Error()
+		} else {
+			result.Status = ExtractStatusOk
+		}
+		e.add(result)
+		return nil
+	}
+	if !isRegular {
+		result.Status = ExtractStatusSkipped
+		result.Error = "not a regular file"
+		e.add(result)
+		return nil
+	}
+	if info, err := os.Stat(dest); err == nil {
+		if info.IsDir() || !e.options.Overwrite {
+			result.Status = ExtractStatusExists
+			e.add(result)
+			return nil
+		}
+	}
+	in, err := r()
+	if err != nil {
+		result.Status = ExtractStatusError
+		result.Error = err.Error()
+		e.add(result)
+		return nil
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		result.Status = ExtractStatusError
+		result.Error = err.Error()
+		e.add(result)
+		return nil
+	}
+	// the file is unpacked next to dest and only renamed over it once it passed Check
+	out, err := os.CreateTemp(filepath.Dir(dest), ".sfs-extract-*")
+	if err != nil {
+		result.Status = ExtractStatusError
+		result.Error = err.Error()
+		e.add(result)
+		return nil
+	}
+	var src io.Reader = in
+	if e.options.MaxSize > 0 {
+		// never trust the sizes recorded in the archive headers
+		src = io.LimitReader(in, e.options.MaxSize-e.written+1)
+	}
+	head := make([]byte, headSize)
+	headLen, err := io.ReadFull(src, head)
+	head = head[:headLen]
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	var n int64
+	if err == nil {
+		n, err = io.Copy(out, io.MultiReader(bytes.NewReader(head), src))
+	}
+	out.Close()
+	e.written += n
+	if e.options.MaxSize > 0 && e.written > e.options.MaxSize {
+		os.Remove(out.Name())
+		return ErrExtractTooLarge
+	}
+	result.Size = n
+	if err == nil && e.options.Check != nil {
+		if checkErr := e.options.Check(result.Path, n, head); checkErr != nil {
+			os.Remove(out.Name())
+			result.Status = ExtractStatusRejected
+			result.Error = checkErr.Error()
+			e.add(result)
+			return nil
+		}
+	}
+	if err == nil {
+		// CreateTemp makes the file readable by its owner only
+		err = os.Chmod(out.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(out.Name(), dest)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		result.Status = ExtractStatusError
+		result.Error = err.Error()
+	} else {
+		result.Status = ExtractStatusOk
+	}
+	e.add(result)
+	return nil
+}
+
+// ExtractZip unpacks a zip archive into target, returning a result per entry
+func ExtractZip(r io.ReaderAt, size int64, target string, options ExtractOptions) ([]ExtractResult, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	e := &extractor{target: target, options: options}
+	for _, f := range zr.File {
+		mode := f.Mode()
+		err := e.extract(f.Name, mode.IsDir(), mode.IsRegular(), func() (io.ReadCloser, error) {
+			return f.Open()
+		})
+		if err != nil {
+			return e.results, err
+		}
+	}
+	return e.results, nil
+}
+
+// ExtractTar unpacks a tar archive, gzip compressed if gz is set, into target
+func ExtractTar(r io.Reader, gz bool, target string, options ExtractOptions) ([]ExtractResult, error) {
+	if gz {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	}
+	tr := tar.NewReader(r)
+	e := &extractor{target: target, options: options}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return e.results, nil
+		}
+		if err != nil {
+			return e.results, err
+		}
+		isDir := header.Typeflag == tar.TypeDir
+		isRegular := header.Typeflag == tar.TypeReg
+		err = e.extract(header.Name, isDir, isRegular, func() (io.ReadCloser, error) {
+			return io.NopCloser(tr), nil
+		})
+		if err != nil {
+			return e.results, err
+		}
+	}
+}
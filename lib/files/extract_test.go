@@ -0,0 +1,179 @@
+package files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExtractorEntryPath(t *testing.T) {
+	target := t.TempDir()
+	e := &extractor{target: target}
+	tests := []struct {
+		name   string
+		want   string
+		wantOk bool
+	}{
+		{"a.txt", filepath.Join(target, "a.txt"), true},
+		{"dir/", filepath.Join(target, "dir"), true},
+		{"dir/sub/a.txt", filepath.Join(target, "dir", "sub", "a.txt"), true},
+		{"./a.txt", filepath.Join(target, "a.txt"), true},
+		{"a..b/c", filepath.Join(target, "a..b", "c"), true},
+		{"", "", false},
+		{"/", "", false},
+		{"../a.txt", "", false},
+		{"dir/../../a.txt", "", false},
+		{"dir/../a.txt", "", false},
+		{"..", "", false},
+		{"/etc/passwd", "", false},
+		{`\windows\a.txt`, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := e.entryPath(tt.name)
+		if ok != tt.wantOk || got != tt.want {
+			t.Errorf("entryPath(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+type testEntry struct {
+	name    string
+	content string
+}
+
+func zipArchive(t *testing.T, entries ...testEntry) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		w, err := zw.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(entry.content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func statuses(results []ExtractResult) map[string]string {
+	got := map[string]string{}
+	for _, result := range results {
+		got[result.Name] = result.Status
+	}
+	return got
+}
+
+func TestExtractZip(t *testing.T) {
+	target := t.TempDir()
+	if err := os.WriteFile(filepath.Join(target, "old.txt"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := zipArchive(t,
+		testEntry{"a.txt", "a"},
+		testEntry{"dir/", ""},
+		testEntry{"dir/b.txt", "b"},
+		testEntry{"../evil.txt", "evil"},
+		testEntry{"old.txt", "new"},
+		testEntry{"bad.exe", "MZ"},
+	)
+	options := ExtractOptions{Check: func(path string, size int64, head []byte) error {
+		if path == "bad.exe" && size == 2 && string(head) == "MZ" {
+			return errors.New("rejected")
+		}
+		return nil
+	}}
+	results, err := ExtractZip(r, r.Size(), target, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"a.txt":       ExtractStatusOk,
+		"dir/":        ExtractStatusOk,
+		"dir/b.txt":   ExtractStatusOk,
+		"../evil.txt": ExtractStatusError,
+		"old.txt":     ExtractStatusExists,
+		"bad.exe":     ExtractStatusRejected,
+	}
+	if got := statuses(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	for name, content := range map[string]string{"a.txt": "a", "dir/b.txt": "b", "old.txt": "old"} {
+		data, err := os.ReadFile(filepath.Join(target, filepath.FromSlash(name)))
+		if err != nil || string(data) != content {
+			t.Errorf("%s holds %q, %v, want %q", name, data, err, content)
+		}
+	}
+	for _, name := range []string{"bad.exe", filepath.Join("..", "evil.txt")} {
+		if FileExists(filepath.Join(target, name)) {
+			t.Errorf("%s was extracted", name)
+		}
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(target, ".sfs-extract-*"))
+	if len(leftovers) > 0 {
+		t.Errorf("temporary files left behind: %q", leftovers)
+	}
+
+	// with Overwrite an existing file is replaced
+	r = zipArchive(t, testEntry{"old.txt", "new"})
+	if _, err := ExtractZip(r, r.Size(), target, ExtractOptions{Overwrite: true}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(target, "old.txt")); string(data) != "new" {
+		t.Errorf("old.txt holds %q after an overwrite", data)
+	}
+}
+
+func TestExtractLimits(t *testing.T) {
+	r := zipArchive(t, testEntry{"a.txt", "aaaa"}, testEntry{"b.txt", "bbbb"}, testEntry{"c.txt", "cccc"})
+	if _, err := ExtractZip(r, r.Size(), t.TempDir(), ExtractOptions{MaxEntries: 2}); err != ErrExtractTooManyEntries {
+		t.Errorf("MaxEntries 2 returned %v", err)
+	}
+	target := t.TempDir()
+	if _, err := ExtractZip(r, r.Size(), target, ExtractOptions{MaxSize: 10}); err != ErrExtractTooLarge {
+		t.Errorf("MaxSize 10 returned %v", err)
+	}
+	if FileExists(filepath.Join(target, "c.txt")) {
+		t.Error("the entry over MaxSize was extracted")
+	}
+	if _, err := ExtractZip(r, r.Size(), t.TempDir(), ExtractOptions{MaxSize: 12, MaxEntries: 3}); err != nil {
+		t.Errorf("an archive within the limits returned %v", err)
+	}
+}
+
+func TestExtractTarGz(t *testing.T) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, entry := range []testEntry{{"dir/a.txt", "a"}, {"/abs.txt", "abs"}} {
+		tw.WriteHeader(&tar.Header{Name: entry.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(entry.content))})
+		tw.Write([]byte(entry.content))
+	}
+	tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"})
+	tw.Close()
+	gw.Close()
+
+	target := t.TempDir()
+	results, err := ExtractTar(&buf, true, target, ExtractOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"dir/a.txt": ExtractStatusOk, "/abs.txt": ExtractStatusError, "link": ExtractStatusSkipped}
+	if got := statuses(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(target, "dir", "a.txt")); string(data) != "a" {
+		t.Errorf("dir/a.txt holds %q", data)
+	}
+	if _, err := os.Lstat(filepath.Join(target, "link")); err == nil {
+		t.Error("the symlink was extracted")
+	}
+}
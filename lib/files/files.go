@@ -2,13 +2,18 @@ package files
 
 import (
 	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	log "github.com/sirupsen/logrus"
+	"hash/crc32"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -70,6 +75,26 @@ func DeleteIfExists(path string) {
 	}
 }
 
+// PruneEmptyDirs removes dir and its empty parents, stopping at root
+func PruneEmptyDirs(dir string, root string) {
+	root = filepath.Clean(root)
+	dir = filepath.Clean(dir)
+	for dir != root {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			return
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			return
+		}
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
 func FileMd5(path string) string {
 	file, err := os.Open(path)
 	if err != nil {
@@ -83,6 +108,28 @@ func FileMd5(path string) string {
 	return hex.EncodeToString(hash.Sum(nil))
 }
 
+// FileHashes computes all supported digests of path in a single read
+func FileHashes(path string) (Hashes, error) {
+	var hashes Hashes
+	file, err := os.Open(path)
+	if err != nil {
+		return hashes, err
+	}
+	defer file.Close()
+	md5Hash := md5.New()
+	sha1Hash := sha1.New()
+	sha256Hash := sha256.New()
+	crc32cHash := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	if _, err := io.Copy(io.MultiWriter(md5Hash, sha1Hash, sha256Hash, crc32cHash), file); err != nil {
+		return hashes, err
+	}
+	hashes.Md5 = hex.EncodeToString(md5Hash.Sum(nil))
+	hashes.Sha1 = hex.EncodeToString(sha1Hash.Sum(nil))
+	hashes.Sha256 = hex.EncodeToString(sha256Hash.Sum(nil))
+	hashes.Crc32c = hex.EncodeToString(crc32cHash.Sum(nil))
+	return hashes, nil
+}
+
 func Chmod(path string, mode string, defaultMode string) error {
 	m, _ := strconv.ParseUint(defaultMode, 8, 32)
 	if mode != "" {
@@ -110,3 +157,33 @@ func ListFiles(path string) []FileItem {
 	})
 	return files
 }
+
+var mediaTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".mp4":  "video/mp4",
+	".avi":  "video/x-msvideo",
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wav",
+	".webm": "video/webm",
+	".ogg":  "audio/ogg",
+	".pdf":  "application/pdf",
+	".txt":  "text/plain",
+	".html": "text/html",
+	".css":  "text/css",
+	".js":   "application/javascript",
+	".json": "application/json",
+	".xml":  "application/xml",
+	".zip":  "application/zip",
+}
+
+// ContentType returns the media type for the extension of path, or "" if unknown
+func ContentType(path string) string {
+	return mediaTypes[strings.ToLower(filepath.Ext(path))]
+}
+
+func ETag(info fs.FileInfo) string {
+	return fmt.Sprintf("\"%x-%x\"", info.ModTime().UnixNano(), info.Size())
+}
@@ -0,0 +1,66 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPruneEmptyDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"a/b/c", "a/kept", "x/y"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "a", "kept", "f.txt"), []byte("f"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	PruneEmptyDirs(filepath.Join(root, "a", "b", "c"), root)
+	if FileExists(filepath.Join(root, "a", "b")) {
+		t.Error("empty a/b was not pruned")
+	}
+	if !FileExists(filepath.Join(root, "a", "kept", "f.txt")) {
+		t.Error("a/kept/f.txt was removed")
+	}
+
+	// root itself is never removed, even when it ends up empty
+	PruneEmptyDirs(filepath.Join(root, "x", "y"), root)
+	if FileExists(filepath.Join(root, "x")) {
+		t.Error("empty x was not pruned")
+	}
+	if !FileExists(root) {
+		t.Error("root was removed")
+	}
+
+	// directories outside root are left alone
+	outside := t.TempDir()
+	PruneEmptyDirs(outside, root)
+	if !FileExists(outside) {
+		t.Error("directory outside root was removed")
+	}
+}
+
+func TestFileHashes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "abc.txt")
+	if err := os.WriteFile(path, []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := FileHashes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Hashes{
+		Md5:    "900150983cd24fb0d6963f7d28e17f72",
+		Sha1:   "a9993e364706816aba3e25717850c26c9cd0d89d",
+		Sha256: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		Crc32c: "364b3fb7",
+	}
+	if got != want {
+		t.Errorf("FileHashes = %+v, want %+v", got, want)
+	}
+	if _, err := FileHashes(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("FileHashes of a missing file returned no error")
+	}
+}
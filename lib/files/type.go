@@ -7,3 +7,10 @@ type FileItem struct {
 	Size  int64
 	Mtime int64
 }
+
+type Hashes struct {
+	Md5    string `json:"md5"`
+	Sha1   string `json:"sha1"`
+	Sha256 string `json:"sha256"`
+	Crc32c string `json:"crc32c"`
+}
@@ -0,0 +1,84 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const dayFormat = "2006-01-02"
+
+// DailyWriter writes to dir/<date>.log, it switches to a new file at midnight and removes files older than maxAge days
+type DailyWriter struct {
+	mu     sync.Mutex
+	dir    string
+	maxAge int
+	day    string
+	file   *os.File
+}
+
+func NewDailyWriter(dir string, maxAge int) *DailyWriter {
+	return &DailyWriter{dir: dir, maxAge: maxAge}
+}
+
+func (w *DailyWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if day := time.Now().Format(dayFormat); day != w.day || w.file == nil {
+		if err := w.open(day); err != nil {
+			return 0, err
+		}
+	}
+	return w.file.Write(p)
+}
+
+func (w *DailyWriter) open(day string) error {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	if err := os.MkdirAll(w.dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(w.dir, day+".log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w.file, w.day = file, day
+	go w.removeOld()
+	return nil
+}
+
+// removeOld deletes the daily files older than maxAge days, other files in dir are left alone
+func (w *DailyWriter) removeOld() {
+	if w.maxAge <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return
+	}
+	oldest := time.Now().AddDate(0, 0, -w.maxAge).Format(dayFormat)
+	for _, entry := range entries {
+		name := entry.Name()
+		if filepath.Ext(name) != ".log" {
+			continue
+		}
+		day := name[:len(name)-len(".log")]
+		if _, err := time.Parse(dayFormat, day); err == nil && day < oldest {
+			os.Remove(filepath.Join(w.dir, name))
+		}
+	}
+}
+
+func (w *DailyWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -1,23 +1,36 @@
 package log
 
 import (
-	"fmt"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/natefinch/lumberjack.v2"
 	"io"
 	"os"
-	"time"
+	"simple-file-server/lib/defs"
 )
 
-func Init() {
+// Init sends the app log to stdout and to a daily file in config.Dir
+func Init(config defs.LogConfig) {
 	logrus.SetLevel(logrus.InfoLevel)
-	loggerWriter := &lumberjack.Logger{
-		Filename:   fmt.Sprintf("./log/%s.log", time.Now().Format("2006-01-02")),
+	if config.Format == defs.LogFormatJson {
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	}
+	// stdout first, io.MultiWriter stops at the first writer that fails
+	logrus.SetOutput(io.MultiWriter(os.Stdout, NewDailyWriter(config.Dir, config.MaxAge)))
+}
+
+// StdoutPath as accessLog or adminAccessLog writes the access log to stdout, mixed with the app log
+const StdoutPath = "stdout"
+
+// NewAccessWriter returns a rotating writer for an access log, or stdout for StdoutPath
+func NewAccessWriter(path string) io.Writer {
+	if path == StdoutPath {
+		return os.Stdout
+	}
+	return &lumberjack.Logger{
+		Filename:   path,
 		MaxSize:    500,
 		MaxBackups: 3,
 		MaxAge:     28,
 		Compress:   true,
 	}
-	multiWriter := io.MultiWriter(loggerWriter, os.Stdout)
-	logrus.SetOutput(multiWriter)
 }
@@ -0,0 +1,63 @@
+package log
+
+import (
+	"gopkg.in/natefinch/lumberjack.v2"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDailyWriter(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "log")
+	old := time.Now().AddDate(0, 0, -10).Format(dayFormat) + ".log"
+	recent := time.Now().AddDate(0, 0, -2).Format(dayFormat) + ".log"
+	os.MkdirAll(dir, 0755)
+	for _, name := range []string{old, recent, "access.log"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644)
+	}
+
+	w := NewDailyWriter(dir, 7)
+	defer w.Close()
+	if _, err := w.Write([]byte("line\n")); err != nil {
+		t.Fatal(err)
+	}
+	today := filepath.Join(dir, time.Now().Format(dayFormat)+".log")
+	if data, _ := os.ReadFile(today); string(data) != "line\n" {
+		t.Errorf("today's file holds %q", data)
+	}
+	// old files are removed in the background
+	deadline := time.Now().Add(2 * time.Second)
+	for fileExists(filepath.Join(dir, old)) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for name, want := range map[string]bool{old: false, recent: true, "access.log": true} {
+		if got := fileExists(filepath.Join(dir, name)); got != want {
+			t.Errorf("%s exists: %v, want %v", name, got, want)
+		}
+	}
+
+	// a file in the place of the directory makes writes fail
+	blocked := filepath.Join(t.TempDir(), "blocked")
+	os.WriteFile(blocked, nil, 0644)
+	if _, err := NewDailyWriter(blocked, 7).Write([]byte("line\n")); err == nil {
+		t.Error("Write below a file returned no error")
+	}
+}
+
+func TestNewAccessWriter(t *testing.T) {
+	if w := NewAccessWriter(StdoutPath); w != os.Stdout {
+		t.Errorf("NewAccessWriter(%q) = %T, want stdout", StdoutPath, w)
+	}
+	path := filepath.Join(t.TempDir(), "access.log")
+	w, ok := NewAccessWriter(path).(*lumberjack.Logger)
+	if !ok || w.Filename != path {
+		t.Fatalf("NewAccessWriter(%q) = %+v", path, w)
+	}
+	defer w.Close()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
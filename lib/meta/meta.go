@@ -0,0 +1,159 @@
+package meta
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"simple-file-server/global"
+	"simple-file-server/lib/files"
+	"strings"
+	"time"
+)
+
+// FileMeta is the sidecar metadata stored for a file in DataDir
+type FileMeta struct {
+	ContentType string `json:"contentType,omitempty"`
+
+	Hash      *files.Hashes `json:"hash,omitempty"`
+	HashSize  int64         `json:"hashSize,omitempty"`
+	HashMtime int64         `json:"hashMtime,omitempty"`
+
+	ScrubbedAt int64 `json:"scrubbedAt,omitempty"`
+	Corrupt    bool  `json:"corrupt,omitempty"`
+
+	// set for files fetched by the proxy cache
+	Proxied    bool  `json:"proxied,omitempty"`
+	CachedAt   int64 `json:"cachedAt,omitempty"`
+	AccessedAt int64 `json:"accessedAt,omitempty"`
+	// set when the upstream answered 404 for a path that does not exist locally
+	NotFoundAt int64 `json:"notFoundAt,omitempty"`
+}
+
+// HashValid reports whether the recorded hash was computed for the current content of info
+func (m FileMeta) HashValid(info fs.FileInfo) bool {
+	return m.Hash != nil && m.HashSize == info.Size() && m.HashMtime == info.ModTime().UnixNano()
+}
+
+func Dir() string {
+	return filepath.Join(global.Config().TempDir, "Meta")
+}
+
+// The sidecar of a/b is Meta/a.d/b.json: directories and sidecars get different suffixes,
+// so the sidecar of a file never takes the place of a directory holding other sidecars
+const (
+	dirSuffix  = ".d"
+	fileSuffix = ".json"
+)
+
+func metaFile(path string) string {
+	path = filepath.Clean("/" + path)
+	return filepath.Join(metaDir(filepath.Dir(path)), filepath.Base(path)+fileSuffix)
+}
+
+func metaDir(path string) string {
+	dir := Dir()
+	for _, name := range strings.Split(filepath.ToSlash(filepath.Clean("/"+path)), "/") {
+		if name != "" {
+			dir = filepath.Join(dir, name+dirSuffix)
+		}
+	}
+	return dir
+}
+
+func Get(path string) (FileMeta, bool) {
+	var m FileMeta
+	data, err := os.ReadFile(metaFile(path))
+	if err != nil {
+		return m, false
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, false
+	}
+	return m, true
+}
+
+func Save(path string, m FileMeta) error {
+	file := metaFile(path)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	data, _ := json.Marshal(m)
+	return os.WriteFile(file, data, 0644)
+}
+
+// Delete removes the metadata of path and, for directories, of everything below it
+func Delete(path string) {
+	os.Remove(metaFile(path))
+	os.RemoveAll(metaDir(path))
+	files.PruneEmptyDirs(filepath.Dir(metaFile(path)), Dir())
+}
+
+func Move(from string, to string) {
+	Delete(to)
+	if _, err := os.Stat(metaFile(from)); err == nil {
+		os.MkdirAll(filepath.Dir(metaFile(to)), 0755)
+		os.Rename(metaFile(from), metaFile(to))
+	}
+	if _, err := os.Stat(metaDir(from)); err == nil {
+		os.MkdirAll(filepath.Dir(metaDir(to)), 0755)
+		os.Rename(metaDir(from), metaDir(to))
+	}
+}
+
+// Walk calls fn for every stored metadata entry
+func Walk(fn func(path string, m FileMeta)) {
+	root := Dir()
+	filepath.Walk(root, func(file string, info fs.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(file, fileSuffix) {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, strings.TrimSuffix(file, fileSuffix))
+		names := strings.Split(filepath.ToSlash(rel), "/")
+		for i := range names[:len(names)-1] {
+			names[i] = strings.TrimSuffix(names[i], dirSuffix)
+		}
+		rel = strings.Join(names, "/")
+		if m, ok := Get(rel); ok {
+			fn(rel, m)
+		}
+		return nil
+	})
+}
+
+type HashResult struct {
+	Hashes  files.Hashes
+	Cached  bool
+	Corrupt bool
+}
+
+// Hashes returns the digests of path, computing and recording them unless a valid cached value exists.
+// With refresh the content is always re-read; if it no longer matches a hash recorded for the same
+// size and mtime the file is flagged as corrupt and the recorded hash is kept.
+func Hashes(path string, fullPath string, refresh bool) (HashResult, error) {
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return HashResult{}, err
+	}
+	m, _ := Get(path)
+	if !refresh && m.HashValid(info) {
+		return HashResult{Hashes: *m.Hash, Cached: true, Corrupt: m.Corrupt}, nil
+	}
+	hashes, err := files.FileHashes(fullPath)
+	if err != nil {
+		return HashResult{}, err
+	}
+	if m.HashValid(info) {
+		m.Corrupt = hashes != *m.Hash
+		m.ScrubbedAt = time.Now().Unix()
+	} else {
+		m.Hash = &hashes
+		m.HashSize = info.Size()
+		m.HashMtime = info.ModTime().UnixNano()
+		m.Corrupt = false
+	}
+	if err := Save(path, m); err != nil {
+		return HashResult{}, err
+	}
+	return HashResult{Hashes: hashes, Corrupt: m.Corrupt}, nil
+}
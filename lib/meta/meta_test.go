@@ -0,0 +1,146 @@
+package meta
+
+import (
+	"os"
+	"path/filepath"
+	"simple-file-server/global"
+	"simple-file-server/lib/defs"
+	"testing"
+	"time"
+)
+
+func useTempDir(t *testing.T) {
+	previous := *global.Config()
+	global.SetConfig(defs.Config{TempDir: t.TempDir()})
+	t.Cleanup(func() {
+		global.SetConfig(previous)
+	})
+}
+
+func contentType(path string) string {
+	m, _ := Get(path)
+	return m.ContentType
+}
+
+func TestSaveAndGet(t *testing.T) {
+	useTempDir(t)
+	// the sidecar of a file must not collide with the sidecars below a directory of a similar name
+	for _, path := range []string{"a", "a.json/b", "a.json", "a.d/c", "a.d", "dir/a"} {
+		if err := Save(path, FileMeta{ContentType: "text/" + path}); err != nil {
+			t.Fatalf("Save(%q): %v", path, err)
+		}
+	}
+	for _, path := range []string{"a", "a.json/b", "a.json", "a.d/c", "a.d", "dir/a"} {
+		if got := contentType(path); got != "text/"+path {
+			t.Errorf("Get(%q).ContentType = %q, want %q", path, got, "text/"+path)
+		}
+	}
+	if got := contentType("/dir//a"); got != "text/dir/a" {
+		t.Errorf("Get of an unclean path returned %q", got)
+	}
+	if _, ok := Get("missing"); ok {
+		t.Error("Get(missing) found metadata")
+	}
+}
+
+func TestDeleteAndMove(t *testing.T) {
+	useTempDir(t)
+	for _, path := range []string{"dir", "dir/a", "dir/sub/b", "dir2/c", "file"} {
+		if err := Save(path, FileMeta{ContentType: "text/" + path}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// deleting a directory removes the metadata below it too
+	Delete("dir")
+	for _, path := range []string{"dir", "dir/a", "dir/sub/b"} {
+		if _, ok := Get(path); ok {
+			t.Errorf("metadata of %s survived deleting dir", path)
+		}
+	}
+	if contentType("dir2/c") != "text/dir2/c" {
+		t.Error("deleting dir removed the metadata of dir2/c")
+	}
+
+	Move("dir2", "moved")
+	if _, ok := Get("dir2/c"); ok {
+		t.Error("metadata of dir2/c survived the move")
+	}
+	if got := contentType("moved/c"); got != "text/dir2/c" {
+		t.Errorf("metadata of moved/c is %q", got)
+	}
+
+	// moving over an existing path replaces its metadata
+	Move("file", "moved/c")
+	if got := contentType("moved/c"); got != "text/file" {
+		t.Errorf("metadata of moved/c is %q after moving file over it", got)
+	}
+}
+
+func TestHashes(t *testing.T) {
+	useTempDir(t)
+	fullPath := filepath.Join(t.TempDir(), "a.txt")
+	mtime := time.Now().Add(-time.Hour)
+	write := func(content string) {
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(fullPath, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("abc")
+
+	res, err := Hashes("a.txt", fullPath, false)
+	if err != nil || res.Cached || res.Corrupt || res.Hashes.Md5 != "900150983cd24fb0d6963f7d28e17f72" {
+		t.Fatalf("first Hashes = %+v, %v", res, err)
+	}
+	if res, _ = Hashes("a.txt", fullPath, false); !res.Cached {
+		t.Error("second Hashes was not cached")
+	}
+
+	// same size and mtime but other content is bit rot, the recorded hash is kept
+	write("abd")
+	if res, _ = Hashes("a.txt", fullPath, false); !res.Cached || res.Corrupt {
+		t.Errorf("Hashes without refresh = %+v, want the cached hash", res)
+	}
+	res, err = Hashes("a.txt", fullPath, true)
+	if err != nil || !res.Corrupt || res.Hashes.Md5 == "900150983cd24fb0d6963f7d28e17f72" {
+		t.Errorf("Hashes with refresh = %+v, %v, want corrupt", res, err)
+	}
+	if m, _ := Get("a.txt"); !m.Corrupt || m.Hash.Md5 != "900150983cd24fb0d6963f7d28e17f72" || m.ScrubbedAt == 0 {
+		t.Errorf("recorded metadata = %+v, want corrupt with the first hash", m)
+	}
+
+	// a new size is a legitimate change
+	write("abcd")
+	if res, _ = Hashes("a.txt", fullPath, true); res.Corrupt || res.Cached {
+		t.Errorf("Hashes after a change = %+v", res)
+	}
+
+	if _, err := Hashes("missing.txt", filepath.Join(t.TempDir(), "missing.txt"), false); err == nil {
+		t.Error("Hashes of a missing file returned no error")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	useTempDir(t)
+	paths := []string{"a", "a.json/b", "a.d/c", "dir/sub/d.txt"}
+	for _, path := range paths {
+		if err := Save(path, FileMeta{ContentType: "text/" + path}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := map[string]string{}
+	Walk(func(path string, m FileMeta) {
+		got[path] = m.ContentType
+	})
+	if len(got) != len(paths) {
+		t.Errorf("Walk visited %v, want %q", got, paths)
+	}
+	for _, path := range paths {
+		if got[path] != "text/"+path {
+			t.Errorf("Walk gave %q for %s", got[path], path)
+		}
+	}
+}
@@ -2,21 +2,31 @@ package response
 
 import (
 	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
 	"net/http"
 	"simple-file-server/lib/defs"
+	sfserrors "simple-file-server/lib/errors"
 )
 
 func Generate(ctx *gin.Context, code int, msg string, data interface{}) {
+	GenerateWithStatus(ctx, http.StatusOK, code, msg, data)
+}
+
+func GenerateWithStatus(ctx *gin.Context, status int, code int, msg string, data interface{}) {
 	if data == nil {
 		data = gin.H{}
 	}
 	res := defs.Response{
-		Code: code,
-		Msg:  msg,
-		Data: data,
+		Code:      code,
+		Msg:       msg,
+		Data:      data,
+		RequestId: ctx.GetString("requestId"),
+	}
+	if code != 0 {
+		res.Error = defs.LookupErrorCode(code).Name
 	}
 	ctx.Header("Transfer-Encoding", "identity")
-	ctx.JSON(http.StatusOK, res)
+	ctx.JSON(status, res)
 	ctx.Abort()
 }
 
@@ -33,5 +43,30 @@ func GenerateSuccessData(ctx *gin.Context, data interface{}) {
 }
 
 func GenerateError(ctx *gin.Context, msg string) {
-	Generate(ctx, -1, msg, nil)
+	GenerateBusinessError(ctx, sfserrors.New(-1, msg))
+}
+
+func GenerateErrorWithData(ctx *gin.Context, msg string, data interface{}) {
+	GenerateBusinessError(ctx, sfserrors.New(-1, msg).WithDetail(data))
+}
+
+func GenerateErrorCode(ctx *gin.Context, code int, msg string) {
+	GenerateBusinessError(ctx, sfserrors.New(code, msg))
+}
+
+// GenerateBusinessError answers err with the http status of its code, errors with a cause or a 5xx status are logged
+func GenerateBusinessError(ctx *gin.Context, err *sfserrors.BusinessError) {
+	errorCode := defs.LookupErrorCode(err.Code)
+	if err.Err != nil || errorCode.Status >= http.StatusInternalServerError {
+		entry := log.WithFields(log.Fields{
+			"requestId": ctx.GetString("requestId"),
+			"code":      errorCode.Name,
+			"path":      ctx.Request.URL.Path,
+		})
+		if err.Map != nil {
+			entry = entry.WithFields(err.Map)
+		}
+		entry.Error(err.Error())
+	}
+	GenerateWithStatus(ctx, errorCode.Status, err.Code, err.Msg, err.Detail)
 }
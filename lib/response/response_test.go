@@ -0,0 +1,46 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http/httptest"
+	"simple-file-server/lib/defs"
+	sfserrors "simple-file-server/lib/errors"
+	"testing"
+)
+
+func respond(generate func(c *gin.Context)) (int, defs.Response, *gin.Context) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/_admin/test", nil)
+	c.Set("requestId", "req-1")
+	generate(c)
+	var res defs.Response
+	json.Unmarshal(w.Body.Bytes(), &res)
+	return w.Code, res, c
+}
+
+func TestGenerateBusinessError(t *testing.T) {
+	status, res, c := respond(func(c *gin.Context) {
+		GenerateBusinessError(c, sfserrors.Wrap(defs.CodeNotFound, "File not found", errors.New("stat a.txt: no such file")).WithDetail(gin.H{"path": "a.txt"}))
+	})
+	if status != 404 || res.Code != defs.CodeNotFound || res.Error != "NOT_FOUND" || res.Msg != "File not found" || res.RequestId != "req-1" {
+		t.Errorf("GenerateBusinessError = %d %+v", status, res)
+	}
+	if data, _ := json.Marshal(res.Data); string(data) != `{"path":"a.txt"}` {
+		t.Errorf("detail = %s", data)
+	}
+	if !c.IsAborted() {
+		t.Error("the error response did not abort the chain")
+	}
+
+	// errors without a code of the catalog are a generic 400
+	if status, res, _ := respond(func(c *gin.Context) { GenerateError(c, "Invalid request") }); status != 400 || res.Code != -1 || res.Error != "ERROR" {
+		t.Errorf("GenerateError = %d %+v", status, res)
+	}
+	if status, res, _ := respond(func(c *gin.Context) { GenerateSuccessData(c, gin.H{"a": 1}) }); status != 200 || res.Code != 0 || res.Error != "" {
+		t.Errorf("GenerateSuccessData = %d %+v", status, res)
+	}
+}
@@ -0,0 +1,202 @@
+package throttle
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket limiting throughput to Rate bytes per second, safe for concurrent use
+type Limiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	lock   sync.Mutex
+}
+
+// NewLimiter returns a limiter allowing rate bytes per second with bursts of up to burst bytes.
+// A burst of 0 defaults to one second worth of data.
+func NewLimiter(rate int64, burst int64) *Limiter {
+	if burst <= 0 {
+		burst = rate
+	}
+	return &Limiter{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Reserve takes n tokens and returns how long the caller has to wait before using them
+func (l *Limiter) Reserve(n int) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+func (l *Limiter) WaitN(n int) {
+	if wait := l.Reserve(n); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// Allow takes n tokens if they are available right away, otherwise it takes nothing
+// and returns how long until they will be
+func (l *Limiter) Allow(n int) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	if l.tokens >= float64(n) {
+		l.tokens -= float64(n)
+		return true, 0
+	}
+	return false, time.Duration((float64(n) - l.tokens) / l.rate * float64(time.Second))
+}
+
+// idle tells whether the limiter has been refilled completely, so dropping it changes nothing
+func (l *Limiter) idle(now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.tokens+now.Sub(l.last).Seconds()*l.rate >= l.burst
+}
+
+// Group hands out one limiter per key, like a client ip or a token name
+type Group struct {
+	rate      int64
+	burst     int64
+	limiters  map[string]*Limiter
+	lastPrune time.Time
+	lock      sync.Mutex
+}
+
+func NewGroup(rate int64, burst int64) *Group {
+	return &Group{
+		rate:      rate,
+		burst:     burst,
+		limiters:  map[string]*Limiter{},
+		lastPrune: time.Now(),
+	}
+}
+
+func (g *Group) Get(key string) *Limiter {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	now := time.Now()
+	// full limiters behave exactly like new ones, so they are dropped once a minute to bound memory
+	if now.Sub(g.lastPrune) > time.Minute {
+		for k, l := range g.limiters {
+			if l.idle(now) {
+				delete(g.limiters, k)
+			}
+		}
+		g.lastPrune = now
+	}
+	l, ok := g.limiters[key]
+	if !ok {
+		l = NewLimiter(g.rate, g.burst)
+		g.limiters[key] = l
+	}
+	return l
+}
+
+// chunk is the largest amount of data passed through at once so waits stay short
+func (l *Limiter) chunk() int {
+	c := int(l.burst)
+	if c > 32*1024 {
+		c = 32 * 1024
+	}
+	if c < 1 {
+		c = 1
+	}
+	return c
+}
+
+type reader struct {
+	r        io.Reader
+	limiters []*Limiter
+}
+
+// Reader limits reads from r by every non nil limiter
+func Reader(r io.Reader, limiters ...*Limiter) io.Reader {
+	active := compact(limiters)
+	if len(active) == 0 {
+		return r
+	}
+	return &reader{r: r, limiters: active}
+}
+
+func (t *reader) Read(p []byte) (int, error) {
+	for _, l := range t.limiters {
+		if c := l.chunk(); len(p) > c {
+			p = p[:c]
+		}
+	}
+	n, err := t.r.Read(p)
+	for _, l := range t.limiters {
+		l.WaitN(n)
+	}
+	return n, err
+}
+
+type writer struct {
+	w        io.Writer
+	limiters []*Limiter
+}
+
+// Writer limits writes to w by every non nil limiter
+func Writer(w io.Writer, limiters ...*Limiter) io.Writer {
+	active := compact(limiters)
+	if len(active) == 0 {
+		return w
+	}
+	return &writer{w: w, limiters: active}
+}
+
+func (t *writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		size := len(p)
+		for _, l := range t.limiters {
+			if c := l.chunk(); size > c {
+				size = c
+			}
+		}
+		for _, l := range t.limiters {
+			l.WaitN(size)
+		}
+		n, err := t.w.Write(p[:size])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[size:]
+	}
+	return written, nil
+}
+
+func compact(limiters []*Limiter) []*Limiter {
+	var active []*Limiter
+	for _, l := range limiters {
+		if l != nil {
+			active = append(active, l)
+		}
+	}
+	return active
+}
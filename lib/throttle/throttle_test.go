@@ -0,0 +1,59 @@
+package throttle
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimiterReserve(t *testing.T) {
+	l := NewLimiter(1000, 0)
+	if wait := l.Reserve(1000); wait != 0 {
+		t.Errorf("the first burst waits %v", wait)
+	}
+	// the bucket is empty, 500 more bytes take half a second
+	wait := l.Reserve(500)
+	if wait < 400*time.Millisecond || wait > 500*time.Millisecond {
+		t.Errorf("Reserve(500) on an empty bucket waits %v", wait)
+	}
+}
+
+func TestReaderAndWriter(t *testing.T) {
+	content := strings.Repeat("x", 3000)
+	// no limiter passes the data through
+	var out bytes.Buffer
+	if _, err := io.Copy(Writer(&out, nil), Reader(strings.NewReader(content))); err != nil || out.String() != content {
+		t.Fatalf("unlimited copy = %d bytes, %v", out.Len(), err)
+	}
+
+	out.Reset()
+	start := time.Now()
+	if _, err := io.Copy(&out, Reader(strings.NewReader(content), NewLimiter(10000, 1000))); err != nil || out.String() != content {
+		t.Fatalf("limited copy = %d bytes, %v", out.Len(), err)
+	}
+	// 1000 bytes of burst and 2000 at 10000 bytes per second
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("copying 3000 bytes at 10000/s with a burst of 1000 took %v", elapsed)
+	}
+}
+
+func TestAllowAndGroup(t *testing.T) {
+	g := NewGroup(1, 2)
+	a := g.Get("10.0.0.1")
+	for i := 0; i < 2; i++ {
+		if ok, _ := a.Allow(1); !ok {
+			t.Fatalf("request %d within the burst was refused", i+1)
+		}
+	}
+	if ok, wait := a.Allow(1); ok || wait <= 0 || wait > time.Second {
+		t.Errorf("request over the burst = %v, %v, want a wait up to one second", ok, wait)
+	}
+	if g.Get("10.0.0.1") != a {
+		t.Error("the same key got a new limiter")
+	}
+	if ok, _ := g.Get("10.0.0.2").Allow(1); !ok {
+		t.Error("another key shares the exhausted limiter")
+	}
+}
@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"os"
 	"simple-file-server/command"
-	"simple-file-server/lib/config"
-	"simple-file-server/lib/log"
 )
 
 //func isRoot() bool {
@@ -29,8 +27,6 @@ func init() {
 }
 
 func main() {
-	log.Init()
-	config.Init()
 	if err := command.RootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
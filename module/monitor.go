@@ -6,7 +6,10 @@ import (
 	"github.com/robfig/cron/v3"
 	log "github.com/sirupsen/logrus"
 	"simple-file-server/global"
+	"simple-file-server/lib/bucket"
 	"simple-file-server/lib/files"
+	"simple-file-server/lib/meta"
+	"strings"
 	"time"
 )
 
@@ -17,9 +20,10 @@ type MonitorService struct {
 
 func (m *MonitorService) Run() {
 	log.Info("MonitorService Run")
-	fileList := files.ListFiles(global.CONFIG.TempDir)
+	keepDirs := []string{meta.Dir(), ReplicationDir(), WebhookDir(), bucket.Dir()}
+	fileList := files.ListFiles(global.Config().TempDir)
 	for _, file := range fileList {
-		if file.IsDir {
+		if file.IsDir || hasAnyPrefix(file.Path, keepDirs) {
 			continue
 		}
 		if file.Mtime < time.Now().Unix()-3600*24*30 {
@@ -28,7 +32,7 @@ func (m *MonitorService) Run() {
 		}
 	}
 	// Clean MultiPart dirs older than 24 hours
-	multiPartDir := global.CONFIG.TempDir + "/MultiPart"
+	multiPartDir := global.Config().TempDir + "/MultiPart"
 	if files.FileExists(multiPartDir) {
 		fileList = files.ListFiles(multiPartDir)
 		for _, file := range fileList {
@@ -38,6 +42,18 @@ func (m *MonitorService) Run() {
 			}
 		}
 	}
+	if global.Config().Proxy.Upstream != "" {
+		CleanProxyCache()
+	}
+}
+
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
 func StartMonitor(removeBefore bool, interval int64) error {
@@ -0,0 +1,60 @@
+package module
+
+import (
+	log "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"simple-file-server/global"
+	"simple-file-server/lib/files"
+	"simple-file-server/lib/meta"
+	"sort"
+	"time"
+)
+
+type proxiedFile struct {
+	path       string
+	size       int64
+	accessedAt int64
+}
+
+// CleanProxyCache drops expired negative entries and evicts the least recently used
+// proxied files until they fit in the configured maximum size
+func CleanProxyCache() {
+	config := global.Config().Proxy
+	now := time.Now().Unix()
+	var cached []proxiedFile
+	var total int64
+	meta.Walk(func(path string, m meta.FileMeta) {
+		fullPath := filepath.Join(global.Config().DataDir, filepath.FromSlash(path))
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			if m.NotFoundAt == 0 || now-m.NotFoundAt >= config.NegativeTtl {
+				meta.Delete(path)
+			}
+			return
+		}
+		if m.Proxied && !info.IsDir() {
+			cached = append(cached, proxiedFile{path: path, size: info.Size(), accessedAt: m.AccessedAt})
+			total += info.Size()
+		}
+	})
+	if config.MaxSize <= 0 || total <= config.MaxSize {
+		return
+	}
+	sort.Slice(cached, func(i, j int) bool {
+		return cached[i].accessedAt < cached[j].accessedAt
+	})
+	for _, file := range cached {
+		if total <= config.MaxSize {
+			break
+		}
+		fullPath := filepath.Join(global.Config().DataDir, filepath.FromSlash(file.path))
+		if err := os.Remove(fullPath); err != nil {
+			continue
+		}
+		log.Info("EvictProxyFile:" + file.path)
+		meta.Delete(file.path)
+		files.PruneEmptyDirs(filepath.Dir(fullPath), global.Config().DataDir)
+		total -= file.size
+	}
+}
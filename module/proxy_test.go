@@ -0,0 +1,45 @@
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"simple-file-server/lib/defs"
+	"simple-file-server/lib/meta"
+	"testing"
+	"time"
+)
+
+func TestCleanProxyCache(t *testing.T) {
+	root := t.TempDir()
+	useConfig(t, defs.Config{DataDir: root, TempDir: t.TempDir(), Proxy: defs.ProxyConfig{MaxSize: 9, NegativeTtl: 60}})
+	now := time.Now().Unix()
+	for name, accessedAt := range map[string]int64{"old.txt": now - 300, "mid.txt": now - 200, "dir/new.txt": now - 100} {
+		fullPath := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(fullPath), 0755)
+		if err := os.WriteFile(fullPath, []byte("12345"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		meta.Save(name, meta.FileMeta{Proxied: true, CachedAt: accessedAt, AccessedAt: accessedAt})
+	}
+	// an uploaded file is never evicted
+	os.WriteFile(filepath.Join(root, "uploaded.txt"), []byte("1234567890"), 0644)
+	meta.Save("uploaded.txt", meta.FileMeta{ContentType: "text/plain"})
+	meta.Save("gone.txt", meta.FileMeta{NotFoundAt: now - 120})
+	meta.Save("recent.txt", meta.FileMeta{NotFoundAt: now - 10})
+
+	CleanProxyCache()
+	for name, want := range map[string]bool{"old.txt": false, "mid.txt": false, "dir/new.txt": true, "uploaded.txt": true} {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(name))); (err == nil) != want {
+			t.Errorf("%s exists: %v, want %v", name, err == nil, want)
+		}
+	}
+	if _, ok := meta.Get("old.txt"); ok {
+		t.Error("the metadata of the evicted old.txt was kept")
+	}
+	if _, ok := meta.Get("gone.txt"); ok {
+		t.Error("the expired 404 of gone.txt was kept")
+	}
+	if _, ok := meta.Get("recent.txt"); !ok {
+		t.Error("the 404 of recent.txt was dropped before negativeTtl")
+	}
+}
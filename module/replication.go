@@ -0,0 +1,224 @@
+package module
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"simple-file-server/global"
+	"simple-file-server/lib/client"
+	"simple-file-server/lib/common"
+	"simple-file-server/lib/defs"
+	"simple-file-server/lib/meta"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ReplicateUpload = "upload"
+	ReplicateMkdir  = "mkdir"
+	ReplicateMove   = "move"
+	ReplicateDelete = "delete"
+)
+
+type ReplicationEvent struct {
+	Op       string `json:"op"`
+	Path     string `json:"path"`
+	To       string `json:"to,omitempty"`
+	Time     int64  `json:"time"`
+	Attempts int    `json:"attempts"`
+	NextAt   int64  `json:"nextAt"`
+	Error    string `json:"error,omitempty"`
+}
+
+func ReplicationDir() string {
+	return filepath.Join(global.Config().TempDir, "Replication")
+}
+
+func replicaQueueDir(name string) string {
+	return filepath.Join(ReplicationDir(), name, "queue")
+}
+
+func replicaFailedDir(name string) string {
+	return filepath.Join(ReplicationDir(), name, "failed")
+}
+
+// Replicate queues event for every configured replica, it is a no-op on servers without replicas
+func Replicate(event ReplicationEvent) {
+	event.Time = time.Now().Unix()
+	for _, replica := range global.Config().Replication.Replicas {
+		if err := writeReplicationEvent(replicaQueueDir(replica.Name), event); err != nil {
+			log.Error("ReplicateEnqueue:", replica.Name, " ", err)
+		}
+	}
+}
+
+func writeReplicationEvent(dir string, event ReplicationEvent) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%020d-%s.json", time.Now().UnixNano(), common.RandomString(6))
+	data, _ := json.Marshal(event)
+	// write then rename so the worker never reads a partial event
+	tmpFile := filepath.Join(dir, name+".tmp")
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, filepath.Join(dir, name))
+}
+
+func queuedEvents(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReplicationBacklog returns the number of queued and failed events per replica
+func ReplicationBacklog() map[string]map[string]int {
+	backlog := map[string]map[string]int{}
+	for _, replica := range global.Config().Replication.Replicas {
+		backlog[replica.Name] = map[string]int{
+			"queued": len(queuedEvents(replicaQueueDir(replica.Name))),
+			"failed": len(queuedEvents(replicaFailedDir(replica.Name))),
+		}
+	}
+	return backlog
+}
+
+// ReplicationService drains the outbound queue of every replica in order
+type ReplicationService struct {
+}
+
+func (r *ReplicationService) Run() {
+	for _, replica := range global.Config().Replication.Replicas {
+		r.runReplica(replica)
+	}
+}
+
+func (r *ReplicationService) runReplica(replica defs.ReplicaConfig) {
+	c := client.New(replica.Url, replica.Token)
+	dir := replicaQueueDir(replica.Name)
+	for _, name := range queuedEvents(dir) {
+		file := filepath.Join(dir, name)
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var event ReplicationEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			log.Error("ReplicateInvalidEvent:", file)
+			os.Remove(file)
+			continue
+		}
+		if event.NextAt > time.Now().Unix() {
+			// keep the order of events, later events wait for this one
+			return
+		}
+		err = applyReplicationEvent(c, event)
+		if err == nil {
+			os.Remove(file)
+			continue
+		}
+		event.Attempts++
+		event.Error = err.Error()
+		maxRetries := global.Config().Replication.MaxRetries
+		if maxRetries > 0 && event.Attempts >= maxRetries {
+			log.Errorf("ReplicateFailed:%s %s %s %s", replica.Name, event.Op, event.Path, err)
+			if err := writeReplicationEvent(replicaFailedDir(replica.Name), event); err == nil {
+				os.Remove(file)
+			}
+			continue
+		}
+		backoff := int64(1) << event.Attempts
+		if backoff > 300 {
+			backoff = 300
+		}
+		event.NextAt = time.Now().Unix() + backoff
+		log.Warnf("ReplicateRetry:%s %s %s attempt %d, %s", replica.Name, event.Op, event.Path, event.Attempts, err)
+		data, _ = json.Marshal(event)
+		os.WriteFile(file, data, 0644)
+		return
+	}
+}
+
+func applyReplicationEvent(c *client.Client, event ReplicationEvent) error {
+	switch event.Op {
+	case ReplicateUpload:
+		return replicateUpload(c, event.Path)
+	case ReplicateMkdir:
+		return c.Mkdir(event.Path)
+	case ReplicateMove:
+		err := c.Move(event.Path, event.To)
+		if client.IsNotFound(err) {
+			return replicateUpload(c, event.To)
+		}
+		return err
+	case ReplicateDelete:
+		err := c.Delete(event.Path, true)
+		if client.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	return errors.New("unknown replication op: " + event.Op)
+}
+
+// replicateUpload sends the current local content of rel, which may be a file or a directory
+func replicateUpload(c *client.Client, rel string) error {
+	root := filepath.Clean(global.Config().DataDir)
+	fullPath := filepath.Join(root, filepath.FromSlash(rel))
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		// removed since, a later delete event takes care of the replica
+		return nil
+	}
+	if !info.IsDir() {
+		return replicateFile(c, rel, fullPath)
+	}
+	return filepath.Walk(fullPath, func(p string, info fs.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		r, _ := filepath.Rel(root, p)
+		return replicateFile(c, filepath.ToSlash(r), p)
+	})
+}
+
+func replicateFile(c *client.Client, rel string, fullPath string) error {
+	options := client.DefaultUploadOptions()
+	options.KeepMtime = true
+	if m, ok := meta.Get(rel); ok {
+		options.ContentType = m.ContentType
+	}
+	return c.UploadFile(fullPath, path.Clean(rel), options)
+}
+
+func StartReplication(removeBefore bool, interval int64) error {
+	if removeBefore && global.CronIDReplication != 0 {
+		global.CRON.Remove(global.CronIDReplication)
+		global.CronIDReplication = 0
+	}
+	if len(global.Config().Replication.Replicas) == 0 {
+		return nil
+	}
+	replicationID, err := global.CRON.AddJob(fmt.Sprintf("@every %ds", interval), &ReplicationService{})
+	if err != nil {
+		return err
+	}
+	global.CronIDReplication = replicationID
+	return nil
+}
@@ -0,0 +1,131 @@
+package module
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"simple-file-server/lib/defs"
+	"sync"
+	"testing"
+)
+
+// fakeReplica records the admin calls it gets, it fails every mkdir while failMkdir is set
+type fakeReplica struct {
+	sync.Mutex
+	calls     []string
+	failMkdir bool
+}
+
+func (r *fakeReplica) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.Lock()
+	defer r.Unlock()
+	call := req.URL.Path
+	if req.URL.Path == "/_admin/upload" {
+		file, _, _ := req.FormFile("file")
+		content, _ := io.ReadAll(file)
+		call += " " + req.FormValue("filePath") + " " + string(content)
+	} else {
+		var body map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&body)
+		for _, key := range []string{"path", "from", "to"} {
+			if value, ok := body[key]; ok {
+				call += " " + value.(string)
+			}
+		}
+	}
+	r.calls = append(r.calls, call)
+	if req.URL.Path == "/_admin/mkdir" && r.failMkdir {
+		w.WriteHeader(500)
+		w.Write([]byte(`{"code": 1018, "msg": "Failed to create directory"}`))
+		return
+	}
+	w.Write([]byte(`{"code": 0, "msg": "ok", "data": {}}`))
+}
+
+func useReplica(t *testing.T, replica *fakeReplica, maxRetries int) string {
+	server := httptest.NewServer(replica)
+	t.Cleanup(server.Close)
+	root := t.TempDir()
+	useConfig(t, defs.Config{
+		DataDir: root,
+		TempDir: t.TempDir(),
+		Replication: defs.ReplicationConfig{
+			Replicas:   []defs.ReplicaConfig{{Name: "r1", Url: server.URL, Token: "replica-token"}},
+			MaxRetries: maxRetries,
+		},
+	})
+	return root
+}
+
+func TestReplication(t *testing.T) {
+	replica := &fakeReplica{}
+	root := useReplica(t, replica, 0)
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("aaa"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	Replicate(ReplicationEvent{Op: ReplicateUpload, Path: "a.txt"})
+	Replicate(ReplicationEvent{Op: ReplicateMkdir, Path: "dir"})
+	Replicate(ReplicationEvent{Op: ReplicateMove, Path: "a.txt", To: "dir/a.txt"})
+	Replicate(ReplicationEvent{Op: ReplicateDelete, Path: "dir"})
+	if got := ReplicationBacklog()["r1"]; got["queued"] != 4 || got["failed"] != 0 {
+		t.Errorf("backlog before the run = %v", got)
+	}
+
+	(&ReplicationService{}).Run()
+	want := []string{
+		"/_admin/upload a.txt aaa",
+		"/_admin/mkdir dir",
+		"/_admin/move a.txt dir/a.txt",
+		"/_admin/delete dir",
+	}
+	if !reflect.DeepEqual(replica.calls, want) {
+		t.Errorf("replica got %q, want %q", replica.calls, want)
+	}
+	if got := ReplicationBacklog()["r1"]; got["queued"] != 0 {
+		t.Errorf("backlog after the run = %v", got)
+	}
+}
+
+func TestReplicationFailure(t *testing.T) {
+	replica := &fakeReplica{failMkdir: true}
+	useReplica(t, replica, 2)
+	Replicate(ReplicationEvent{Op: ReplicateMkdir, Path: "dir"})
+	Replicate(ReplicationEvent{Op: ReplicateDelete, Path: "other"})
+
+	// a failed event is retried later and holds back the events behind it
+	(&ReplicationService{}).Run()
+	if !reflect.DeepEqual(replica.calls, []string{"/_admin/mkdir dir"}) {
+		t.Errorf("replica got %q", replica.calls)
+	}
+	if got := ReplicationBacklog()["r1"]; got["queued"] != 2 || got["failed"] != 0 {
+		t.Errorf("backlog after the first failure = %v", got)
+	}
+	(&ReplicationService{}).Run()
+	if len(replica.calls) != 1 {
+		t.Errorf("the event was retried before its backoff: %q", replica.calls)
+	}
+
+	// after maxRetries attempts the event moves to the failed queue
+	dir := replicaQueueDir("r1")
+	name := queuedEvents(dir)[0]
+	data, _ := os.ReadFile(filepath.Join(dir, name))
+	var event ReplicationEvent
+	json.Unmarshal(data, &event)
+	if event.Attempts != 1 || event.Error == "" {
+		t.Errorf("queued event after a failure = %+v", event)
+	}
+	event.NextAt = 0
+	data, _ = json.Marshal(event)
+	os.WriteFile(filepath.Join(dir, name), data, 0644)
+	(&ReplicationService{}).Run()
+	if got := ReplicationBacklog()["r1"]; got["queued"] != 0 || got["failed"] != 1 {
+		t.Errorf("backlog after giving up = %v", got)
+	}
+	if want := []string{"/_admin/mkdir dir", "/_admin/mkdir dir", "/_admin/delete other"}; !reflect.DeepEqual(replica.calls, want) {
+		t.Errorf("replica got %q, want %q", replica.calls, want)
+	}
+}
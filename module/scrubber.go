@@ -0,0 +1,84 @@
+package module
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/fs"
+	"path/filepath"
+	"simple-file-server/global"
+	"simple-file-server/lib/meta"
+	"sync"
+	"time"
+)
+
+type ScrubReport struct {
+	StartedAt  int64    `json:"startedAt"`
+	FinishedAt int64    `json:"finishedAt"`
+	Checked    int      `json:"checked"`
+	Failed     int      `json:"failed"`
+	Corrupt    []string `json:"corrupt"`
+}
+
+var (
+	scrubReport     ScrubReport
+	scrubReportLock sync.Mutex
+)
+
+func LastScrubReport() ScrubReport {
+	scrubReportLock.Lock()
+	defer scrubReportLock.Unlock()
+	return scrubReport
+}
+
+// ScrubberService re-hashes every stored file and flags files whose content no longer matches their recorded hash
+type ScrubberService struct {
+}
+
+func (s *ScrubberService) Run() {
+	log.Info("ScrubberService Run")
+	report := ScrubReport{
+		StartedAt: time.Now().Unix(),
+		Corrupt:   []string{},
+	}
+	root := filepath.Clean(global.Config().DataDir)
+	filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		res, err := meta.Hashes(rel, path, true)
+		if err != nil {
+			log.Warn("ScrubFailed:", rel, " ", err)
+			report.Failed++
+			return nil
+		}
+		report.Checked++
+		if res.Corrupt {
+			log.Warn("ScrubCorrupt:" + rel)
+			report.Corrupt = append(report.Corrupt, rel)
+		}
+		return nil
+	})
+	report.FinishedAt = time.Now().Unix()
+	log.Infof("ScrubberService Done, checked %d, failed %d, corrupt %d", report.Checked, report.Failed, len(report.Corrupt))
+	scrubReportLock.Lock()
+	scrubReport = report
+	scrubReportLock.Unlock()
+}
+
+func StartScrubber(removeBefore bool, interval int64) error {
+	if removeBefore && global.CronIDScrubber != 0 {
+		global.CRON.Remove(global.CronIDScrubber)
+		global.CronIDScrubber = 0
+	}
+	if interval <= 0 {
+		return nil
+	}
+	scrubberID, err := global.CRON.AddJob(fmt.Sprintf("@every %ds", interval), &ScrubberService{})
+	if err != nil {
+		return err
+	}
+	global.CronIDScrubber = scrubberID
+	return nil
+}
@@ -0,0 +1,54 @@
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"simple-file-server/global"
+	"simple-file-server/lib/defs"
+	"testing"
+	"time"
+)
+
+// useConfig publishes config for the test and restores the previous one when it ends
+func useConfig(t *testing.T, config defs.Config) {
+	previous := *global.Config()
+	global.SetConfig(config)
+	t.Cleanup(func() {
+		global.SetConfig(previous)
+	})
+}
+
+func TestScrubber(t *testing.T) {
+	root := t.TempDir()
+	useConfig(t, defs.Config{DataDir: root, TempDir: t.TempDir()})
+	mtime := time.Now().Add(-time.Hour)
+	write := func(name string, content string) {
+		fullPath := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(fullPath, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.txt", "aaa")
+	write("dir/b.txt", "bbb")
+
+	(&ScrubberService{}).Run()
+	report := LastScrubReport()
+	if report.Checked != 2 || report.Failed != 0 || len(report.Corrupt) != 0 || report.FinishedAt == 0 {
+		t.Fatalf("first report = %+v", report)
+	}
+
+	// flip the content behind the recorded hash, keeping size and mtime
+	write("dir/b.txt", "bbc")
+	(&ScrubberService{}).Run()
+	report = LastScrubReport()
+	if report.Checked != 2 || !reflect.DeepEqual(report.Corrupt, []string{"dir/b.txt"}) {
+		t.Errorf("report after corruption = %+v", report)
+	}
+}
@@ -0,0 +1,186 @@
+package module
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"os"
+	"path/filepath"
+	"simple-file-server/global"
+	"simple-file-server/lib/bucket"
+	"simple-file-server/lib/defs"
+	"strings"
+	"time"
+)
+
+// WebhookEvent is the json body posted to the webhook of a bucket, paths are relative to the bucket
+type WebhookEvent struct {
+	Bucket string `json:"bucket"`
+	Op     string `json:"op"`
+	Path   string `json:"path"`
+	To     string `json:"to,omitempty"`
+	Time   int64  `json:"time"`
+}
+
+func WebhookDir() string {
+	return filepath.Join(global.Config().TempDir, "Webhook")
+}
+
+func webhookQueueDir(name string) string {
+	return filepath.Join(WebhookDir(), name, "queue")
+}
+
+func webhookFailedDir(name string) string {
+	return filepath.Join(WebhookDir(), name, "failed")
+}
+
+// Notify queues event for the webhook of the bucket it happened in, if that bucket has one
+func Notify(event ReplicationEvent) {
+	event.Time = time.Now().Unix()
+	b, ok := bucket.ForPath(event.Path)
+	if !ok || b.Webhook.Url == "" {
+		return
+	}
+	if len(b.Webhook.Events) > 0 && !containsString(b.Webhook.Events, event.Op) {
+		return
+	}
+	if err := writeReplicationEvent(webhookQueueDir(b.Name), event); err != nil {
+		log.Error("WebhookEnqueue:", b.Name, " ", err)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookBacklog returns the number of queued and failed events per bucket with a webhook
+func WebhookBacklog() map[string]map[string]int {
+	backlog := map[string]map[string]int{}
+	for _, b := range bucket.List() {
+		if b.Webhook.Url == "" {
+			continue
+		}
+		backlog[b.Name] = map[string]int{
+			"queued": len(queuedEvents(webhookQueueDir(b.Name))),
+			"failed": len(queuedEvents(webhookFailedDir(b.Name))),
+		}
+	}
+	return backlog
+}
+
+// WebhookService drains the webhook queue of every bucket in order
+type WebhookService struct {
+}
+
+func (w *WebhookService) Run() {
+	for _, b := range bucket.List() {
+		if b.Webhook.Url != "" {
+			w.runBucket(b)
+		}
+	}
+}
+
+func (w *WebhookService) runBucket(b defs.Bucket) {
+	dir := webhookQueueDir(b.Name)
+	for _, name := range queuedEvents(dir) {
+		file := filepath.Join(dir, name)
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var event ReplicationEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			log.Error("WebhookInvalidEvent:", file)
+			os.Remove(file)
+			continue
+		}
+		if event.NextAt > time.Now().Unix() {
+			// keep the order of events, later events wait for this one
+			return
+		}
+		err = postWebhook(b, event)
+		if err == nil {
+			os.Remove(file)
+			continue
+		}
+		event.Attempts++
+		event.Error = err.Error()
+		if b.Webhook.MaxRetries > 0 && event.Attempts >= b.Webhook.MaxRetries {
+			log.Errorf("WebhookFailed:%s %s %s %s", b.Name, event.Op, event.Path, err)
+			if err := writeReplicationEvent(webhookFailedDir(b.Name), event); err == nil {
+				os.Remove(file)
+			}
+			continue
+		}
+		backoff := int64(1) << event.Attempts
+		if backoff > 300 {
+			backoff = 300
+		}
+		event.NextAt = time.Now().Unix() + backoff
+		log.Warnf("WebhookRetry:%s %s %s attempt %d, %s", b.Name, event.Op, event.Path, event.Attempts, err)
+		data, _ = json.Marshal(event)
+		os.WriteFile(file, data, 0644)
+		return
+	}
+}
+
+func postWebhook(b defs.Bucket, event ReplicationEvent) error {
+	body, _ := json.Marshal(WebhookEvent{
+		Bucket: b.Name,
+		Op:     event.Op,
+		Path:   bucketPath(b, event.Path),
+		To:     bucketPath(b, event.To),
+		Time:   event.Time,
+	})
+	req, err := http.NewRequest(http.MethodPost, b.Webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Simple-File-Server")
+	if b.Webhook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(b.Webhook.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Sfs-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	res, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", res.Status)
+	}
+	return nil
+}
+
+// bucketPath strips the bucket directory from rel
+func bucketPath(b defs.Bucket, rel string) string {
+	if rel == b.Name {
+		return ""
+	}
+	return strings.TrimPrefix(rel, b.Name+"/")
+}
+
+func StartWebhooks(removeBefore bool, interval int64) error {
+	if removeBefore && global.CronIDWebhook != 0 {
+		global.CRON.Remove(global.CronIDWebhook)
+		global.CronIDWebhook = 0
+	}
+	webhookID, err := global.CRON.AddJob(fmt.Sprintf("@every %ds", interval), &WebhookService{})
+	if err != nil {
+		return err
+	}
+	global.CronIDWebhook = webhookID
+	return nil
+}
@@ -0,0 +1,64 @@
+package module
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"simple-file-server/lib/bucket"
+	"simple-file-server/lib/defs"
+	"testing"
+)
+
+func TestWebhook(t *testing.T) {
+	var events []WebhookEvent
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		mac := hmac.New(sha256.New, []byte("webhooksecret"))
+		mac.Write(body)
+		if req.Header.Get("X-Sfs-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("invalid signature %q", req.Header.Get("X-Sfs-Signature"))
+		}
+		var event WebhookEvent
+		json.Unmarshal(body, &event)
+		events = append(events, event)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	useConfig(t, defs.Config{DataDir: t.TempDir(), TempDir: t.TempDir()})
+	webhook := defs.WebhookConfig{Url: server.URL, Secret: "webhooksecret", Events: []string{ReplicateUpload, ReplicateMove}, MaxRetries: 1}
+	if err := bucket.Save(defs.Bucket{Name: "tenant", Webhook: webhook}); err != nil {
+		t.Fatal(err)
+	}
+
+	Notify(ReplicationEvent{Op: ReplicateUpload, Path: "tenant/a.txt"})
+	// delete is not in the events of the webhook and other is no bucket
+	Notify(ReplicationEvent{Op: ReplicateDelete, Path: "tenant/a.txt"})
+	Notify(ReplicationEvent{Op: ReplicateUpload, Path: "other/a.txt"})
+	Notify(ReplicationEvent{Op: ReplicateMove, Path: "tenant/a.txt", To: "tenant/b/a.txt"})
+	(&WebhookService{}).Run()
+	var got []WebhookEvent
+	for _, event := range events {
+		got = append(got, WebhookEvent{Bucket: event.Bucket, Op: event.Op, Path: event.Path, To: event.To})
+	}
+	want := []WebhookEvent{
+		{Bucket: "tenant", Op: ReplicateUpload, Path: "a.txt"},
+		{Bucket: "tenant", Op: ReplicateMove, Path: "a.txt", To: "b/a.txt"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("posted events %+v, want %+v", got, want)
+	}
+
+	// after maxRetries failed attempts the event moves to the failed queue
+	status = http.StatusInternalServerError
+	Notify(ReplicationEvent{Op: ReplicateUpload, Path: "tenant/c.txt"})
+	(&WebhookService{}).Run()
+	if backlog := WebhookBacklog()["tenant"]; backlog["queued"] != 0 || backlog["failed"] != 1 {
+		t.Errorf("backlog after a failure = %v", backlog)
+	}
+}
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"simple-file-server/global"
+	"simple-file-server/lib/bucket"
+	"simple-file-server/lib/config"
+	"simple-file-server/lib/defs"
+	sfserrors "simple-file-server/lib/errors"
+	"simple-file-server/lib/response"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccessFileName is the per directory rule file, it applies to the directory and everything below it
+const AccessFileName = ".access"
+
+type accessFile struct {
+	mtime time.Time
+	rule  defs.AccessRule
+	err   error
+}
+
+// accessFiles caches parsed .access files until their mtime changes
+var accessFiles = struct {
+	sync.Mutex
+	m map[string]accessFile
+}{m: map[string]accessFile{}}
+
+// readAccessFile returns the rule of the .access file in dir, ok is false when there is none
+func readAccessFile(dir string) (defs.AccessRule, bool, error) {
+	file := filepath.Join(dataPath(dir), AccessFileName)
+	info, err := os.Stat(file)
+	if err != nil {
+		return defs.AccessRule{}, false, nil
+	}
+	accessFiles.Lock()
+	cached, ok := accessFiles.m[file]
+	accessFiles.Unlock()
+	if ok && cached.mtime.Equal(info.ModTime()) {
+		return cached.rule, true, cached.err
+	}
+	cached = accessFile{mtime: info.ModTime()}
+	data, err := os.ReadFile(file)
+	if err == nil {
+		err = json.Unmarshal(data, &cached.rule)
+	}
+	if err == nil {
+		err = config.ValidateAccessRule(cached.rule)
+	}
+	if err != nil {
+		cached.err = fmt.Errorf("%s: %w", file, err)
+	}
+	accessFiles.Lock()
+	accessFiles.m[file] = cached
+	accessFiles.Unlock()
+	return cached.rule, true, cached.err
+}
+
+// checkAccessFileWrite refuses to let anything but the admin token itself create, replace, move or delete an .access
+// file, a bucket token, ticket or policy could otherwise lift the rules of a directory
+func checkAccessFileWrite(c *gin.Context, rel string) *sfserrors.BusinessError {
+	if path.Base(rel) == AccessFileName && tokenName(c.GetHeader("admin-api-token")) != "admin" {
+		return sfserrors.New(defs.CodeForbidden, "Only the admin token can change "+AccessFileName+" files")
+	}
+	return nil
+}
+
+func pathDepth(rel string) int {
+	if rel == "" {
+		return 0
+	}
+	return strings.Count(rel, "/") + 1
+}
+
+// accessRule returns the rule for rel, the deepest .access file, accessRules prefix or bucket rule wins,
+// an .access file wins over a rule prefix of the same directory
+func accessRule(rel string) (defs.AccessRule, error) {
+	rule := defs.AccessRule{Mode: defs.AccessPublic}
+	depth := -1
+	for _, r := range global.Config().AccessRules {
+		prefix := relPath(r.Prefix)
+		if prefix == "" || rel == prefix || strings.HasPrefix(rel, prefix+"/") {
+			if d := pathDepth(prefix); d > depth {
+				rule, depth = r, d
+			}
+		}
+	}
+	if b, ok := bucket.ForPath(rel); ok {
+		for _, r := range b.AccessRules {
+			prefix := relPath(b.Name + "/" + r.Prefix)
+			if rel == prefix || strings.HasPrefix(rel, prefix+"/") {
+				if d := pathDepth(prefix); d > depth {
+					rule, depth = r, d
+				}
+			}
+		}
+	}
+	for dir := rel; ; dir = path.Dir(dir) {
+		if dir == "." || dir == "/" {
+			dir = ""
+		}
+		if pathDepth(dir) < depth {
+			break
+		}
+		if r, ok, err := readAccessFile(dir); ok {
+			return r, err
+		}
+		if dir == "" {
+			break
+		}
+	}
+	return rule, nil
+}
+
+// checkAccess applies the access rule of rel to a public request, it answers 401 or 403 when denied
+func checkAccess(c *gin.Context, rel string) bool {
+	rule, err := accessRule(rel)
+	if err != nil {
+		// a broken rule file must not make its directory public
+		requestLog(c).Warn("Invalid access rule, denying: ", err)
+		response.GenerateErrorCode(c, defs.CodeForbidden, "Access denied")
+		return false
+	}
+	if len(rule.AllowIps) > 0 && !ipAllowed(rule.AllowIps, c.ClientIP()) {
+		response.GenerateErrorCode(c, defs.CodeForbidden, "Ip not allowed")
+		return false
+	}
+	if len(rule.AllowReferers) > 0 && !refererAllowed(rule, c.GetHeader("Referer")) {
+		response.GenerateErrorCode(c, defs.CodeForbidden, "Referer not allowed")
+		return false
+	}
+	switch rule.Mode {
+	case defs.AccessPrivate:
+		if !validUrlSignature(rel, c.Query("expires"), c.Query("signature")) {
+			response.GenerateErrorCode(c, defs.CodeForbidden, "Invalid or expired signature")
+			return false
+		}
+	case defs.AccessToken:
+		token := c.GetHeader("access-token")
+		if token == "" {
+			token = c.Query("token")
+		}
+		if token == "" || !containsString(rule.Tokens, token) {
+			response.GenerateErrorCode(c, defs.CodeInvalidToken, "Invalid access token")
+			return false
+		}
+	}
+	return true
+}
+
+func ipAllowed(allowed []string, ip string) bool {
+	clientIp := net.ParseIP(ip)
+	if clientIp == nil {
+		return false
+	}
+	for _, item := range allowed {
+		if _, network, err := net.ParseCIDR(item); err == nil {
+			if network.Contains(clientIp) {
+				return true
+			}
+		} else if allowedIp := net.ParseIP(item); allowedIp != nil && allowedIp.Equal(clientIp) {
+			return true
+		}
+	}
+	return false
+}
+
+func refererAllowed(rule defs.AccessRule, referer string) bool {
+	if referer == "" {
+		return rule.AllowEmptyReferer
+	}
+	u, err := url.Parse(referer)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range rule.AllowReferers {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func urlSignature(secret string, rel string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(rel + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func validUrlSignature(rel string, expires string, signature string) bool {
+	secret := global.Config().SignSecret
+	if secret == "" || signature == "" {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || expiresAt < time.Now().Unix() {
+		return false
+	}
+	return hmac.Equal([]byte(urlSignature(secret, rel, expiresAt)), []byte(signature))
+}
+
+type signRequest struct {
+	Path string `json:"path"`
+	Ttl  int64  `json:"ttl"`
+}
+
+// ActionSign returns a url of a private path that is valid for ttl seconds
+func ActionSign(c *gin.Context) {
+	if !checkAdminToken(c) {
+		return
+	}
+	var req signRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	secret := global.Config().SignSecret
+	if secret == "" {
+		response.GenerateErrorCode(c, defs.CodeNotConfigured, "signSecret is not configured")
+		return
+	}
+	if req.Ttl <= 0 {
+		req.Ttl = 3600
+	}
+	rel := relPath(scopePath(c, req.Path))
+	expires := time.Now().Unix() + req.Ttl
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", urlSignature(secret, rel, expires))
+	response.GenerateSuccessData(c, gin.H{
+		"url":     (&url.URL{Path: "/" + rel, RawQuery: query.Encode()}).String(),
+		"expires": expires,
+	})
+}
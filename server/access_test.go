@@ -0,0 +1,149 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"simple-file-server/lib/bucket"
+	"simple-file-server/lib/defs"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestValidUrlSignature(t *testing.T) {
+	const secret = "sign-secret-1234567"
+	useConfig(t, defs.Config{SignSecret: secret})
+	future := time.Now().Unix() + 60
+	past := time.Now().Unix() - 60
+	valid := urlSignature(secret, "private/a.txt", future)
+	tests := []struct {
+		name      string
+		rel       string
+		expires   string
+		signature string
+		want      bool
+	}{
+		{"valid", "private/a.txt", strconv.FormatInt(future, 10), valid, true},
+		{"other path", "private/b.txt", strconv.FormatInt(future, 10), valid, false},
+		{"other expiry", "private/a.txt", strconv.FormatInt(future+1, 10), valid, false},
+		{"expired", "private/a.txt", strconv.FormatInt(past, 10), urlSignature(secret, "private/a.txt", past), false},
+		{"other secret", "private/a.txt", strconv.FormatInt(future, 10), urlSignature("other-secret-123456", "private/a.txt", future), false},
+		{"no signature", "private/a.txt", strconv.FormatInt(future, 10), "", false},
+		{"invalid expiry", "private/a.txt", "tomorrow", valid, false},
+		{"no expiry", "private/a.txt", "", valid, false},
+	}
+	for _, tt := range tests {
+		if got := validUrlSignature(tt.rel, tt.expires, tt.signature); got != tt.want {
+			t.Errorf("%s: validUrlSignature(%q, %q, %q) = %v, want %v", tt.name, tt.rel, tt.expires, tt.signature, got, tt.want)
+		}
+	}
+
+	// without a secret nothing is signed, not even a signature made with an empty key
+	useConfig(t, defs.Config{})
+	if validUrlSignature("private/a.txt", strconv.FormatInt(future, 10), urlSignature("", "private/a.txt", future)) {
+		t.Error("validUrlSignature accepted a signature without signSecret")
+	}
+}
+
+func TestAccessRule(t *testing.T) {
+	root := t.TempDir()
+	useConfig(t, defs.Config{
+		DataDir: root,
+		TempDir: t.TempDir(),
+		AccessRules: []defs.AccessRule{
+			{Prefix: "docs", Mode: defs.AccessPrivate},
+			{Prefix: "docs/public", Mode: defs.AccessPublic},
+			{Prefix: "site/open", Mode: defs.AccessPublic},
+			{Prefix: "shared", Mode: defs.AccessPrivate},
+		},
+	})
+	if err := bucket.Save(defs.Bucket{Name: "b", AccessRules: []defs.AccessRule{{Prefix: "priv", Mode: defs.AccessPrivate}}}); err != nil {
+		t.Fatal(err)
+	}
+	writeAccessFile(t, root, "docs/public/locked", `{"mode": "token", "tokens": ["t1"]}`)
+	writeAccessFile(t, root, "site", `{"mode": "private"}`)
+	writeAccessFile(t, root, "shared", `{"mode": "token", "tokens": ["t2"]}`)
+	writeAccessFile(t, root, "broken", `{"mode": "secret"}`)
+	writeAccessFile(t, root, "b/priv/open", `{"mode": "public"}`)
+	tests := []struct {
+		rel     string
+		want    string
+		wantErr bool
+	}{
+		{"other/a.txt", defs.AccessPublic, false},
+		{"docs", defs.AccessPrivate, false},
+		{"docs/a.txt", defs.AccessPrivate, false},
+		// the deepest prefix wins
+		{"docs/public/a.txt", defs.AccessPublic, false},
+		// an .access file below a prefix wins
+		{"docs/public/locked/a/b.txt", defs.AccessToken, false},
+		// a prefix below an .access file wins
+		{"site/a.txt", defs.AccessPrivate, false},
+		{"site/open/a.txt", defs.AccessPublic, false},
+		// an .access file wins over a prefix of the same directory
+		{"shared/a.txt", defs.AccessToken, false},
+		{"b/a.txt", defs.AccessPublic, false},
+		{"b/priv/a.txt", defs.AccessPrivate, false},
+		{"b/priv/open/a.txt", defs.AccessPublic, false},
+		{"broken/a.txt", "", true},
+	}
+	for _, tt := range tests {
+		rule, err := accessRule(tt.rel)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("accessRule(%q) returned no error", tt.rel)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("accessRule(%q) returned %v", tt.rel, err)
+			continue
+		}
+		if rule.Mode != tt.want {
+			t.Errorf("accessRule(%q).Mode = %q, want %q", tt.rel, rule.Mode, tt.want)
+		}
+	}
+}
+
+func writeAccessFile(t *testing.T, root string, dir string, content string) {
+	t.Helper()
+	fullPath := filepath.Join(root, filepath.FromSlash(dir))
+	if err := os.MkdirAll(fullPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(fullPath, AccessFileName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckAccessFileWrite(t *testing.T) {
+	useConfig(t, defs.Config{
+		ApiToken: "admintoken-123456",
+		Tokens:   []defs.TokenConfig{{Name: "app", Token: "apptoken-123456"}},
+	})
+	tests := []struct {
+		token string
+		rel   string
+		want  bool
+	}{
+		{"admintoken-123456", "docs/.access", true},
+		{"admintoken-123456", ".access", true},
+		{"apptoken-123456", "docs/.access", false},
+		{"", "docs/.access", false},
+		{"", ".access", false},
+		{"apptoken-123456", "docs/a.access", true},
+		{"", "docs/.access/a.txt", true},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/_admin/upload", nil)
+		if tt.token != "" {
+			c.Request.Header.Set("admin-api-token", tt.token)
+		}
+		if got := checkAccessFileWrite(c, tt.rel) == nil; got != tt.want {
+			t.Errorf("checkAccessFileWrite(%q) with token %q allowed = %v, want %v", tt.rel, tt.token, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/url"
+	"simple-file-server/global"
+	"simple-file-server/lib/common"
+	"simple-file-server/lib/defs"
+	"strings"
+	"time"
+)
+
+// RequestIdHeader carries the request id, a valid id sent by the client or a proxy is kept
+const RequestIdHeader = "X-Request-Id"
+
+// requestId gives every request an id, returned in the X-Request-Id header and written to the access log
+func requestId(c *gin.Context) {
+	id := c.GetHeader(RequestIdHeader)
+	if !validRequestId(id) {
+		id = common.SecureToken(8)
+	}
+	c.Set("requestId", id)
+	c.Header(RequestIdHeader, id)
+	c.Next()
+}
+
+func validRequestId(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// requestLog is the app logger for lines about a request, they carry its id
+func requestLog(c *gin.Context) *log.Entry {
+	return log.WithField("requestId", c.GetString("requestId"))
+}
+
+type accessEntry struct {
+	Time      string  `json:"time"`
+	RequestId string  `json:"requestId"`
+	ClientIp  string  `json:"clientIp"`
+	Method    string  `json:"method"`
+	Host      string  `json:"host"`
+	Uri       string  `json:"uri"`
+	Proto     string  `json:"proto"`
+	Status    int     `json:"status"`
+	Bytes     int     `json:"bytes"`
+	LatencyMs float64 `json:"latencyMs"`
+	TokenName string  `json:"tokenName,omitempty"`
+	Referer   string  `json:"referer,omitempty"`
+	UserAgent string  `json:"userAgent,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// accessLog writes a line per request to out in the log.accessFormat of the current config
+func accessLog(out io.Writer) gin.HandlerFunc {
+	text := gin.LoggerWithConfig(gin.LoggerConfig{Output: out, Formatter: textFormatter})
+	return func(c *gin.Context) {
+		format := global.Config().Log.AccessFormat
+		if format == "" || format == defs.LogFormatText {
+			text(c)
+			return
+		}
+		start := time.Now()
+		c.Next()
+		entry := accessEntry{
+			Time:      start.Format(time.RFC3339),
+			RequestId: c.GetString("requestId"),
+			ClientIp:  c.ClientIP(),
+			Method:    c.Request.Method,
+			Host:      c.Request.Host,
+			Uri:       loggedUri(c.Request.URL),
+			Proto:     c.Request.Proto,
+			Status:    c.Writer.Status(),
+			Bytes:     c.Writer.Size(),
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			TokenName: c.GetString("tokenName"),
+			Referer:   c.Request.Referer(),
+			UserAgent: c.Request.UserAgent(),
+			Error:     c.Errors.ByType(gin.ErrorTypePrivate).String(),
+		}
+		if entry.Bytes < 0 {
+			entry.Bytes = 0
+		}
+		if format == defs.LogFormatJson {
+			data, _ := json.Marshal(entry)
+			out.Write(append(data, '\n'))
+			return
+		}
+		fmt.Fprintf(out, "%s - %s [%s] %q %d %d %q %q %s %.3f\n",
+			entry.ClientIp, dash(entry.TokenName), start.Format("02/Jan/2006:15:04:05 -0700"),
+			entry.Method+" "+entry.Uri+" "+entry.Proto, entry.Status, entry.Bytes,
+			dash(entry.Referer), dash(entry.UserAgent), entry.RequestId, entry.LatencyMs)
+	}
+}
+
+// textFormatter is the gin default format plus the request id
+func textFormatter(param gin.LogFormatterParams) string {
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	requestId, _ := param.Keys["requestId"].(string)
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		requestId,
+		param.Method,
+		loggedUri(param.Request.URL),
+		param.ErrorMessage,
+	)
+}
+
+// loggedUri is the request uri with the values of secret query parameters replaced
+func loggedUri(u *url.URL) string {
+	query := u.Query()
+	redacted := false
+	for _, name := range []string{"token", "signature"} {
+		if query.Has(name) {
+			query.Set(name, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.RequestURI()
+	}
+	return (&url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: query.Encode()}).RequestURI()
+}
+
+func dash(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "-"
+	}
+	return s
+}
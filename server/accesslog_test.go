@@ -0,0 +1,85 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http/httptest"
+	"simple-file-server/lib/defs"
+	"strings"
+	"testing"
+)
+
+func TestValidRequestId(t *testing.T) {
+	for id, want := range map[string]bool{
+		"abc-123_X.y":           true,
+		"":                      false,
+		"has space":             false,
+		"line\nbreak":           false,
+		strings.Repeat("a", 65): false,
+	} {
+		if got := validRequestId(id); got != want {
+			t.Errorf("validRequestId(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+// logRequest sends a request through the request id and access log middleware and returns the response and the log
+func logRequest(t *testing.T, format string, requestIdHeader string) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+	useConfig(t, defs.Config{Log: defs.LogConfig{AccessFormat: format}})
+	var out bytes.Buffer
+	r := gin.New()
+	r.Use(requestId, accessLog(&out))
+	r.GET("/a.txt", func(c *gin.Context) {
+		c.String(201, "hello")
+	})
+	req := httptest.NewRequest("GET", "/a.txt?token=secret&x=1", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("User-Agent", "test-agent")
+	if requestIdHeader != "" {
+		req.Header.Set(RequestIdHeader, requestIdHeader)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w, out.String()
+}
+
+func TestAccessLog(t *testing.T) {
+	w, line := logRequest(t, defs.LogFormatJson, "client-id")
+	if w.Header().Get(RequestIdHeader) != "client-id" {
+		t.Errorf("the valid request id of the client was replaced by %q", w.Header().Get(RequestIdHeader))
+	}
+	var entry accessEntry
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		t.Fatalf("json access log %q: %v", line, err)
+	}
+	if entry.RequestId != "client-id" || entry.Status != 201 || entry.Bytes != 5 || entry.Method != "GET" ||
+		entry.ClientIp != "192.0.2.1" || entry.UserAgent != "test-agent" || entry.Uri != "/a.txt?token=REDACTED&x=1" {
+		t.Errorf("json access log = %+v", entry)
+	}
+
+	w, line = logRequest(t, defs.LogFormatCombined, "bad id")
+	id := w.Header().Get(RequestIdHeader)
+	if id == "" || id == "bad id" {
+		t.Errorf("an invalid request id was answered with %q", id)
+	}
+	if !strings.HasPrefix(line, "192.0.2.1 - - [") || !strings.Contains(line, `"GET /a.txt?token=REDACTED&x=1 HTTP/1.1" 201 5 "-" "test-agent" `+id+" ") {
+		t.Errorf("combined access log = %q", line)
+	}
+
+	_, line = logRequest(t, defs.LogFormatText, "")
+	if !strings.Contains(line, "GET") || !strings.Contains(line, "201") {
+		t.Errorf("text access log = %q", line)
+	}
+}
+
+func TestTextAccessLog(t *testing.T) {
+	w, line := logRequest(t, defs.LogFormatText, "")
+	if id := w.Header().Get(RequestIdHeader); !strings.Contains(line, " "+id+" ") {
+		t.Errorf("text access log %q lacks the request id %q", line, id)
+	}
+	if strings.Contains(line, "secret") || !strings.Contains(line, "token=REDACTED") {
+		t.Errorf("text access log %q shows the token", line)
+	}
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"simple-file-server/global"
+	"simple-file-server/lib/defs"
+	sfserrors "simple-file-server/lib/errors"
+	"simple-file-server/lib/files"
+	"simple-file-server/lib/meta"
+	"simple-file-server/lib/response"
+	"simple-file-server/module"
+	"strings"
+)
+
+type archiveRequest struct {
+	Paths  []string `json:"paths"`
+	Prefix string   `json:"prefix"`
+	Format string   `json:"format"`
+	Name   string   `json:"name"`
+}
+
+func ActionArchive(c *gin.Context) {
+	if !checkAdminToken(c) {
+		return
+	}
+	var req archiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	if len(req.Paths) == 0 && req.Prefix == "" {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "paths or prefix is required")
+		return
+	}
+	if req.Format == "" {
+		req.Format = files.ArchiveFormatZip
+	}
+	if req.Format != files.ArchiveFormatZip && req.Format != files.ArchiveFormatTarGz {
+		response.GenerateErrorCode(c, defs.CodeUnsupportedFormat, "Unsupported format")
+		return
+	}
+	if req.Name == "" {
+		req.Name = "archive"
+	}
+	var roots []string
+	for _, p := range req.Paths {
+		fullPath := dataPath(scopePath(c, p))
+		if !files.FileExists(fullPath) {
+			response.GenerateErrorCode(c, defs.CodeNotFound, "File not found: "+p)
+			return
+		}
+		roots = append(roots, fullPath)
+	}
+	c.Header("Content-Type", map[string]string{
+		files.ArchiveFormatZip:   "application/zip",
+		files.ArchiveFormatTarGz: "application/gzip",
+	}[req.Format])
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", req.Name+"."+req.Format))
+	c.Status(200)
+	aw, _ := files.NewArchiveWriter(c.Writer, req.Format)
+	base := rootPath(c)
+	for _, root := range roots {
+		if err := files.AddTree(aw, root, base); err != nil {
+			requestLog(c).Error("ActionArchive.AddTree: ", err)
+			return
+		}
+	}
+	if req.Prefix != "" {
+		if err := addPrefix(aw, archivePrefix(req.Prefix), base); err != nil {
+			requestLog(c).Error("ActionArchive.AddPrefix: ", err)
+			return
+		}
+	}
+	if err := aw.Close(); err != nil {
+		requestLog(c).Error("ActionArchive.Close: ", err)
+	}
+}
+
+// archivePrefix normalizes prefix and keeps a trailing "/", which limits the archive to that directory
+func archivePrefix(prefix string) string {
+	rel := relPath(prefix)
+	if rel != "" && strings.HasSuffix(prefix, "/") {
+		rel += "/"
+	}
+	return rel
+}
+
+// addPrefix adds every file whose path relative to base starts with prefix
+func addPrefix(aw files.ArchiveWriter, prefix string, base string) error {
+	dir := base
+	if prefix != "" {
+		dir = filepath.Join(base, filepath.FromSlash(prefix))
+		if !strings.HasSuffix(prefix, "/") {
+			dir = filepath.Dir(dir)
+		}
+	}
+	if !files.FileExists(dir) {
+		return nil
+	}
+	return filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !strings.HasPrefix(rel, prefix) {
+			return nil
+		}
+		return aw.Add(path, rel, info)
+	})
+}
+
+func ActionExtract(c *gin.Context) {
+	if !checkAdminToken(c) {
+		return
+	}
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid file")
+		return
+	}
+	defer file.Close()
+	format := c.PostForm("format")
+	if format == "" {
+		format = files.ArchiveFormat(header.Filename)
+	}
+	target := c.PostForm("target")
+	scopedTarget := scopePath(c, target)
+	targetPath := dataPath(scopedTarget)
+	if info, err := os.Stat(targetPath); err == nil && !info.IsDir() {
+		response.GenerateErrorCode(c, defs.CodeNotADirectory, "Target is not a directory")
+		return
+	}
+	options := files.ExtractOptions{
+		MaxSize:    global.Config().ExtractMaxSize,
+		MaxEntries: global.Config().ExtractMaxEntries,
+		Overwrite:  c.PostForm("overwrite") == "true" || c.PostForm("overwrite") == "1",
+		// every file has to pass the limits an upload to its path would
+		Check: func(path string, size int64, head []byte) error {
+			rel := relPath(scopedTarget + "/" + path)
+			if err := checkAccessFileWrite(c, rel); err != nil {
+				return err
+			}
+			layers := uploadLimits(c, rel)
+			if err := checkUploadSize(layers, size); err != nil {
+				return err
+			}
+			if err := checkUploadType(layers, rel, sniffBytes(head)); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+	if left := quotaLeft(relPath(scopedTarget)); left >= 0 && left < options.MaxSize {
+		if left == 0 {
+			response.GenerateBusinessError(c, sfserrors.New(defs.CodeQuotaExceeded, "Bucket quota exceeded"))
+			return
+		}
+		options.MaxSize = left
+	}
+	files.EnsureDir(targetPath, "0755")
+	var results []files.ExtractResult
+	switch format {
+	case files.ArchiveFormatZip:
+		results, err = files.ExtractZip(file, header.Size, targetPath, options)
+	case files.ArchiveFormatTar, files.ArchiveFormatTarGz:
+		results, err = files.ExtractTar(file, format == files.ArchiveFormatTarGz, targetPath, options)
+	default:
+		response.GenerateErrorCode(c, defs.CodeUnsupportedFormat, "Unsupported format")
+		return
+	}
+	for i, result := range results {
+		if result.Path != "" {
+			results[i].Path = relPath(target + "/" + result.Path)
+		}
+		if result.Status == files.ExtractStatusOk && !result.IsDir {
+			rel := relPath(scopedTarget + "/" + result.Path)
+			meta.Delete(rel)
+			addUsage(rel, result.Size)
+			emit(module.ReplicationEvent{Op: module.ReplicateUpload, Path: rel})
+		}
+	}
+	if results == nil {
+		results = []files.ExtractResult{}
+	}
+	if err != nil {
+		response.GenerateBusinessError(c, sfserrors.New(defs.CodeExtractFailed, "Extract failed: "+err.Error()).WithDetail(gin.H{
+			"entries": results,
+		}))
+		return
+	}
+	response.GenerateSuccessWithData(c, "ok", gin.H{
+		"entries": results,
+	})
+}
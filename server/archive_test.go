@@ -0,0 +1,52 @@
+package server
+
+import (
+	"io/fs"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// nameRecorder is an archive writer that only records the entry names
+type nameRecorder struct {
+	names []string
+}
+
+func (r *nameRecorder) Add(path string, name string, info fs.FileInfo) error {
+	r.names = append(r.names, name)
+	return nil
+}
+
+func (r *nameRecorder) Close() error {
+	return nil
+}
+
+func TestAddPrefix(t *testing.T) {
+	root := t.TempDir()
+	base := root + "/data"
+	writeFiles(t, root, "outside.txt", "data/photos/a.jpg", "data/photos/sub/b.jpg", "data/photos2/c.jpg", "data/photos.txt", "data/other/d.jpg")
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"photos/", []string{"photos/a.jpg", "photos/sub/b.jpg"}},
+		{"/photos//", []string{"photos/a.jpg", "photos/sub/b.jpg"}},
+		{"photos", []string{"photos.txt", "photos/a.jpg", "photos/sub/b.jpg", "photos2/c.jpg"}},
+		{"photos/sub/", []string{"photos/sub/b.jpg"}},
+		{"photos/s", []string{"photos/sub/b.jpg"}},
+		{"missing/", nil},
+		{"/", []string{"other/d.jpg", "photos.txt", "photos/a.jpg", "photos/sub/b.jpg", "photos2/c.jpg"}},
+		{"../", []string{"other/d.jpg", "photos.txt", "photos/a.jpg", "photos/sub/b.jpg", "photos2/c.jpg"}},
+	}
+	for _, tt := range tests {
+		recorder := &nameRecorder{}
+		if err := addPrefix(recorder, archivePrefix(tt.prefix), base); err != nil {
+			t.Errorf("addPrefix(%q) returned %v", tt.prefix, err)
+			continue
+		}
+		sort.Strings(recorder.names)
+		if !reflect.DeepEqual(recorder.names, tt.want) {
+			t.Errorf("addPrefix(%q) added %q, want %q", tt.prefix, recorder.names, tt.want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"simple-file-server/global"
+	"simple-file-server/lib/bucket"
+	"simple-file-server/lib/config"
+	"simple-file-server/lib/defs"
+	sfserrors "simple-file-server/lib/errors"
+	"simple-file-server/lib/files"
+	"simple-file-server/lib/meta"
+	"simple-file-server/lib/response"
+	"simple-file-server/module"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestBucket returns the bucket of a request made with a bucket token, or with a ticket or policy minted by one.
+// Bucket token names are "<bucket>/<token>".
+func requestBucket(c *gin.Context) (defs.Bucket, bool) {
+	name, _, ok := strings.Cut(c.GetString("tokenName"), "/")
+	if !ok {
+		return defs.Bucket{}, false
+	}
+	return bucket.Get(name)
+}
+
+// scopePath maps a request path of a bucket request into the bucket, other paths are returned unchanged
+func scopePath(c *gin.Context, p string) string {
+	if b, ok := requestBucket(c); ok {
+		return relPath(b.Name + "/" + relPath(p))
+	}
+	return p
+}
+
+// unscopePath turns rel back into a path relative to the bucket of a bucket request
+func unscopePath(c *gin.Context, rel string) string {
+	if b, ok := requestBucket(c); ok {
+		if rel == b.Name {
+			return ""
+		}
+		return strings.TrimPrefix(rel, b.Name+"/")
+	}
+	return rel
+}
+
+// rootPath is the directory a request can see, DataDir or the bucket directory
+func rootPath(c *gin.Context) string {
+	if b, ok := requestBucket(c); ok {
+		return dataPath(b.Name)
+	}
+	return filepath.Clean(global.Config().DataDir)
+}
+
+// publicPath is the url path of rel on the host of the request, without the bucket directory on a bucket host
+func publicPath(c *gin.Context, rel string) string {
+	if name := c.GetString("hostBucket"); name != "" {
+		if rel == name {
+			return "/"
+		}
+		return "/" + strings.TrimPrefix(rel, name+"/")
+	}
+	return "/" + rel
+}
+
+// checkServerToken refuses bucket tokens for the routes about the whole server
+func checkServerToken(c *gin.Context) bool {
+	if !checkAdminToken(c) {
+		return false
+	}
+	if _, ok := requestBucket(c); ok {
+		response.GenerateErrorCode(c, defs.CodePathNotAllowed, "Not allowed for bucket tokens")
+		return false
+	}
+	return true
+}
+
+// emit reports a change in DataDir to the replicas and the webhook of its bucket
+func emit(event module.ReplicationEvent) {
+	module.Replicate(event)
+	module.Notify(event)
+}
+
+// bucketUsages caches the size of bucket directories, walking a large bucket on every upload would be slow
+var bucketUsages = struct {
+	sync.Mutex
+	m map[string]bucketUsage
+}{m: map[string]bucketUsage{}}
+
+type bucketUsage struct {
+	size int64
+	at   time.Time
+}
+
+// usage returns the bytes in the bucket directory, at most a minute old plus what was uploaded since
+func usage(name string) int64 {
+	bucketUsages.Lock()
+	u, ok := bucketUsages.m[name]
+	bucketUsages.Unlock()
+	if ok && time.Since(u.at) < time.Minute {
+		return u.size
+	}
+	var size int64
+	filepath.WalkDir(dataPath(name), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	bucketUsages.Lock()
+	bucketUsages.m[name] = bucketUsage{size: size, at: time.Now()}
+	bucketUsages.Unlock()
+	return size
+}
+
+func addUsage(rel string, size int64) {
+	b, ok := bucket.ForPath(rel)
+	if !ok {
+		return
+	}
+	bucketUsages.Lock()
+	if u, ok := bucketUsages.m[b.Name]; ok {
+		u.size += size
+		bucketUsages.m[b.Name] = u
+	}
+	bucketUsages.Unlock()
+}
+
+// quotaLeft returns the bytes rel may still grow by, -1 when it is not in a bucket with a quota
+func quotaLeft(rel string) int64 {
+	b, ok := bucket.ForPath(rel)
+	if !ok || b.Quota == 0 {
+		return -1
+	}
+	left := b.Quota - usage(b.Name)
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// checkQuota refuses an upload of size bytes to rel that does not fit into the quota of its bucket,
+// the file it replaces is counted as freed
+func checkQuota(rel string, size int64) *sfserrors.BusinessError {
+	if info, err := os.Stat(dataPath(rel)); err == nil && !info.IsDir() {
+		size -= info.Size()
+	}
+	if left := quotaLeft(rel); left >= 0 && size > left {
+		return sfserrors.New(defs.CodeQuotaExceeded, "Bucket quota exceeded")
+	}
+	return nil
+}
+
+func ActionBucketCreate(c *gin.Context) {
+	if !checkServerToken(c) {
+		return
+	}
+	var req defs.Bucket
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	if err := config.ValidateBucket(req, *global.Config()); err != nil {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid bucket: "+err.Error())
+		return
+	}
+	old, exists := bucket.Get(req.Name)
+	for _, b := range bucket.List() {
+		if b.Name == req.Name {
+			continue
+		}
+		if req.Host != "" && strings.EqualFold(b.Host, req.Host) {
+			response.GenerateErrorCode(c, defs.CodeAlreadyExists, "Host is used by bucket "+b.Name)
+			return
+		}
+		for _, t := range b.Tokens {
+			for _, token := range req.Tokens {
+				if t.Token == token.Token {
+					response.GenerateErrorCode(c, defs.CodeAlreadyExists, "Token is used by bucket "+b.Name)
+					return
+				}
+			}
+		}
+	}
+	// creating an existing bucket updates its definition
+	req.CreatedAt = time.Now().Unix()
+	if exists {
+		req.CreatedAt = old.CreatedAt
+	}
+	files.EnsureDir(dataPath(req.Name), "0755")
+	if err := bucket.Save(req); err != nil {
+		response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to save bucket", err))
+		return
+	}
+	response.GenerateSuccessData(c, bucketInfo(req))
+}
+
+func ActionBucketList(c *gin.Context) {
+	if !checkServerToken(c) {
+		return
+	}
+	list := []gin.H{}
+	for _, b := range bucket.List() {
+		list = append(list, bucketInfo(b))
+	}
+	response.GenerateSuccessData(c, list)
+}
+
+// bucketInfo is a bucket without the secrets of its tokens and webhook, plus its usage
+func bucketInfo(b defs.Bucket) gin.H {
+	tokens := []gin.H{}
+	for _, t := range b.Tokens {
+		tokens = append(tokens, gin.H{"name": t.Name, "upload": t.Upload})
+	}
+	return gin.H{
+		"name":        b.Name,
+		"host":        b.Host,
+		"tokens":      tokens,
+		"quota":       b.Quota,
+		"usage":       usage(b.Name),
+		"accessRules": b.AccessRules,
+		"webhook": gin.H{
+			"url":        b.Webhook.Url,
+			"events":     b.Webhook.Events,
+			"maxRetries": b.Webhook.MaxRetries,
+		},
+		"createdAt": b.CreatedAt,
+	}
+}
+
+type bucketDeleteRequest struct {
+	Name string `json:"name"`
+	// DeleteData removes the bucket directory too, otherwise it stays as a plain directory of DataDir
+	DeleteData bool `json:"deleteData"`
+}
+
+func ActionBucketDelete(c *gin.Context) {
+	if !checkServerToken(c) {
+		return
+	}
+	var req bucketDeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	if _, ok := bucket.Get(req.Name); !ok {
+		response.GenerateErrorCode(c, defs.CodeNotFound, "Bucket not found")
+		return
+	}
+	if err := bucket.Delete(req.Name); err != nil {
+		response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to delete bucket", err))
+		return
+	}
+	os.RemoveAll(filepath.Join(module.WebhookDir(), req.Name))
+	bucketUsages.Lock()
+	delete(bucketUsages.m, req.Name)
+	bucketUsages.Unlock()
+	if req.DeleteData {
+		fullPath := dataPath(req.Name)
+		if err := os.RemoveAll(fullPath); err != nil {
+			response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to delete bucket data", err))
+			return
+		}
+		meta.Delete(req.Name)
+		module.Replicate(module.ReplicationEvent{Op: module.ReplicateDelete, Path: req.Name})
+	}
+	response.GenerateSuccess(c, "ok")
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"simple-file-server/lib/defs"
+	"testing"
+)
+
+const testBucketToken = "buckettoken-123456"
+
+func uploadWithToken(t *testing.T, token string, filePath string, content string) (int, json.RawMessage) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = multipartRequest(t, map[string]string{"filePath": filePath}, "upload.txt", content)
+	c.Request.Header.Set("admin-api-token", token)
+	ActionUpload(c)
+	var res struct {
+		Code int             `json:"code"`
+		Data json.RawMessage `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	return res.Code, res.Data
+}
+
+func TestBucketScope(t *testing.T) {
+	useDataDir(t)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	if scopePath(c, "a/b") != "a/b" || unscopePath(c, "a/b") != "a/b" {
+		t.Error("paths of a server token were changed")
+	}
+	if code, _ := callAdmin(t, ActionBucketCreate, defs.Bucket{Name: "tenant", Tokens: []defs.TokenConfig{{Name: "uploader", Token: testBucketToken}}}); code != 0 {
+		t.Fatalf("ActionBucketCreate = %d", code)
+	}
+	c.Set("tokenName", "tenant/uploader")
+	for p, want := range map[string]string{"": "tenant", "a/b": "tenant/a/b", "/a/../../b": "tenant/b"} {
+		if got := scopePath(c, p); got != want {
+			t.Errorf("scopePath(%q) = %q, want %q", p, got, want)
+		}
+	}
+	for rel, want := range map[string]string{"tenant": "", "tenant/a/b": "a/b"} {
+		if got := unscopePath(c, rel); got != want {
+			t.Errorf("unscopePath(%q) = %q, want %q", rel, got, want)
+		}
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	root := useDataDir(t)
+	bucket := defs.Bucket{Name: "tenant", Host: "tenant.example.com", Quota: 10, Tokens: []defs.TokenConfig{{Name: "uploader", Token: testBucketToken}}}
+	if code, _ := callAdmin(t, ActionBucketCreate, bucket); code != 0 {
+		t.Fatalf("ActionBucketCreate = %d", code)
+	}
+	for _, invalid := range []defs.Bucket{{Name: "Bad Name"}, {Name: "other", Tokens: []defs.TokenConfig{{Name: "short", Token: "short"}}}} {
+		if code, _ := callAdmin(t, ActionBucketCreate, invalid); code == 0 {
+			t.Errorf("ActionBucketCreate of %+v succeeded", invalid)
+		}
+	}
+	code, data := callAdmin(t, ActionBucketList, gin.H{})
+	var list []struct {
+		Name   string                   `json:"name"`
+		Tokens []map[string]interface{} `json:"tokens"`
+	}
+	json.Unmarshal(data, &list)
+	if code != 0 || len(list) != 1 || list[0].Name != "tenant" || len(list[0].Tokens) != 1 {
+		t.Fatalf("ActionBucketList = %d %s", code, data)
+	}
+	if _, ok := list[0].Tokens[0]["token"]; ok {
+		t.Error("ActionBucketList shows the secret of a bucket token")
+	}
+
+	// bucket tokens see paths relative to the bucket
+	code, data = callWithToken(t, ActionMkdir, testBucketToken, gin.H{"path": "docs"})
+	if code != 0 || string(data) != `{"path":"docs"}` {
+		t.Errorf("mkdir with the bucket token = %d %s", code, data)
+	}
+	if info, err := os.Stat(filepath.Join(root, "tenant", "docs")); err != nil || !info.IsDir() {
+		t.Error("docs was not created in the bucket")
+	}
+	code, data = uploadWithToken(t, testBucketToken, "docs/a.txt", "12345")
+	if code != 0 || string(data) != `{"filePath":"docs/a.txt"}` {
+		t.Errorf("upload with the bucket token = %d %s", code, data)
+	}
+	if code, _ := uploadWithToken(t, testBucketToken, "../escape.txt", "1"); code != 0 {
+		t.Errorf("upload of ../escape.txt = %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(root, "tenant", "escape.txt")); err != nil {
+		t.Error("../escape.txt was not kept inside the bucket")
+	}
+	if code, _ := uploadWithToken(t, testBucketToken, "big.txt", "123456"); code != defs.CodeQuotaExceeded {
+		t.Errorf("upload over the quota = %d, want %d", code, defs.CodeQuotaExceeded)
+	}
+	if code, _ := callWithToken(t, ActionBucketList, testBucketToken, gin.H{}); code == 0 {
+		t.Error("a bucket token listed the buckets")
+	}
+
+	// the host of the bucket serves it at its root
+	req := httptest.NewRequest("GET", "/docs/a.txt", nil)
+	req.Host = "tenant.example.com"
+	if w := getPublic(req); w.Code != 200 || w.Body.String() != "12345" {
+		t.Errorf("GET on the bucket host = %d %q", w.Code, w.Body.String())
+	}
+
+	if code, _ := callAdmin(t, ActionBucketDelete, gin.H{"name": "tenant", "deleteData": true}); code != 0 {
+		t.Fatalf("ActionBucketDelete = %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(root, "tenant")); !os.IsNotExist(err) {
+		t.Error("the bucket directory was kept with deleteData")
+	}
+	if code, _ := callWithToken(t, ActionMkdir, testBucketToken, gin.H{"path": "docs"}); code == 0 {
+		t.Error("the token of a deleted bucket was accepted")
+	}
+	if code, _ := callAdmin(t, ActionBucketDelete, gin.H{"name": "tenant"}); code == 0 {
+		t.Error("deleting a missing bucket succeeded")
+	}
+}
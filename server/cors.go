@@ -0,0 +1,75 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"simple-file-server/global"
+	"simple-file-server/lib/defs"
+	"strconv"
+	"strings"
+)
+
+// cors adds the CORS headers of the public or admin rule and answers preflight requests
+func cors(c *gin.Context) {
+	config := global.Config().Cors
+	rule := config.Public
+	if strings.HasPrefix(c.Request.URL.Path, "/_admin/") {
+		rule = config.Admin
+	}
+	if len(rule.AllowOrigins) == 0 {
+		return
+	}
+	header := c.Writer.Header()
+	header.Add("Vary", "Origin")
+	origin := c.GetHeader("Origin")
+	if origin == "" || !originAllowed(rule, origin) {
+		return
+	}
+	if rule.AllowCredentials || !containsString(rule.AllowOrigins, "*") {
+		header.Set("Access-Control-Allow-Origin", origin)
+	} else {
+		header.Set("Access-Control-Allow-Origin", "*")
+	}
+	if rule.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if len(rule.ExposeHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(rule.ExposeHeaders, ", "))
+	}
+	if c.Request.Method != http.MethodOptions || c.GetHeader("Access-Control-Request-Method") == "" {
+		return
+	}
+	methods := rule.AllowMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(rule.AllowHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(rule.AllowHeaders, ", "))
+	} else if requested := c.GetHeader("Access-Control-Request-Headers"); requested != "" {
+		header.Add("Vary", "Access-Control-Request-Headers")
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+	if rule.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.FormatInt(rule.MaxAge, 10))
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func originAllowed(rule defs.CorsRule, origin string) bool {
+	for _, allowed := range rule.AllowOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"simple-file-server/lib/defs"
+	"testing"
+)
+
+func corsRequest(method string, path string, origin string, requestMethod string) (*httptest.ResponseRecorder, bool) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		c.Request.Header.Set("Origin", origin)
+	}
+	if requestMethod != "" {
+		c.Request.Header.Set("Access-Control-Request-Method", requestMethod)
+		c.Request.Header.Set("Access-Control-Request-Headers", "upload-ticket")
+	}
+	cors(c)
+	c.Writer.WriteHeaderNow()
+	return w, c.IsAborted()
+}
+
+func TestCors(t *testing.T) {
+	useConfig(t, defs.Config{})
+	if w, aborted := corsRequest("GET", "/a.txt", "https://example.com", ""); aborted || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("CORS headers were added without allowOrigins")
+	}
+
+	useConfig(t, defs.Config{Cors: defs.CorsConfig{
+		Public: defs.CorsRule{AllowOrigins: []string{"*"}, ExposeHeaders: []string{"ETag"}},
+		Admin:  defs.CorsRule{AllowOrigins: []string{"https://app.example.com/"}, AllowCredentials: true, MaxAge: 600},
+	}})
+	w, aborted := corsRequest("GET", "/a.txt", "https://example.com", "")
+	if aborted || w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Access-Control-Expose-Headers") != "ETag" {
+		t.Errorf("public GET: aborted %v, headers %v", aborted, w.Header())
+	}
+
+	// the admin rule lists its origins and allows credentials, so the origin is echoed
+	w, aborted = corsRequest("OPTIONS", "/_admin/upload", "https://app.example.com", "POST")
+	if !aborted || w.Code != http.StatusNoContent {
+		t.Fatalf("preflight: aborted %v, status %d", aborted, w.Code)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, HEAD, POST",
+		"Access-Control-Allow-Headers":     "upload-ticket",
+		"Access-Control-Max-Age":           "600",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("preflight %s = %q, want %q", header, got, want)
+		}
+	}
+
+	if w, aborted := corsRequest("OPTIONS", "/_admin/upload", "https://evil.example.com", "POST"); aborted || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("preflight of another origin: aborted %v, headers %v", aborted, w.Header())
+	}
+}
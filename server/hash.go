@@ -0,0 +1,56 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"os"
+	"simple-file-server/lib/defs"
+	sfserrors "simple-file-server/lib/errors"
+	"simple-file-server/lib/meta"
+	"simple-file-server/lib/response"
+	"simple-file-server/module"
+)
+
+type hashRequest struct {
+	Path    string `json:"path"`
+	Refresh bool   `json:"refresh"`
+}
+
+func ActionHash(c *gin.Context) {
+	if !checkAdminToken(c) {
+		return
+	}
+	var req hashRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	req.Path = scopePath(c, req.Path)
+	fullPath := dataPath(req.Path)
+	info, err := os.Stat(fullPath)
+	if err != nil || info.IsDir() {
+		response.GenerateErrorCode(c, defs.CodeNotFound, "File not found")
+		return
+	}
+	res, err := meta.Hashes(relPath(req.Path), fullPath, req.Refresh)
+	if err != nil {
+		response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to hash file", err))
+		return
+	}
+	response.GenerateSuccessWithData(c, "ok", gin.H{
+		"path":    unscopePath(c, relPath(req.Path)),
+		"size":    info.Size(),
+		"md5":     res.Hashes.Md5,
+		"sha1":    res.Hashes.Sha1,
+		"sha256":  res.Hashes.Sha256,
+		"crc32c":  res.Hashes.Crc32c,
+		"cached":  res.Cached,
+		"corrupt": res.Corrupt,
+	})
+}
+
+func ActionScrubReport(c *gin.Context) {
+	if !checkServerToken(c) {
+		return
+	}
+	response.GenerateSuccessData(c, module.LastScrubReport())
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"testing"
+)
+
+func TestActionHash(t *testing.T) {
+	root := useDataDir(t)
+	writeFiles(t, root, "abc")
+
+	var res struct {
+		Path   string `json:"path"`
+		Size   int64  `json:"size"`
+		Md5    string `json:"md5"`
+		Cached bool   `json:"cached"`
+	}
+	code, data := callAdmin(t, ActionHash, gin.H{"path": "abc"})
+	json.Unmarshal(data, &res)
+	if code != 0 || res.Path != "abc" || res.Size != 3 || res.Md5 != "900150983cd24fb0d6963f7d28e17f72" || res.Cached {
+		t.Errorf("hash answered %d %s", code, data)
+	}
+	code, data = callAdmin(t, ActionHash, gin.H{"path": "abc"})
+	json.Unmarshal(data, &res)
+	if code != 0 || !res.Cached {
+		t.Errorf("second hash answered %d %s, want a cached hash", code, data)
+	}
+
+	writeFiles(t, root, "dir/a.txt")
+	for _, path := range []string{"missing", "dir", ""} {
+		if code, _ := callAdmin(t, ActionHash, gin.H{"path": path}); code == 0 {
+			t.Errorf("hash of %q succeeded", path)
+		}
+	}
+}
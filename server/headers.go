@@ -0,0 +1,84 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"mime"
+	"net/http"
+	"path"
+	"simple-file-server/global"
+	"simple-file-server/lib/defs"
+	"strings"
+	"time"
+)
+
+// matchHeaderRule tells whether rule applies to rel, a pattern without "/" matches the file name too
+func matchHeaderRule(rule defs.HeaderRule, rel string) bool {
+	if rule.Pattern != "" {
+		ok, _ := path.Match(rule.Pattern, rel)
+		if !ok && !strings.Contains(rule.Pattern, "/") {
+			ok, _ = path.Match(rule.Pattern, path.Base(rel))
+		}
+		if ok {
+			return true
+		}
+	}
+	return len(rule.Extensions) > 0 && containsExtension(rule.Extensions, strings.ToLower(path.Ext(rel)))
+}
+
+// setFileHeaders applies the headers rules of rel and the download query parameter to a public file response.
+// A Cache-Control set before, like the one of a site rule, is kept.
+func setFileHeaders(c *gin.Context, rel string) {
+	headers := map[string]string{}
+	for _, rule := range global.Config().Headers {
+		if !matchHeaderRule(rule, rel) {
+			continue
+		}
+		if rule.CacheControl != "" {
+			headers["Cache-Control"] = rule.CacheControl
+		}
+		if rule.Expires > 0 {
+			headers["Expires"] = time.Now().Add(time.Duration(rule.Expires) * time.Second).UTC().Format(http.TimeFormat)
+		}
+		if rule.Attachment {
+			headers["Content-Disposition"] = contentDisposition(path.Base(rel))
+		}
+		if rule.ContentSecurityPolicy != "" {
+			headers["Content-Security-Policy"] = rule.ContentSecurityPolicy
+		}
+		for name, value := range rule.Headers {
+			headers[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+	// ?download saves the file under its own name, ?download=name under the given one
+	if name, ok := c.GetQuery("download"); ok {
+		name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+		if name == "" || name == "." || name == "/" {
+			name = path.Base(rel)
+		}
+		headers["Content-Disposition"] = contentDisposition(name)
+	}
+	for name, value := range headers {
+		if name == "Cache-Control" && c.Writer.Header().Get(name) != "" {
+			continue
+		}
+		if value == "" {
+			c.Writer.Header().Del(name)
+			continue
+		}
+		c.Header(name, value)
+	}
+}
+
+// contentDisposition returns an attachment disposition, non ASCII names are encoded as filename*
+func contentDisposition(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	if disposition := mime.FormatMediaType("attachment", map[string]string{"filename": name}); disposition != "" {
+		return disposition
+	}
+	return "attachment"
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http/httptest"
+	"simple-file-server/lib/defs"
+	"testing"
+)
+
+func TestContentDisposition(t *testing.T) {
+	for name, want := range map[string]string{
+		"a.txt":       `attachment; filename=a.txt`,
+		"my file.pdf": `attachment; filename="my file.pdf"`,
+		"bad\r\n.txt": `attachment; filename=bad.txt`,
+		"résumé.pdf":  `attachment; filename*=utf-8''r%C3%A9sum%C3%A9.pdf`,
+	} {
+		if got := contentDisposition(name); got != want {
+			t.Errorf("contentDisposition(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestFileHeaders(t *testing.T) {
+	root := t.TempDir()
+	useConfig(t, defs.Config{DataDir: root, TempDir: t.TempDir(), Headers: []defs.HeaderRule{
+		{Pattern: "*.html", CacheControl: "no-cache", ContentSecurityPolicy: "default-src 'self'"},
+		{Extensions: []string{".js"}, CacheControl: "max-age=3600", Headers: map[string]string{"x-custom": "js"}},
+		{Pattern: "docs/*.pdf", Attachment: true},
+		// later rules override earlier ones, an empty value removes the header
+		{Pattern: "nocache/*", CacheControl: "no-store", Headers: map[string]string{"X-Custom": ""}},
+	}})
+	writeFiles(t, root, "page.html", "app.js", "nocache/app.js", "docs/guide.pdf", "a.txt")
+
+	tests := []struct {
+		path    string
+		headers map[string]string
+	}{
+		{"/page.html", map[string]string{"Cache-Control": "no-cache", "Content-Security-Policy": "default-src 'self'"}},
+		{"/app.js", map[string]string{"Cache-Control": "max-age=3600", "X-Custom": "js"}},
+		{"/nocache/app.js", map[string]string{"Cache-Control": "no-store", "X-Custom": ""}},
+		{"/docs/guide.pdf", map[string]string{"Content-Disposition": "attachment; filename=guide.pdf"}},
+		{"/a.txt", map[string]string{"Cache-Control": "", "Content-Disposition": ""}},
+		{"/a.txt?download", map[string]string{"Content-Disposition": "attachment; filename=a.txt"}},
+		// only the base name of ?download is used
+		{"/a.txt?download=../b.txt", map[string]string{"Content-Disposition": "attachment; filename=b.txt"}},
+	}
+	for _, tt := range tests {
+		w := getPublic(httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != 200 {
+			t.Errorf("GET %s = %d", tt.path, w.Code)
+			continue
+		}
+		for name, want := range tt.headers {
+			if got := w.Header().Get(name); got != want {
+				t.Errorf("GET %s: %s = %q, want %q", tt.path, name, got, want)
+			}
+		}
+	}
+}
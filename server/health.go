@@ -0,0 +1,231 @@
+package server
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/robfig/cron/v3"
+	"github.com/shirou/gopsutil/v3/disk"
+	"net/http"
+	"os"
+	"runtime"
+	"simple-file-server/global"
+	"simple-file-server/lib/defs"
+	"simple-file-server/lib/platform"
+	"simple-file-server/lib/response"
+	"simple-file-server/lib/version"
+	"simple-file-server/module"
+	"sync"
+	"time"
+)
+
+var startedAt = time.Now()
+
+// ActionHealth reports every check, it answers 503 when one of them fails
+func ActionHealth(c *gin.Context) {
+	if !checkServerToken(c) {
+		return
+	}
+	report := defs.HealthReport{
+		Version:   version.VERSION,
+		StartedAt: startedAt.Unix(),
+		Uptime:    int64(time.Since(startedAt).Seconds()),
+		Platform:  platformInfo(),
+		Checks: defs.HealthChecks{
+			DataDir:     dirHealth(global.Config().DataDir, true),
+			TempDir:     dirHealth(global.Config().TempDir, true),
+			Cron:        cronHealth(),
+			Replication: backlogHealth(module.ReplicationBacklog()),
+			Webhook:     backlogHealth(module.WebhookBacklog()),
+		},
+	}
+	report.Status = worstStatus(report.Checks.DataDir.Status, report.Checks.TempDir.Status, report.Checks.Cron.Status,
+		report.Checks.Replication.Status, report.Checks.Webhook.Status)
+	if report.Status == defs.HealthFail {
+		response.GenerateWithStatus(c, http.StatusServiceUnavailable, defs.CodeNotReady, "unhealthy", report)
+		return
+	}
+	response.GenerateSuccessData(c, report)
+}
+
+// ActionReady is the readiness probe, the server is ready when its directories exist and have space left.
+// It needs no token and is not rate limited, so it only reads; the write probe is left to ActionHealth.
+func ActionReady(c *gin.Context) {
+	report := defs.ReadyReport{Failed: []string{}}
+	if dirHealth(global.Config().DataDir, false).Status == defs.HealthFail {
+		report.Failed = append(report.Failed, "dataDir")
+	}
+	if dirHealth(global.Config().TempDir, false).Status == defs.HealthFail {
+		report.Failed = append(report.Failed, "tempDir")
+	}
+	report.Ready = len(report.Failed) == 0
+	if !report.Ready {
+		response.GenerateWithStatus(c, http.StatusServiceUnavailable, defs.CodeNotReady, "not ready", report)
+		return
+	}
+	response.GenerateSuccessData(c, report)
+}
+
+func platformInfo() defs.PlatformInfo {
+	hostname, _ := os.Hostname()
+	return defs.PlatformInfo{
+		Os:         string(platform.PlatformOS),
+		Arch:       string(platform.PlatformArch),
+		Family:     string(platform.PlatformFamily),
+		Hostname:   hostname,
+		GoVersion:  runtime.Version(),
+		NumCpu:     runtime.NumCPU(),
+		Goroutines: runtime.NumGoroutine(),
+	}
+}
+
+// dirHealth checks the free space and inodes of dir against the health config.
+// With probe it also writes and removes a file in dir, without it only checks that dir is a directory.
+func dirHealth(dir string, probe bool) defs.DirHealth {
+	health := defs.DirHealth{Status: defs.HealthOk, Path: dir}
+	if probe {
+		if err := writeProbe(dir); err != nil {
+			health.Status = defs.HealthFail
+			health.Error = "not writable: " + err.Error()
+		} else {
+			health.Writable = true
+		}
+	} else if info, err := os.Stat(dir); err != nil {
+		health.Status = defs.HealthFail
+		health.Error = err.Error()
+	} else if !info.IsDir() {
+		health.Status = defs.HealthFail
+		health.Error = "not a directory"
+	}
+	usage, err := disk.Usage(dir)
+	if err != nil {
+		health.Status = defs.HealthFail
+		health.Error = "disk usage: " + err.Error()
+		return health
+	}
+	health.Free, health.Total = usage.Free, usage.Total
+	health.InodesFree, health.InodesTotal = usage.InodesFree, usage.InodesTotal
+	config := global.Config().Health
+	if health.Status == defs.HealthOk && usage.Free < uint64(config.MinFreeSpace) {
+		health.Status = defs.HealthFail
+		health.Error = fmt.Sprintf("%d bytes free, health.minFreeSpace is %d", usage.Free, config.MinFreeSpace)
+	}
+	// some file systems like btrfs have no fixed number of inodes and report 0
+	if health.Status == defs.HealthOk && usage.InodesTotal > 0 && usage.InodesFree < uint64(config.MinFreeInodes) {
+		health.Status = defs.HealthFail
+		health.Error = fmt.Sprintf("%d inodes free, health.minFreeInodes is %d", usage.InodesFree, config.MinFreeInodes)
+	}
+	return health
+}
+
+func writeProbe(dir string) error {
+	file, err := os.CreateTemp(dir, ".sfs-health-*")
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString("ok")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if removeErr := os.Remove(file.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// cronWatchInterval is how often watchCron asks the scheduler for its entries
+const cronWatchInterval = 5 * time.Second
+
+// cronWatch is the last answer of the scheduler to watchCron
+var cronWatch = struct {
+	sync.Mutex
+	at      time.Time
+	entries []cron.Entry
+}{}
+
+// watchCron polls the scheduler for good. Entries is answered by the scheduler goroutine, so a stuck scheduler
+// blocks only this goroutine and shows up as a heartbeat that stops.
+func watchCron(interval time.Duration) {
+	for {
+		if global.CRON != nil {
+			entries := global.CRON.Entries()
+			cronWatch.Lock()
+			cronWatch.at = time.Now()
+			cronWatch.entries = entries
+			cronWatch.Unlock()
+		}
+		time.Sleep(interval)
+	}
+}
+
+// cronHealth fails when the scheduler stopped answering watchCron or a job is more than a minute late
+func cronHealth() defs.CronHealth {
+	health := defs.CronHealth{Status: defs.HealthOk, Jobs: map[string]defs.CronJobHealth{}}
+	cronWatch.Lock()
+	heartbeat, scheduled := cronWatch.at, cronWatch.entries
+	cronWatch.Unlock()
+	if heartbeat.IsZero() {
+		health.Status = defs.HealthFail
+		health.Error = "scheduler not started"
+		return health
+	}
+	if time.Since(heartbeat) > 3*cronWatchInterval {
+		health.Status = defs.HealthFail
+		health.Error = fmt.Sprintf("scheduler not responding for %d seconds", int(time.Since(heartbeat).Seconds()))
+		return health
+	}
+	global.CronIDLock.RLock()
+	names := map[cron.EntryID]string{
+		global.CronIDMonitor:     "monitor",
+		global.CronIDScrubber:    "scrubber",
+		global.CronIDReplication: "replication",
+		global.CronIDWebhook:     "webhook",
+	}
+	global.CronIDLock.RUnlock()
+	late := time.Now().Add(-time.Minute)
+	for _, entry := range scheduled {
+		name, ok := names[entry.ID]
+		if !ok || entry.ID == 0 {
+			continue
+		}
+		job := defs.CronJobHealth{Next: entry.Next.Unix()}
+		if !entry.Prev.IsZero() {
+			job.Prev = entry.Prev.Unix()
+		}
+		health.Jobs[name] = job
+		if entry.Next.Before(late) {
+			health.Status = defs.HealthFail
+			health.Error = name + " job is late"
+		}
+	}
+	if _, ok := health.Jobs["monitor"]; !ok && health.Status == defs.HealthOk {
+		health.Status = defs.HealthFail
+		health.Error = "monitor job not scheduled"
+	}
+	return health
+}
+
+// backlogHealth warns when events failed for good or more than health.maxBacklog are queued
+func backlogHealth(backlog map[string]map[string]int) defs.BacklogHealth {
+	health := defs.BacklogHealth{Status: defs.HealthOk, Backlog: backlog}
+	for name, counts := range backlog {
+		if counts["failed"] > 0 {
+			health.Status = defs.HealthWarn
+			health.Error = fmt.Sprintf("%s has %d failed events", name, counts["failed"])
+		} else if counts["queued"] > global.Config().Health.MaxBacklog && health.Status == defs.HealthOk {
+			health.Status = defs.HealthWarn
+			health.Error = fmt.Sprintf("%s has %d queued events", name, counts["queued"])
+		}
+	}
+	return health
+}
+
+func worstStatus(statuses ...string) string {
+	rank := map[string]int{defs.HealthOk: 0, defs.HealthWarn: 1, defs.HealthFail: 2}
+	worst := defs.HealthOk
+	for _, status := range statuses {
+		if rank[status] > rank[worst] {
+			worst = status
+		}
+	}
+	return worst
+}
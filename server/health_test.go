@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/robfig/cron/v3"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"simple-file-server/global"
+	"simple-file-server/lib/defs"
+	"testing"
+	"time"
+)
+
+func TestDirHealth(t *testing.T) {
+	dir := t.TempDir()
+	useConfig(t, defs.Config{})
+
+	health := dirHealth(dir, true)
+	if health.Status != defs.HealthOk || !health.Writable || health.Total == 0 {
+		t.Fatalf("probe = %+v", health)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("probe left %d files", len(entries))
+	}
+	if health := dirHealth(dir, false); health.Status != defs.HealthOk || health.Writable {
+		t.Fatalf("stat = %+v", health)
+	}
+
+	if health := dirHealth(filepath.Join(dir, "missing"), false); health.Status != defs.HealthFail {
+		t.Fatalf("missing dir = %+v", health)
+	}
+	if health := dirHealth(filepath.Join(dir, "missing"), true); health.Status != defs.HealthFail || health.Writable {
+		t.Fatalf("missing dir with probe = %+v", health)
+	}
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, []byte("x"), 0644)
+	if health := dirHealth(file, false); health.Status != defs.HealthFail || health.Error != "not a directory" {
+		t.Fatalf("file = %+v", health)
+	}
+
+	useConfig(t, defs.Config{Health: defs.HealthConfig{MinFreeSpace: 1 << 62}})
+	if health := dirHealth(dir, false); health.Status != defs.HealthFail {
+		t.Fatalf("minFreeSpace = %+v", health)
+	}
+}
+
+func TestActionReady(t *testing.T) {
+	dataDir, tempDir := t.TempDir(), t.TempDir()
+	useConfig(t, defs.Config{DataDir: dataDir, TempDir: tempDir})
+	ready := func() (int, int, defs.ReadyReport) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/_admin/ready", nil)
+		ActionReady(c)
+		var res struct {
+			Code int              `json:"code"`
+			Data defs.ReadyReport `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+		}
+		return w.Code, res.Code, res.Data
+	}
+
+	if status, code, report := ready(); status != 200 || code != 0 || !report.Ready || len(report.Failed) != 0 {
+		t.Fatalf("ready = %d %d %+v", status, code, report)
+	}
+	// the probe is unauthenticated, it must not write to the directories
+	for _, dir := range []string{dataDir, tempDir} {
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Fatalf("ready wrote %d files to %s", len(entries), dir)
+		}
+	}
+
+	os.Remove(tempDir)
+	status, code, report := ready()
+	if status != 503 || code != defs.CodeNotReady || report.Ready || !reflect.DeepEqual(report.Failed, []string{"tempDir"}) {
+		t.Fatalf("not ready = %d %d %+v", status, code, report)
+	}
+}
+
+// useCronWatch sets the last answer of the scheduler and the monitor job id for the test
+func useCronWatch(t *testing.T, at time.Time, monitor cron.EntryID, entries ...cron.Entry) {
+	cronWatch.Lock()
+	previousAt, previousEntries := cronWatch.at, cronWatch.entries
+	cronWatch.at, cronWatch.entries = at, entries
+	cronWatch.Unlock()
+	global.CronIDLock.Lock()
+	previousMonitor := global.CronIDMonitor
+	global.CronIDMonitor = monitor
+	global.CronIDLock.Unlock()
+	t.Cleanup(func() {
+		cronWatch.Lock()
+		cronWatch.at, cronWatch.entries = previousAt, previousEntries
+		cronWatch.Unlock()
+		global.CronIDLock.Lock()
+		global.CronIDMonitor = previousMonitor
+		global.CronIDLock.Unlock()
+	})
+}
+
+func TestCronHealth(t *testing.T) {
+	now := time.Now()
+	next := now.Add(time.Minute)
+
+	useCronWatch(t, time.Time{}, 0)
+	if health := cronHealth(); health.Status != defs.HealthFail || health.Error != "scheduler not started" {
+		t.Fatalf("not started = %+v", health)
+	}
+
+	useCronWatch(t, now.Add(-time.Minute), 1, cron.Entry{ID: 1, Next: next})
+	if health := cronHealth(); health.Status != defs.HealthFail {
+		t.Fatalf("stale heartbeat = %+v", health)
+	}
+
+	useCronWatch(t, now, 1, cron.Entry{ID: 1, Prev: now.Add(-time.Minute), Next: next}, cron.Entry{ID: 7, Next: next})
+	health := cronHealth()
+	if health.Status != defs.HealthOk || len(health.Jobs) != 1 || health.Jobs["monitor"].Next != next.Unix() {
+		t.Fatalf("ok = %+v", health)
+	}
+
+	useCronWatch(t, now, 1, cron.Entry{ID: 1, Next: now.Add(-2 * time.Minute)})
+	if health := cronHealth(); health.Status != defs.HealthFail || health.Error != "monitor job is late" {
+		t.Fatalf("late = %+v", health)
+	}
+
+	useCronWatch(t, now, 1)
+	if health := cronHealth(); health.Status != defs.HealthFail || health.Error != "monitor job not scheduled" {
+		t.Fatalf("not scheduled = %+v", health)
+	}
+}
+
+func TestBacklogHealth(t *testing.T) {
+	useConfig(t, defs.Config{Health: defs.HealthConfig{MaxBacklog: 2}})
+	cases := []struct {
+		backlog map[string]map[string]int
+		status  string
+	}{
+		{map[string]map[string]int{}, defs.HealthOk},
+		{map[string]map[string]int{"a": {"queued": 2}}, defs.HealthOk},
+		{map[string]map[string]int{"a": {"queued": 3}}, defs.HealthWarn},
+		{map[string]map[string]int{"a": {"failed": 1}}, defs.HealthWarn},
+	}
+	for _, tc := range cases {
+		if health := backlogHealth(tc.backlog); health.Status != tc.status {
+			t.Errorf("backlogHealth(%v) = %+v, want %s", tc.backlog, health, tc.status)
+		}
+	}
+	if status := worstStatus(defs.HealthOk, defs.HealthFail, defs.HealthWarn); status != defs.HealthFail {
+		t.Errorf("worstStatus = %s", status)
+	}
+}
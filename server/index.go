@@ -0,0 +1,183 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"simple-file-server/global"
+	"sort"
+	"strings"
+	"time"
+)
+
+const IndexDocument = "index.html"
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Index of /{{.Path}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.2em 1.2em 0.2em 0; text-align: left; }
+td.size { text-align: right; }
+a { text-decoration: none; }
+</style>
+</head>
+<body>
+<h1>Index of {{range .Crumbs}}<a href="{{.Href}}">{{.Name}}/</a>{{end}}</h1>
+<table>
+<tr>
+<th><a href="?sort=name&amp;order={{.NextOrder "name"}}">Name</a></th>
+<th><a href="?sort=size&amp;order={{.NextOrder "size"}}">Size</a></th>
+<th><a href="?sort=mtime&amp;order={{.NextOrder "mtime"}}">Modified</a></th>
+</tr>
+{{if .Path}}<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{end}}{{range .Items}}<tr>
+<td><a href="{{.Href}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td>
+<td class="size">{{if not .IsDir}}{{.Size}}{{end}}</td>
+<td>{{.Modified}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type indexCrumb struct {
+	Name string
+	Href string
+}
+
+type indexItem struct {
+	ListItem
+	Href     string
+	Modified string
+}
+
+type indexPage struct {
+	Path   string
+	Crumbs []indexCrumb
+	Items  []indexItem
+	Sort   string
+	Order  string
+}
+
+// NextOrder is the order a click on the column header sorts by
+func (p indexPage) NextOrder(column string) string {
+	if p.Sort == column && p.Order == "asc" {
+		return "desc"
+	}
+	return "asc"
+}
+
+// indexEnabled tells whether the deepest indexes entry covering rel turns index pages on
+func indexEnabled(rel string) bool {
+	enabled := false
+	depth := -1
+	for _, index := range global.Config().Indexes {
+		prefix := relPath(index.Prefix)
+		if prefix == "" || rel == prefix || strings.HasPrefix(rel, prefix+"/") {
+			if d := pathDepth(prefix); d > depth {
+				enabled, depth = index.Enabled, d
+			}
+		}
+	}
+	return enabled
+}
+
+// serveDirectory serves index.html of a directory or renders its listing as html or json
+func serveDirectory(c *gin.Context, rel string, fullPath string) {
+	if !strings.HasSuffix(c.Request.URL.Path, "/") {
+		// relative links in the page need the trailing slash
+		target := url.URL{Path: strings.TrimSuffix(publicPath(c, rel), "/") + "/", RawQuery: c.Request.URL.RawQuery}
+		c.Redirect(http.StatusMovedPermanently, target.String())
+		return
+	}
+	index := filepath.Join(fullPath, IndexDocument)
+	if info, err := os.Stat(index); err == nil && !info.IsDir() {
+		serveFile(c, relPath(rel+"/"+IndexDocument), index)
+		return
+	}
+	entries, err := os.ReadDir(fullPath)
+	if err != nil {
+		c.AbortWithStatus(404)
+		return
+	}
+	// the path as seen by the client, the bucket directory is hidden on a bucket host
+	shown := strings.TrimPrefix(publicPath(c, rel), "/")
+	items := []ListItem{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		items = append(items, ListItem{
+			Path:  strings.TrimPrefix(shown+"/"+entry.Name(), "/"),
+			Name:  entry.Name(),
+			IsDir: info.IsDir(),
+			Size:  info.Size(),
+			Mtime: info.ModTime().Unix(),
+		})
+	}
+	sortBy := c.DefaultQuery("sort", "name")
+	order := c.DefaultQuery("order", "asc")
+	sortListItems(items, sortBy, order == "desc")
+
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+		c.JSON(http.StatusOK, gin.H{"path": shown, "items": items})
+		return
+	}
+	page := indexPage{Path: shown, Sort: sortBy, Order: order}
+	page.Crumbs = append(page.Crumbs, indexCrumb{Name: "", Href: "/"})
+	if shown != "" {
+		href := ""
+		for _, name := range strings.Split(shown, "/") {
+			href += "/" + name
+			page.Crumbs = append(page.Crumbs, indexCrumb{Name: name, Href: (&url.URL{Path: href + "/"}).String()})
+		}
+	}
+	for _, item := range items {
+		href := (&url.URL{Path: item.Name}).String()
+		if item.IsDir {
+			href += "/"
+		}
+		page.Items = append(page.Items, indexItem{
+			ListItem: item,
+			Href:     href,
+			Modified: time.Unix(item.Mtime, 0).Format("2006-01-02 15:04:05"),
+		})
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Server", "Simple-File-Server")
+	c.Status(http.StatusOK)
+	indexTemplate.Execute(c.Writer, page)
+}
+
+// sortListItems sorts directories first, then by name, size or mtime
+func sortListItems(items []ListItem, by string, desc bool) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if a.IsDir != b.IsDir {
+			return a.IsDir
+		}
+		if desc {
+			a, b = b, a
+		}
+		switch by {
+		case "size":
+			return a.Size < b.Size || a.Size == b.Size && a.Name < b.Name
+		case "mtime":
+			return a.Mtime < b.Mtime || a.Mtime == b.Mtime && a.Name < b.Name
+		default:
+			return a.Name < b.Name
+		}
+	})
+}
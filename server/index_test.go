@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"simple-file-server/lib/defs"
+	"strings"
+	"testing"
+)
+
+// getPublic sends req to the public file handler
+func getPublic(req *http.Request) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.NoRoute(ActionServeFile)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIndexEnabled(t *testing.T) {
+	useConfig(t, defs.Config{Indexes: []defs.IndexConfig{
+		{Prefix: "pub", Enabled: true},
+		{Prefix: "/pub/private/", Enabled: false},
+	}})
+	for rel, want := range map[string]bool{
+		"":                false,
+		"pub":             true,
+		"pub/a/b":         true,
+		"public":          false,
+		"pub/private":     false,
+		"pub/private/sub": false,
+	} {
+		if got := indexEnabled(rel); got != want {
+			t.Errorf("indexEnabled(%q) = %v, want %v", rel, got, want)
+		}
+	}
+}
+
+func TestServeDirectory(t *testing.T) {
+	root := t.TempDir()
+	useConfig(t, defs.Config{DataDir: root, TempDir: t.TempDir(), Indexes: []defs.IndexConfig{{Prefix: "pub", Enabled: true}}})
+	writeFiles(t, root, "pub/b.txt", "pub/a-long.txt", "pub/.hidden", "pub/sub/c.txt", "pub/site/index.html", "closed/d.txt")
+
+	w := getPublic(httptest.NewRequest("GET", "/pub?sort=size", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/pub/?sort=size" {
+		t.Errorf("directory without a slash = %d to %q", w.Code, w.Header().Get("Location"))
+	}
+
+	req := httptest.NewRequest("GET", "/pub/?sort=size&order=desc", nil)
+	req.Header.Set("Accept", "application/json")
+	w = getPublic(req)
+	var listing struct {
+		Path  string     `json:"path"`
+		Items []ListItem `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("json listing %q: %v", w.Body.String(), err)
+	}
+	var names []string
+	for _, item := range listing.Items {
+		names = append(names, item.Name)
+	}
+	// hidden files are left out and directories come first, both sorted by descending size
+	if want := []string{"sub", "site", "a-long.txt", "b.txt"}; listing.Path != "pub" || !reflect.DeepEqual(names, want) {
+		t.Errorf("json listing of %q = %q, want %q", listing.Path, names, want)
+	}
+
+	w = getPublic(httptest.NewRequest("GET", "/pub/", nil))
+	if body := w.Body.String(); w.Code != 200 || !strings.Contains(body, "Index of") || !strings.Contains(body, `href="sub/"`) {
+		t.Errorf("html listing = %d %q", w.Code, body)
+	}
+	if w := getPublic(httptest.NewRequest("GET", "/pub/site/", nil)); w.Code != 200 || w.Body.String() != "pub/site/index.html" {
+		t.Errorf("directory with index.html = %d %q", w.Code, w.Body.String())
+	}
+	if w := getPublic(httptest.NewRequest("GET", "/closed/", nil)); w.Code != 404 {
+		t.Errorf("directory without index pages = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"simple-file-server/global"
+	"simple-file-server/lib/defs"
+	sfserrors "simple-file-server/lib/errors"
+	"strings"
+)
+
+// formOverhead is the room left for multipart headers and form fields when capping a request body
+const formOverhead = 1024 * 1024
+
+// tokenLimits returns the limits known before the target path is: the global ones and the ones of the token or ticket
+func tokenLimits(c *gin.Context) []defs.UploadLimits {
+	config := global.Config()
+	layers := []defs.UploadLimits{config.Upload}
+	name := c.GetString("tokenName")
+	for _, token := range config.Tokens {
+		if token.Name == name {
+			layers = append(layers, token.Upload)
+		}
+	}
+	if b, ok := requestBucket(c); ok {
+		for _, token := range b.Tokens {
+			if b.Name+"/"+token.Name == name {
+				layers = append(layers, token.Upload)
+			}
+		}
+	}
+	if t := requestTicket(c); t != nil {
+		layers = append(layers, defs.UploadLimits{MaxSize: t.MaxSize, MaxPartSize: t.MaxSize, MaxTotalSize: t.MaxSize})
+	}
+	return layers
+}
+
+// uploadLimits returns every set of limits an upload to path has to pass
+func uploadLimits(c *gin.Context, path string) []defs.UploadLimits {
+	layers := tokenLimits(c)
+	rel := relPath(path)
+	for _, rule := range global.Config().UploadRules {
+		prefix := relPath(rule.Prefix)
+		if prefix == "" || rel == prefix || strings.HasPrefix(rel, prefix+"/") {
+			layers = append(layers, rule.Limits)
+		}
+	}
+	return layers
+}
+
+// limitBody caps the request body to the smallest limit picked from layers, so oversized uploads never reach the disk.
+// Uploads read the target path from the same form as the file, so they cap the body with tokenLimits only
+// and check the uploadRules of the path once the form is parsed.
+func limitBody(c *gin.Context, layers []defs.UploadLimits, pick func(defs.UploadLimits) int64) {
+	if limit := smallestLimit(layers, pick); limit > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+formOverhead)
+	}
+}
+
+// smallestLimit returns the smallest non zero limit picked from layers, 0 if there is none
+func smallestLimit(layers []defs.UploadLimits, pick func(defs.UploadLimits) int64) int64 {
+	var limit int64
+	for _, layer := range layers {
+		if value := pick(layer); value > 0 && (limit == 0 || value < limit) {
+			limit = value
+		}
+	}
+	return limit
+}
+
+func isBodyTooLarge(err error) bool {
+	var maxBytesError *http.MaxBytesError
+	return errors.As(err, &maxBytesError)
+}
+
+func checkUploadSize(layers []defs.UploadLimits, size int64) *sfserrors.BusinessError {
+	for _, layer := range layers {
+		if layer.MaxSize > 0 && size > layer.MaxSize {
+			return sfserrors.New(defs.CodeFileTooLarge, fmt.Sprintf("File too large, max %d bytes", layer.MaxSize))
+		}
+	}
+	return nil
+}
+
+func checkPartSize(layers []defs.UploadLimits, size int64) *sfserrors.BusinessError {
+	for _, layer := range layers {
+		if layer.MaxPartSize > 0 && size > layer.MaxPartSize {
+			return sfserrors.New(defs.CodePartTooLarge, fmt.Sprintf("Part too large, max %d bytes", layer.MaxPartSize))
+		}
+	}
+	return nil
+}
+
+func checkMultipart(layers []defs.UploadLimits, parts int, totalSize int64) *sfserrors.BusinessError {
+	for _, layer := range layers {
+		if layer.MaxParts > 0 && parts > layer.MaxParts {
+			return sfserrors.New(defs.CodeTooManyParts, fmt.Sprintf("Too many parts, max %d", layer.MaxParts))
+		}
+		if layer.MaxTotalSize > 0 && totalSize > layer.MaxTotalSize {
+			return sfserrors.New(defs.CodeTotalSizeTooLarge, fmt.Sprintf("Total size too large, max %d bytes", layer.MaxTotalSize))
+		}
+	}
+	return nil
+}
+
+// checkUploadType checks the extension of path and every given content type against the allow and deny lists, empty types are skipped
+func checkUploadType(layers []defs.UploadLimits, path string, contentTypes ...string) *sfserrors.BusinessError {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, layer := range layers {
+		if len(layer.AllowExtensions) > 0 && !containsExtension(layer.AllowExtensions, ext) ||
+			containsExtension(layer.DenyExtensions, ext) {
+			return sfserrors.New(defs.CodeExtensionNotAllowed, fmt.Sprintf("Extension %q not allowed", ext))
+		}
+		for _, contentType := range contentTypes {
+			if contentType == "" {
+				continue
+			}
+			if len(layer.AllowMimeTypes) > 0 && !matchMimeType(layer.AllowMimeTypes, contentType) ||
+				matchMimeType(layer.DenyMimeTypes, contentType) {
+				return sfserrors.New(defs.CodeContentTypeNotAllowed, fmt.Sprintf("Content type %q not allowed", contentType))
+			}
+		}
+	}
+	return nil
+}
+
+// hasTypeRules tells whether sniffing the content is needed at all
+func hasTypeRules(layers []defs.UploadLimits) bool {
+	for _, layer := range layers {
+		if len(layer.AllowMimeTypes) > 0 || len(layer.DenyMimeTypes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffContentType detects the type of the content and rewinds r, unknown content is application/octet-stream
+func sniffContentType(r io.ReadSeeker) string {
+	buf := make([]byte, 512)
+	n, _ := io.ReadFull(r, buf)
+	r.Seek(0, io.SeekStart)
+	return sniffBytes(buf[:n])
+}
+
+func sniffBytes(data []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	return mediaType
+}
+
+// declaredType strips parameters like charset from a type given by the uploader, octet-stream says nothing and is dropped
+func declaredType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "application/octet-stream" {
+		return ""
+	}
+	return mediaType
+}
+
+func containsExtension(list []string, ext string) bool {
+	for _, item := range list {
+		item = strings.ToLower(item)
+		if !strings.HasPrefix(item, ".") {
+			item = "." + item
+		}
+		if item == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func matchMimeType(patterns []string, contentType string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), contentType); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"simple-file-server/lib/defs"
+	"testing"
+)
+
+func TestCheckUploadType(t *testing.T) {
+	allowImages := defs.UploadLimits{AllowExtensions: []string{"png", ".JPG"}, AllowMimeTypes: []string{"image/*"}}
+	denyExecutables := defs.UploadLimits{DenyExtensions: []string{".exe"}, DenyMimeTypes: []string{"application/x-msdownload"}}
+	tests := []struct {
+		name         string
+		layers       []defs.UploadLimits
+		path         string
+		contentTypes []string
+		want         int
+	}{
+		{"no rules", []defs.UploadLimits{{}}, "a.bin", []string{"application/zip"}, 0},
+		{"allowed extension", []defs.UploadLimits{allowImages}, "a/b.png", []string{"image/png"}, 0},
+		{"extension case", []defs.UploadLimits{allowImages}, "b.jpg", nil, 0},
+		{"extension not allowed", []defs.UploadLimits{allowImages}, "b.txt", []string{"image/png"}, defs.CodeExtensionNotAllowed},
+		{"no extension", []defs.UploadLimits{allowImages}, "png", nil, defs.CodeExtensionNotAllowed},
+		{"type not allowed", []defs.UploadLimits{allowImages}, "b.png", []string{"text/html"}, defs.CodeContentTypeNotAllowed},
+		{"every type is checked", []defs.UploadLimits{allowImages}, "b.png", []string{"image/png", "text/html"}, defs.CodeContentTypeNotAllowed},
+		{"empty type is skipped", []defs.UploadLimits{allowImages}, "b.png", []string{"", "image/gif"}, 0},
+		{"denied extension", []defs.UploadLimits{denyExecutables}, "setup.EXE", nil, defs.CodeExtensionNotAllowed},
+		{"denied type", []defs.UploadLimits{denyExecutables}, "setup.bin", []string{"application/x-msdownload"}, defs.CodeContentTypeNotAllowed},
+		{"every layer applies", []defs.UploadLimits{{}, allowImages, denyExecutables}, "a.exe", nil, defs.CodeExtensionNotAllowed},
+	}
+	for _, tt := range tests {
+		got := 0
+		if err := checkUploadType(tt.layers, tt.path, tt.contentTypes...); err != nil {
+			got = err.Code
+		}
+		if got != tt.want {
+			t.Errorf("%s: checkUploadType(%q, %q) = %d, want %d", tt.name, tt.path, tt.contentTypes, got, tt.want)
+		}
+	}
+}
+
+func TestSmallestLimit(t *testing.T) {
+	maxSize := func(limits defs.UploadLimits) int64 { return limits.MaxSize }
+	tests := []struct {
+		name   string
+		layers []defs.UploadLimits
+		want   int64
+	}{
+		{"no layers", nil, 0},
+		{"no limits", []defs.UploadLimits{{}, {}}, 0},
+		{"one limit", []defs.UploadLimits{{}, {MaxSize: 100}}, 100},
+		{"smallest wins", []defs.UploadLimits{{MaxSize: 100}, {}, {MaxSize: 50}, {MaxSize: 80}}, 50},
+		{"other limits are ignored", []defs.UploadLimits{{MaxSize: 100, MaxPartSize: 10}}, 100},
+	}
+	for _, tt := range tests {
+		if got := smallestLimit(tt.layers, maxSize); got != tt.want {
+			t.Errorf("%s: smallestLimit = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// multipartRequest builds a POST with fields and the file content as form field file
+func multipartRequest(t *testing.T, fields map[string]string, fileName string, content string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, value := range fields {
+		writer.WriteField(key, value)
+	}
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(content))
+	writer.Close()
+	req := httptest.NewRequest("POST", "/", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestActionUploadLimits(t *testing.T) {
+	root := t.TempDir()
+	useConfig(t, defs.Config{
+		ApiToken: testToken,
+		DataDir:  root,
+		TempDir:  t.TempDir(),
+		Upload:   defs.UploadLimits{MaxSize: 10, DenyExtensions: []string{".exe"}},
+		UploadRules: []defs.UploadRule{
+			{Prefix: "small/", Limits: defs.UploadLimits{MaxSize: 3}},
+		},
+	})
+	upload := func(filePath string, content string) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = multipartRequest(t, map[string]string{"filePath": filePath}, "upload.bin", content)
+		c.Request.Header.Set("admin-api-token", testToken)
+		ActionUpload(c)
+		var res struct {
+			Code int `json:"code"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return res.Code
+	}
+	tests := []struct {
+		path    string
+		content string
+		want    int
+	}{
+		{"other/a.txt", "12345", 0},
+		{"big.txt", "12345678901", defs.CodeFileTooLarge},
+		{"setup.exe", "1", defs.CodeExtensionNotAllowed},
+		// the size limit of a prefix is checked once the form names the path
+		{"small/a.txt", "12345", defs.CodeFileTooLarge},
+		{"small/b.txt", "123", 0},
+	}
+	for _, tt := range tests {
+		if code := upload(tt.path, tt.content); code != tt.want {
+			t.Errorf("upload of %d bytes to %s = %d, want %d", len(tt.content), tt.path, code, tt.want)
+		}
+		_, err := os.Stat(filepath.Join(root, filepath.FromSlash(tt.path)))
+		if stored := err == nil; stored != (tt.want == 0) {
+			t.Errorf("%s stored: %v", tt.path, stored)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"os"
+	"simple-file-server/global"
+	"simple-file-server/lib/files"
+	sfslog "simple-file-server/lib/log"
+	"strings"
+)
+
+const unixPrefix = "unix:"
+
+// newEngine returns an engine with its own request id, access log, recovery and CORS middleware
+func newEngine(accessLogPath string) *gin.Engine {
+	r := gin.New()
+	// forwarded headers are spoofable, only the ones set by trustedProxies are used for the client ip
+	if len(global.Config().TrustedProxies) > 0 {
+		if err := r.SetTrustedProxies(global.Config().TrustedProxies); err != nil {
+			log.Fatal("Invalid trustedProxies: ", err)
+		}
+	} else {
+		r.SetTrustedProxies(nil)
+	}
+	r.Use(requestId, accessLog(sfslog.NewAccessWriter(accessLogPath)), gin.Recovery(), cors)
+	return r
+}
+
+func publicListen() string {
+	config := global.Config()
+	if config.Listen != "" {
+		return config.Listen
+	}
+	return fmt.Sprintf(":%d", config.Port)
+}
+
+// listen opens addr, either a tcp address or "unix:/path/to/socket"
+func listen(addr string) (net.Listener, error) {
+	if !strings.HasPrefix(addr, unixPrefix) {
+		return net.Listen("tcp", addr)
+	}
+	path := strings.TrimPrefix(addr, unixPrefix)
+	// remove the socket left by a previous run
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := files.Chmod(path, global.Config().AdminSocketMode, "0660"); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// startServer serves handler on addr in the background, over TLS when tlsConfig is set and addr is not a unix socket
+func startServer(name string, addr string, handler http.Handler, tlsConfig *tls.Config) *http.Server {
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
+	ln, err := listen(addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		log.Info(name, " listening on ", addr)
+		if strings.HasPrefix(addr, unixPrefix) || tlsConfig == nil {
+			err = srv.Serve(ln)
+		} else {
+			srv.TLSConfig = tlsConfig.Clone()
+			if global.Config().Tls.DisableHttp2 {
+				srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+			}
+			err = srv.ServeTLS(ln, "", "")
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	return srv
+}
+
+func isUnixRequest(c *gin.Context) bool {
+	addr, ok := c.Request.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr.Network() == "unix"
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"simple-file-server/lib/defs"
+	"strconv"
+	"testing"
+)
+
+func TestPublicListen(t *testing.T) {
+	useConfig(t, defs.Config{Port: 8080})
+	if addr := publicListen(); addr != ":8080" {
+		t.Errorf("publicListen = %q, want :8080", addr)
+	}
+	useConfig(t, defs.Config{Port: 8080, Listen: "127.0.0.1:9090"})
+	if addr := publicListen(); addr != "127.0.0.1:9090" {
+		t.Errorf("publicListen = %q, want the listen address", addr)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	useConfig(t, defs.Config{})
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	ln, err := listen(unixPrefix + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0660 {
+		t.Errorf("socket mode = %v, %v, want 0660", info.Mode().Perm(), err)
+	}
+	// the socket left by a previous run is replaced
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	useConfig(t, defs.Config{AdminSocketMode: "0600"})
+	ln, err = listen(unixPrefix + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, want adminSocketMode 0600", info.Mode().Perm())
+	}
+
+	if _, err := listen(unixPrefix + filepath.Join(t.TempDir(), "missing", "admin.sock")); err == nil {
+		t.Error("listen in a missing directory returned no error")
+	}
+}
+
+func TestStartServerUnix(t *testing.T) {
+	useConfig(t, defs.Config{})
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	r := gin.New()
+	r.GET("/unix", func(c *gin.Context) {
+		c.String(200, strconv.FormatBool(isUnixRequest(c)))
+	})
+	srv := startServer("Admin", unixPrefix+path, r, nil)
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	res, err := client.Get("http://admin/unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if body, _ := io.ReadAll(res.Body); string(body) != "true" {
+		t.Errorf("isUnixRequest over the socket = %s, want true", body)
+	}
+}
@@ -0,0 +1,405 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"reflect"
+	"simple-file-server/lib/defs"
+	"simple-file-server/lib/files"
+	"simple-file-server/lib/version"
+	"simple-file-server/module"
+	"strings"
+	"sync"
+)
+
+// apiOperation documents a route, the openapi document is built from apiOperations
+// and TestApiOperations makes sure every registered route is in it and nothing else
+type apiOperation struct {
+	Method  string
+	Path    string
+	Tag     string
+	Summary string
+	// Auth lists the security schemes accepted, empty for public routes
+	Auth []string
+	// Body is a zero value of the JSON request body
+	Body interface{}
+	// Form lists the fields of a multipart form body in the order they are sent
+	Form  []apiParam
+	Query []apiParam
+	// Data describes the data of the response, see schemaOf
+	Data interface{}
+	// Binary is the content type of a response that is not the JSON envelope
+	Binary string
+	// NoRoute operations are served by the NoRoute handler and have no route
+	NoRoute bool
+}
+
+type apiParam struct {
+	Name        string
+	Type        string
+	Required    bool
+	Description string
+}
+
+// apiObject describes a gin.H response, the values are described by schemaOf
+type apiObject map[string]interface{}
+
+type openApiSchema map[string]interface{}
+
+const (
+	authAdmin  = "adminToken"
+	authTicket = "uploadTicket"
+)
+
+var (
+	authToken        = []string{authAdmin}
+	authTokenTicket  = []string{authAdmin, authTicket}
+	uploadIdResponse = apiObject{"uploadId": ""}
+	filePathResponse = apiObject{"filePath": ""}
+	bucketResponse   = apiObject{
+		"name":        "",
+		"host":        "",
+		"tokens":      []apiObject{{"name": "", "upload": defs.UploadLimits{}}},
+		"quota":       int64(0),
+		"usage":       int64(0),
+		"accessRules": []defs.AccessRule{},
+		"webhook":     apiObject{"url": "", "events": []string{}, "maxRetries": 0},
+		"createdAt":   int64(0),
+	}
+	backlogResponse = openApiSchema{
+		"type":                 "object",
+		"description":          "queued and failed events by name",
+		"additionalProperties": apiObject{"queued": 0, "failed": 0},
+	}
+)
+
+var apiOperations = []apiOperation{
+	{Method: "GET", Path: "/_admin/ping", Tag: "server", Summary: "Check the server is up"},
+	{Method: "GET", Path: "/_admin/health", Tag: "server", Summary: "Storage, scheduler and backlog checks with version and platform, 503 when a check fails", Auth: authToken,
+		Data: defs.HealthReport{}},
+	{Method: "GET", Path: "/_admin/ready", Tag: "server", Summary: "Readiness probe, 503 while dataDir or tempDir is missing or full",
+		Data: defs.ReadyReport{}},
+	{Method: "GET", Path: "/_admin/openapi.json", Tag: "server", Summary: "This document", Auth: authToken, Binary: "application/json"},
+	{Method: "POST", Path: "/_admin/upload", Tag: "upload", Summary: "Upload a file in a single request", Auth: authTokenTicket,
+		Form: []apiParam{
+			{Name: "filePath", Type: "string", Required: true},
+			{Name: "mtime", Type: "integer", Description: "modification time in unix seconds"},
+			{Name: "file", Type: "file", Required: true},
+		},
+		Data: filePathResponse},
+	{Method: "POST", Path: "/_admin/upload/multipart_init", Tag: "upload", Summary: "Start a multipart upload", Auth: authTokenTicket,
+		Body: multipartInitRequest{}, Data: uploadIdResponse},
+	{Method: "POST", Path: "/_admin/upload/multipart_upload", Tag: "upload", Summary: "Upload one part, parts can be sent in parallel", Auth: authTokenTicket,
+		Form: []apiParam{
+			{Name: "uploadId", Type: "string", Required: true},
+			{Name: "partNumber", Type: "integer", Required: true, Description: "from 1 to totalParts"},
+			{Name: "file", Type: "file", Required: true},
+		}},
+	{Method: "POST", Path: "/_admin/upload/multipart_status", Tag: "upload", Summary: "List the parts received so far", Auth: authTokenTicket,
+		Body: uploadIdRequest{},
+		Data: apiObject{"uploadId": "", "filePath": "", "totalParts": 0, "totalSize": int64(0), "parts": []apiObject{{"partNumber": 0, "size": int64(0)}}}},
+	{Method: "POST", Path: "/_admin/upload/multipart_end", Tag: "upload", Summary: "Join the parts into the file", Auth: authTokenTicket,
+		Body: uploadIdRequest{}, Data: filePathResponse},
+	{Method: "POST", Path: "/_admin/upload/abort", Tag: "upload", Summary: "Abort a multipart upload and remove its parts", Auth: authTokenTicket,
+		Body: uploadIdRequest{}},
+	{Method: "POST", Path: "/_admin/upload/ticket", Tag: "upload", Summary: "Mint a one-time upload ticket for browsers", Auth: authToken,
+		Body: ticketRequest{}, Data: apiObject{"ticket": "", "prefix": "", "maxSize": int64(0), "expiresAt": int64(0)}},
+	{Method: "POST", Path: "/_admin/upload/policy", Tag: "upload", Summary: "Sign a policy for HTML form uploads to /_upload", Auth: authToken,
+		Body: policyRequest{}, Data: apiObject{"policy": "", "signature": "", "expiration": int64(0)}},
+	{Method: "POST", Path: "/_admin/sign", Tag: "files", Summary: "Sign a temporary url of a private file", Auth: authToken,
+		Body: signRequest{}, Data: apiObject{"url": "", "expires": int64(0)}},
+	{Method: "POST", Path: "/_admin/has", Tag: "files", Summary: "Check a file exists", Auth: authToken,
+		Body: pathRequest{}, Data: false},
+	{Method: "POST", Path: "/_admin/size", Tag: "files", Summary: "Size of a file in bytes", Auth: authToken,
+		Body: pathRequest{}, Data: apiObject{"size": int64(0)}},
+	{Method: "POST", Path: "/_admin/list", Tag: "files", Summary: "List a directory", Auth: authToken,
+		Body: listRequest{}, Data: []ListItem{}},
+	{Method: "GET", Path: "/_admin/get", Tag: "files", Summary: "Download a file, or a directory as zip", Auth: authToken,
+		Query: []apiParam{
+			{Name: "path", Type: "string", Required: true},
+			{Name: "zip", Type: "boolean"},
+		},
+		Binary: "application/octet-stream"},
+	{Method: "POST", Path: "/_admin/get", Tag: "files", Summary: "Download a file, or a directory as zip", Auth: authToken,
+		Body: getRequest{}, Binary: "application/octet-stream"},
+	{Method: "POST", Path: "/_admin/move", Tag: "files", Summary: "Move or rename a file or directory", Auth: authToken,
+		Body: moveRequest{}},
+	{Method: "POST", Path: "/_admin/delete", Tag: "files", Summary: "Delete a file or directory", Auth: authToken,
+		Body: deleteRequest{}, Data: openApiSchema{"description": "{} or the paths that would be deleted when dryRun is set",
+			"oneOf": []interface{}{schemaOf(apiObject{}), schemaOf(apiObject{"dryRun": true, "paths": []string{}})}}},
+	{Method: "POST", Path: "/_admin/mkdir", Tag: "files", Summary: "Create a directory and its parents", Auth: authToken,
+		Body: pathRequest{}, Data: apiObject{"path": ""}},
+	{Method: "POST", Path: "/_admin/hash", Tag: "files", Summary: "Hashes of a file, cached in its metadata", Auth: authToken,
+		Body: hashRequest{},
+		Data: apiObject{"path": "", "size": int64(0), "md5": "", "sha1": "", "sha256": "", "crc32c": "", "cached": false, "corrupt": false}},
+	{Method: "POST", Path: "/_admin/archive", Tag: "archive", Summary: "Stream paths or a prefix as zip or tar.gz", Auth: authToken,
+		Body: archiveRequest{}, Binary: "application/octet-stream"},
+	{Method: "POST", Path: "/_admin/extract", Tag: "archive", Summary: "Upload an archive and extract it", Auth: authToken,
+		Form: []apiParam{
+			{Name: "target", Type: "string", Description: "directory to extract into"},
+			{Name: "format", Type: "string", Description: "zip, tar or tar.gz, by default from the file name"},
+			{Name: "overwrite", Type: "boolean"},
+			{Name: "file", Type: "file", Required: true},
+		},
+		Data: apiObject{"entries": []files.ExtractResult{}}},
+	{Method: "GET", Path: "/_admin/scrub", Tag: "server", Summary: "Report of the last integrity scrub", Auth: authToken,
+		Data: module.ScrubReport{}},
+	{Method: "GET", Path: "/_admin/replication", Tag: "server", Summary: "Replication backlog by replica", Auth: authToken,
+		Data: backlogResponse},
+	{Method: "POST", Path: "/_admin/bucket/create", Tag: "buckets", Summary: "Create a bucket or update its definition", Auth: authToken,
+		Body: defs.Bucket{}, Data: bucketResponse},
+	{Method: "GET", Path: "/_admin/bucket/list", Tag: "buckets", Summary: "List the buckets", Auth: authToken,
+		Data: []apiObject{bucketResponse}},
+	{Method: "POST", Path: "/_admin/bucket/delete", Tag: "buckets", Summary: "Delete a bucket", Auth: authToken,
+		Body: bucketDeleteRequest{}},
+	{Method: "POST", Path: "/_upload", Tag: "public", Summary: "Upload from an HTML form with a signed policy, file must be the last field",
+		Form: []apiParam{
+			{Name: "key", Type: "string", Required: true, Description: "${filename} is replaced by the name of the uploaded file"},
+			{Name: "policy", Type: "string", Required: true},
+			{Name: "signature", Type: "string", Required: true},
+			{Name: "Content-Type", Type: "string"},
+			{Name: "file", Type: "file", Required: true},
+		},
+		Data: apiObject{"key": ""}},
+	{Method: "GET", Path: "/{path}", Tag: "public", Summary: "Serve a public file, site or directory index", NoRoute: true,
+		Query: []apiParam{
+			{Name: "token", Type: "string", Description: "access token of a token mode prefix"},
+			{Name: "expires", Type: "integer", Description: "see /_admin/sign"},
+			{Name: "signature", Type: "string", Description: "see /_admin/sign"},
+			{Name: "download", Type: "string", Description: "send as attachment, optionally with this file name"},
+		},
+		Binary: "application/octet-stream"},
+}
+
+var (
+	apiDoc     gin.H
+	apiDocOnce sync.Once
+)
+
+func ActionOpenApi(c *gin.Context) {
+	if !checkAdminToken(c) {
+		return
+	}
+	apiDocOnce.Do(func() {
+		apiDoc = openApiDoc()
+	})
+	c.JSON(200, apiDoc)
+}
+
+func openApiDoc() gin.H {
+	var errorNames []string
+	for _, errorCode := range defs.ErrorCodes {
+		errorNames = append(errorNames, errorCode.Name)
+	}
+	paths := gin.H{}
+	for _, op := range apiOperations {
+		item, ok := paths[op.Path].(gin.H)
+		if !ok {
+			item = gin.H{}
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = apiOperationDoc(op)
+	}
+	return gin.H{
+		"openapi": "3.0.3",
+		"info": gin.H{
+			"title":       "Simple File Server",
+			"version":     version.VERSION,
+			"description": "Errors answer the status of their code with the Response envelope, the X-Request-Id header carries the request id.",
+		},
+		"paths": paths,
+		"components": gin.H{
+			"securitySchemes": gin.H{
+				authAdmin:  gin.H{"type": "apiKey", "in": "header", "name": "admin-api-token"},
+				authTicket: gin.H{"type": "apiKey", "in": "header", "name": "upload-ticket"},
+			},
+			"schemas": gin.H{
+				"Response": gin.H{
+					"type":     "object",
+					"required": []string{"code", "msg", "data"},
+					"properties": gin.H{
+						"code":      gin.H{"type": "integer", "description": "0 on success"},
+						"error":     gin.H{"type": "string", "enum": errorNames},
+						"msg":       gin.H{"type": "string"},
+						"data":      gin.H{},
+						"requestId": gin.H{"type": "string"},
+					},
+				},
+				"ErrorCodes": gin.H{
+					"description": "the error code catalog",
+					"type":        "array",
+					"items":       schemaOf(defs.ErrorCode{}),
+					"example":     defs.ErrorCodes,
+				},
+			},
+			"responses": gin.H{
+				"Error": gin.H{
+					"description": "error, see ErrorCodes",
+					"content": gin.H{
+						"application/json": gin.H{"schema": gin.H{"$ref": "#/components/schemas/Response"}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func apiOperationDoc(op apiOperation) gin.H {
+	doc := gin.H{
+		"tags":        []string{op.Tag},
+		"summary":     op.Summary,
+		"operationId": operationId(op),
+		"responses":   gin.H{"default": gin.H{"$ref": "#/components/responses/Error"}},
+	}
+	security := []gin.H{}
+	for _, name := range op.Auth {
+		security = append(security, gin.H{name: []string{}})
+	}
+	doc["security"] = security
+	var parameters []gin.H
+	if strings.Contains(op.Path, "{path}") {
+		parameters = append(parameters, gin.H{"name": "path", "in": "path", "required": true, "schema": gin.H{"type": "string"}})
+	}
+	for _, p := range op.Query {
+		parameters = append(parameters, gin.H{"name": p.Name, "in": "query", "required": p.Required,
+			"description": p.Description, "schema": gin.H{"type": p.Type}})
+	}
+	if parameters != nil {
+		doc["parameters"] = parameters
+	}
+	if op.Body != nil {
+		doc["requestBody"] = gin.H{
+			"required": true,
+			"content":  gin.H{"application/json": gin.H{"schema": schemaOf(op.Body)}},
+		}
+	} else if op.Form != nil {
+		properties := gin.H{}
+		var required []string
+		for _, p := range op.Form {
+			schema := gin.H{"type": p.Type, "description": p.Description}
+			if p.Type == "file" {
+				schema = gin.H{"type": "string", "format": "binary"}
+			}
+			properties[p.Name] = schema
+			if p.Required {
+				required = append(required, p.Name)
+			}
+		}
+		doc["requestBody"] = gin.H{
+			"required": true,
+			"content": gin.H{"multipart/form-data": gin.H{"schema": gin.H{
+				"type": "object", "properties": properties, "required": required,
+			}}},
+		}
+	}
+	var content gin.H
+	if op.Binary != "" {
+		schema := gin.H{"type": "string", "format": "binary"}
+		if op.Binary == "application/json" {
+			schema = gin.H{"type": "object"}
+		}
+		content = gin.H{op.Binary: gin.H{"schema": schema}}
+	} else {
+		data := schemaOf(op.Data)
+		if op.Data == nil {
+			data = schemaOf(apiObject{})
+		}
+		content = gin.H{"application/json": gin.H{"schema": gin.H{"allOf": []gin.H{
+			{"$ref": "#/components/schemas/Response"},
+			{"properties": gin.H{"data": data}},
+		}}}}
+	}
+	doc["responses"].(gin.H)["200"] = gin.H{"description": "ok", "content": content}
+	return doc
+}
+
+// operationId is the path without the _admin prefix in camel case, with the method when a path has several
+func operationId(op apiOperation) string {
+	p := strings.TrimPrefix(strings.TrimPrefix(op.Path, "/_admin"), "/")
+	switch p {
+	case "{path}":
+		p = "serve_file"
+	case "_upload":
+		// the public form upload, it would clash with /_admin/upload
+		p = "post_upload"
+	}
+	id := ""
+	for i, word := range strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == '_' || r == '.' }) {
+		if i > 0 {
+			word = strings.ToUpper(word[:1]) + word[1:]
+		}
+		id += word
+	}
+	if op.Path == "/_admin/get" {
+		id += strings.ToUpper(op.Method[:1]) + strings.ToLower(op.Method[1:])
+	}
+	return id
+}
+
+// schemaOf describes v, an openApiSchema, an apiObject, a slice of one apiObject or a zero value of a Go type
+func schemaOf(v interface{}) openApiSchema {
+	switch v := v.(type) {
+	case nil:
+		return openApiSchema{}
+	case openApiSchema:
+		for key, value := range v {
+			if object, ok := value.(apiObject); ok {
+				v[key] = schemaOf(object)
+			}
+		}
+		return v
+	case apiObject:
+		properties := openApiSchema{}
+		for name, value := range v {
+			properties[name] = schemaOf(value)
+		}
+		return openApiSchema{"type": "object", "properties": properties}
+	case []apiObject:
+		return openApiSchema{"type": "array", "items": schemaOf(v[0])}
+	}
+	return typeSchema(reflect.TypeOf(v))
+}
+
+func typeSchema(t reflect.Type) openApiSchema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return openApiSchema{"type": "string"}
+	case reflect.Bool:
+		return openApiSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return openApiSchema{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return openApiSchema{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return openApiSchema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return openApiSchema{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return openApiSchema{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := openApiSchema{}
+		addFields(properties, t)
+		return openApiSchema{"type": "object", "properties": properties}
+	}
+	return openApiSchema{}
+}
+
+// addFields adds the fields of struct t as encoding/json names them, embedded structs are flattened
+func addFields(properties openApiSchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addFields(properties, field.Type)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = typeSchema(field.Type)
+	}
+}
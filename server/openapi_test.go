@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestApiOperations fails when a route is added without documenting it in apiOperations, or the other way round
+func TestApiOperations(t *testing.T) {
+	r := gin.New()
+	registerAdminRoutes(r)
+	registerPublicRoutes(r)
+	documented := map[string]bool{}
+	for _, op := range apiOperations {
+		if !op.NoRoute {
+			documented[op.Method+" "+op.Path] = true
+		}
+	}
+	for _, route := range r.Routes() {
+		key := route.Method + " " + route.Path
+		if !documented[key] {
+			t.Errorf("route %s is not documented in apiOperations", key)
+		}
+		delete(documented, key)
+	}
+	for key := range documented {
+		t.Errorf("apiOperations documents %s, which is not registered", key)
+	}
+}
+
+func TestOperationIdsAreUnique(t *testing.T) {
+	seen := map[string]string{}
+	for _, op := range apiOperations {
+		id := operationId(op)
+		if other, ok := seen[id]; ok {
+			t.Errorf("operationId %q of %s %s is also used by %s", id, op.Method, op.Path, other)
+		}
+		seen[id] = op.Method + " " + op.Path
+	}
+}
+
+func TestActionOpenApi(t *testing.T) {
+	useDataDir(t)
+	w := getAdmin(ActionOpenApi, "/_admin/openapi.json", nil)
+	var doc struct {
+		OpenApi string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil || w.Code != 200 {
+		t.Fatalf("ActionOpenApi = %d %v", w.Code, err)
+	}
+	if !strings.HasPrefix(doc.OpenApi, "3.") {
+		t.Errorf("openapi version %q", doc.OpenApi)
+	}
+	for _, op := range apiOperations {
+		if doc.Paths[op.Path][strings.ToLower(op.Method)]["operationId"] != operationId(op) {
+			t.Errorf("%s %s is missing from the document", op.Method, op.Path)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/_admin/openapi.json", nil)
+	ActionOpenApi(c)
+	if strings.Contains(w.Body.String(), `"paths"`) {
+		t.Error("the document was served without the admin token")
+	}
+}
+
+func TestTypeSchema(t *testing.T) {
+	type Base struct {
+		Inner string `json:"inner"`
+	}
+	type example struct {
+		Base
+		Name    string          `json:"name,omitempty"`
+		Size    int64           `json:"size"`
+		Count   int             `json:"count"`
+		Tags    []string        `json:"tags"`
+		Labels  map[string]bool `json:"labels"`
+		Parent  *Base           `json:"parent"`
+		Skipped string          `json:"-"`
+		Plain   float64
+		hidden  string
+	}
+	got, _ := json.Marshal(typeSchema(reflect.TypeOf(example{})))
+	want := `{"properties":{"Plain":{"type":"number"},"count":{"type":"integer"},"inner":{"type":"string"},` +
+		`"labels":{"additionalProperties":{"type":"boolean"},"type":"object"},"name":{"type":"string"},` +
+		`"parent":{"properties":{"inner":{"type":"string"}},"type":"object"},"size":{"format":"int64","type":"integer"},` +
+		`"tags":{"items":{"type":"string"},"type":"array"}},"type":"object"}`
+	if string(got) != want {
+		t.Errorf("typeSchema = %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,281 @@
+package server
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"simple-file-server/global"
+	"simple-file-server/lib/common"
+	"simple-file-server/lib/defs"
+	sfserrors "simple-file-server/lib/errors"
+	"simple-file-server/lib/files"
+	"simple-file-server/lib/response"
+	"simple-file-server/module"
+	"strings"
+	"time"
+)
+
+// PostPolicy is what a signed HTML form may upload, similar to an S3 POST policy
+type PostPolicy struct {
+	Expiration int64  `json:"expiration"`
+	KeyPrefix  string `json:"keyPrefix"`
+	MinSize    int64  `json:"minSize"`
+	MaxSize    int64  `json:"maxSize"`
+	// ContentType is an exact type like "image/png" or a prefix ending in "/" like "image/"
+	ContentType string `json:"contentType"`
+	// Redirect is where the browser is sent with ?key= after the upload, instead of a json response
+	Redirect      string `json:"redirect"`
+	SuccessStatus int    `json:"successStatus"`
+	// TokenName is the token the policy was signed with, its upload limits apply
+	TokenName string `json:"tokenName"`
+}
+
+func signPolicy(secret string, encoded string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(encoded))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type policyRequest struct {
+	KeyPrefix     string `json:"keyPrefix"`
+	MinSize       int64  `json:"minSize"`
+	MaxSize       int64  `json:"maxSize"`
+	ContentType   string `json:"contentType"`
+	Redirect      string `json:"redirect"`
+	SuccessStatus int    `json:"successStatus"`
+	Ttl           int64  `json:"ttl"`
+}
+
+func ActionUploadPolicy(c *gin.Context) {
+	if !checkAdminToken(c) {
+		return
+	}
+	secret := global.Config().PostPolicy.Secret
+	if secret == "" {
+		response.GenerateErrorCode(c, defs.CodeNotConfigured, "postPolicy.secret is not configured")
+		return
+	}
+	var req policyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	keyPrefix := relPath(scopePath(c, req.KeyPrefix))
+	if keyPrefix == "" {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "keyPrefix is required")
+		return
+	}
+	if req.MinSize < 0 || req.MaxSize < 0 || req.MaxSize > 0 && req.MinSize > req.MaxSize {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid minSize or maxSize")
+		return
+	}
+	if req.Redirect != "" && !strings.HasPrefix(req.Redirect, "http://") && !strings.HasPrefix(req.Redirect, "https://") {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid redirect")
+		return
+	}
+	if req.SuccessStatus != 0 && req.SuccessStatus != 200 && req.SuccessStatus != 201 && req.SuccessStatus != 204 {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "successStatus must be 200, 201 or 204")
+		return
+	}
+	if req.Ttl == 0 {
+		req.Ttl = 3600
+	}
+	if req.Ttl < 0 || req.Ttl > global.Config().PostPolicy.MaxTtl {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "ttl must be between 1 and postPolicy.maxTtl")
+		return
+	}
+	policy := PostPolicy{
+		Expiration:    time.Now().Unix() + req.Ttl,
+		KeyPrefix:     keyPrefix,
+		MinSize:       req.MinSize,
+		MaxSize:       req.MaxSize,
+		ContentType:   req.ContentType,
+		Redirect:      req.Redirect,
+		SuccessStatus: req.SuccessStatus,
+		TokenName:     c.GetString("tokenName"),
+	}
+	data, _ := json.Marshal(policy)
+	encoded := base64.StdEncoding.EncodeToString(data)
+	response.GenerateSuccessData(c, gin.H{
+		"policy":     encoded,
+		"signature":  signPolicy(secret, encoded),
+		"expiration": policy.Expiration,
+	})
+}
+
+// ActionPostUpload takes a multipart form with the key, policy and signature fields before the file field.
+// The form is read as a stream, so everything is checked before the first byte of the file is written.
+func ActionPostUpload(c *gin.Context) {
+	secret := global.Config().PostPolicy.Secret
+	if secret == "" {
+		c.AbortWithStatus(404)
+		return
+	}
+	// the token is only known from the verified policy, postUpload applies its limits and the ones of the key to the file
+	limitBody(c, []defs.UploadLimits{global.Config().Upload}, func(limits defs.UploadLimits) int64 { return limits.MaxSize })
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid form")
+		return
+	}
+	fields := map[string]string{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			response.GenerateErrorCode(c, defs.CodeInvalidRequest, "file is required")
+			return
+		}
+		if err != nil {
+			if isBodyTooLarge(err) {
+				response.GenerateBusinessError(c, sfserrors.New(defs.CodeFileTooLarge, "File too large"))
+				return
+			}
+			response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid form")
+			return
+		}
+		if part.FormName() != "file" {
+			value, _ := io.ReadAll(io.LimitReader(part, 64*1024))
+			fields[part.FormName()] = string(value)
+			continue
+		}
+		postUpload(c, secret, fields, part.FileName(), part.Header.Get("Content-Type"), part)
+		return
+	}
+}
+
+func postUpload(c *gin.Context, secret string, fields map[string]string, fileName string, partType string, file io.Reader) {
+	encoded := fields["policy"]
+	if encoded == "" {
+		response.GenerateErrorCode(c, defs.CodeInvalidPolicy, "policy, signature and key must come before the file field")
+		return
+	}
+	if !hmac.Equal([]byte(signPolicy(secret, encoded)), []byte(fields["signature"])) {
+		response.GenerateErrorCode(c, defs.CodeInvalidPolicy, "Invalid policy signature")
+		return
+	}
+	var policy PostPolicy
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || json.Unmarshal(data, &policy) != nil {
+		response.GenerateErrorCode(c, defs.CodeInvalidPolicy, "Invalid policy")
+		return
+	}
+	if policy.Expiration < time.Now().Unix() {
+		response.GenerateErrorCode(c, defs.CodePolicyExpired, "Policy expired")
+		return
+	}
+	c.Set("tokenName", policy.TokenName)
+	// like S3, ${filename} in the key is replaced by the name of the uploaded file
+	key := relPath(scopePath(c, strings.ReplaceAll(fields["key"], "${filename}", path.Base(fileName))))
+	if key == "" || !(key == policy.KeyPrefix || strings.HasPrefix(key, policy.KeyPrefix+"/")) {
+		response.GenerateErrorCode(c, defs.CodePolicyViolation, "key must be below "+unscopePath(c, policy.KeyPrefix))
+		return
+	}
+	if err := checkAccessFileWrite(c, key); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+	contentType := fields["Content-Type"]
+	if contentType == "" {
+		contentType = partType
+	}
+	contentType = declaredType(contentType)
+	if policy.ContentType != "" && !matchPolicyType(policy.ContentType, contentType) {
+		response.GenerateErrorCode(c, defs.CodePolicyViolation, fmt.Sprintf("Content type %q not allowed by policy", contentType))
+		return
+	}
+	layers := append(uploadLimits(c, key), defs.UploadLimits{MaxSize: policy.MaxSize})
+	buffered := bufio.NewReaderSize(file, 512)
+	head, _ := buffered.Peek(512)
+	sniffed := ""
+	if hasTypeRules(layers) {
+		sniffed = sniffBytes(head)
+	}
+	if err := checkUploadType(layers, key, sniffed, contentType); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+
+	tmpDir := filepath.Join(global.Config().TempDir, "PostUpload")
+	files.EnsureDir(tmpDir, "0755")
+	tmpFile := filepath.Join(tmpDir, common.RandomString(32))
+	out, err := os.Create(tmpFile)
+	if err != nil {
+		response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to create file", err))
+		return
+	}
+	defer os.Remove(tmpFile)
+	maxSize := smallestLimit(layers, func(limits defs.UploadLimits) int64 { return limits.MaxSize })
+	var size int64
+	if maxSize > 0 {
+		size, err = io.Copy(out, io.LimitReader(buffered, maxSize+1))
+	} else {
+		size, err = io.Copy(out, buffered)
+	}
+	out.Close()
+	if err != nil {
+		if isBodyTooLarge(err) {
+			response.GenerateBusinessError(c, sfserrors.New(defs.CodeFileTooLarge, "File too large"))
+			return
+		}
+		response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to save file", err))
+		return
+	}
+	if err := checkUploadSize(layers, size); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+	if size < policy.MinSize {
+		response.GenerateErrorCode(c, defs.CodePolicyViolation, fmt.Sprintf("File too small, min %d bytes", policy.MinSize))
+		return
+	}
+	if err := checkQuota(key, size); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+	fullPath := dataPath(key)
+	files.EnsureDir(filepath.Dir(fullPath), "0755")
+	if err := os.Rename(tmpFile, fullPath); err != nil {
+		response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to save file", err))
+		return
+	}
+	saveContentType(key, contentType)
+	addUsage(key, size)
+	emit(module.ReplicationEvent{Op: module.ReplicateUpload, Path: key})
+
+	if policy.Redirect != "" {
+		target, err := url.Parse(policy.Redirect)
+		if err == nil {
+			query := target.Query()
+			query.Set("key", unscopePath(c, key))
+			target.RawQuery = query.Encode()
+			c.Redirect(http.StatusSeeOther, target.String())
+			return
+		}
+	}
+	switch policy.SuccessStatus {
+	case http.StatusNoContent:
+		c.AbortWithStatus(http.StatusNoContent)
+	case http.StatusCreated:
+		response.GenerateWithStatus(c, http.StatusCreated, 0, "ok", gin.H{"key": unscopePath(c, key)})
+	default:
+		response.GenerateSuccessData(c, gin.H{"key": unscopePath(c, key)})
+	}
+}
+
+func matchPolicyType(policyType string, contentType string) bool {
+	if strings.HasSuffix(policyType, "/") {
+		return strings.HasPrefix(contentType, policyType)
+	}
+	return strings.EqualFold(policyType, contentType)
+}
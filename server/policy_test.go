@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"simple-file-server/lib/defs"
+	"testing"
+	"time"
+)
+
+const testPolicySecret = "policysecret-123456"
+
+// postForm sends fields and the file to ActionPostUpload, it returns the status, the response code and the data
+func postForm(t *testing.T, fields map[string]string, content string) (int, int, json.RawMessage) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = multipartRequest(t, fields, "note.txt", content)
+	ActionPostUpload(c)
+	c.Writer.WriteHeaderNow()
+	var res struct {
+		Code int             `json:"code"`
+		Data json.RawMessage `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	return w.Code, res.Code, res.Data
+}
+
+// encodePolicy signs policy like ActionUploadPolicy does
+func encodePolicy(policy PostPolicy) map[string]string {
+	data, _ := json.Marshal(policy)
+	encoded := base64.StdEncoding.EncodeToString(data)
+	return map[string]string{"policy": encoded, "signature": signPolicy(testPolicySecret, encoded)}
+}
+
+func TestPostUpload(t *testing.T) {
+	root := t.TempDir()
+	useConfig(t, defs.Config{
+		ApiToken:   testToken,
+		DataDir:    root,
+		TempDir:    t.TempDir(),
+		Tokens:     []defs.TokenConfig{{Name: "web", Token: "webtoken-123456", Upload: defs.UploadLimits{MaxSize: 3}}},
+		PostPolicy: defs.PostPolicyConfig{Secret: testPolicySecret, MaxTtl: 3600},
+	})
+	code, data := callAdmin(t, ActionUploadPolicy, gin.H{"keyPrefix": "forms", "maxSize": 10, "contentType": "text/"})
+	if code != 0 {
+		t.Fatalf("ActionUploadPolicy = %d", code)
+	}
+	var signed map[string]interface{}
+	json.Unmarshal(data, &signed)
+	form := func(key string, contentType string) map[string]string {
+		return map[string]string{
+			"policy":       signed["policy"].(string),
+			"signature":    signed["signature"].(string),
+			"key":          key,
+			"Content-Type": contentType,
+		}
+	}
+
+	status, code, data := postForm(t, form("forms/${filename}", "text/plain"), "hello")
+	if status != 200 || code != 0 || string(data) != `{"key":"forms/note.txt"}` {
+		t.Fatalf("form upload = %d, %d, %s", status, code, data)
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "forms", "note.txt")); string(content) != "hello" {
+		t.Errorf("stored content = %q", content)
+	}
+
+	tampered := form("forms/a.txt", "text/plain")
+	tampered["signature"] = signPolicy("othersecret-123456", tampered["policy"])
+	tests := []struct {
+		name    string
+		fields  map[string]string
+		content string
+		want    int
+	}{
+		{"bad signature", tampered, "hello", defs.CodeInvalidPolicy},
+		{"key outside the prefix", form("other/a.txt", "text/plain"), "hello", defs.CodePolicyViolation},
+		{"content type of the policy", form("forms/a.png", "image/png"), "hello", defs.CodePolicyViolation},
+		{"maxSize of the policy", form("forms/big.txt", "text/plain"), "01234567890", defs.CodeFileTooLarge},
+		{"expired policy", encodePolicy(PostPolicy{Expiration: time.Now().Unix() - 1, KeyPrefix: "forms"}), "hello", defs.CodePolicyExpired},
+		// the limits of the token the policy was signed with apply
+		{"token limits", encodePolicy(PostPolicy{Expiration: time.Now().Unix() + 60, KeyPrefix: "forms", TokenName: "web"}), "hello", defs.CodeFileTooLarge},
+	}
+	for _, tt := range tests {
+		if tt.fields["key"] == "" {
+			tt.fields["key"] = "forms/" + tt.name + ".txt"
+		}
+		if _, code, _ := postForm(t, tt.fields, tt.content); code != tt.want {
+			t.Errorf("%s: code %d, want %d", tt.name, code, tt.want)
+		}
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(tt.fields["key"]))); err == nil {
+			t.Errorf("%s: the file was stored", tt.name)
+		}
+	}
+
+	useConfig(t, defs.Config{DataDir: root, TempDir: t.TempDir()})
+	if status, _, _ := postForm(t, form("forms/a.txt", "text/plain"), "hello"); status != 404 {
+		t.Errorf("form upload without postPolicy.secret = %d, want 404", status)
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"simple-file-server/global"
+	"simple-file-server/lib/common"
+	"simple-file-server/lib/files"
+	"simple-file-server/lib/meta"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// proxyClient is replaced as a whole at startup and on reload, requests only load it
+var proxyClient atomic.Pointer[http.Client]
+
+// loadProxyClient builds the client for the timeout of the current config
+func loadProxyClient() {
+	proxyClient.Store(newProxyClient(time.Duration(global.Config().Proxy.Timeout) * time.Second))
+}
+
+// newProxyClient applies timeout to connecting and to waiting for the response headers,
+// the body of a large file may take as long as it needs
+func newProxyClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = timeout
+	transport.ResponseHeaderTimeout = timeout
+	return &http.Client{Transport: transport}
+}
+
+// proxyServe handles the pull-through cache for rel, it returns false when the local file should be served
+func proxyServe(c *gin.Context, rel string, fullPath string) bool {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+	config := global.Config().Proxy
+	now := time.Now().Unix()
+	m, _ := meta.Get(rel)
+	info, err := os.Stat(fullPath)
+	if err == nil {
+		if info.IsDir() || !m.Proxied {
+			return false
+		}
+		if config.Ttl == 0 || now-m.CachedAt < config.Ttl {
+			touchProxied(rel, m, now)
+			return false
+		}
+	} else if m.NotFoundAt > 0 && now-m.NotFoundAt < config.NegativeTtl {
+		c.AbortWithStatus(404)
+		return true
+	}
+	stale := err == nil
+	upstreamUrl := strings.TrimRight(config.Upstream, "/") + "/" + (&url.URL{Path: rel}).EscapedPath()
+	resp, err := proxyClient.Load().Get(upstreamUrl)
+	if err != nil {
+		requestLog(c).Warn("ProxyFetch:", rel, " ", err)
+		if stale {
+			return false
+		}
+		c.AbortWithStatus(502)
+		return true
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		if stale {
+			os.Remove(fullPath)
+			files.PruneEmptyDirs(filepath.Dir(fullPath), global.Config().DataDir)
+		}
+		meta.Save(rel, meta.FileMeta{NotFoundAt: now})
+		c.AbortWithStatus(404)
+		return true
+	}
+	if resp.StatusCode != http.StatusOK {
+		requestLog(c).Warn("ProxyFetch:", rel, " status ", resp.StatusCode)
+		if stale {
+			return false
+		}
+		c.AbortWithStatus(502)
+		return true
+	}
+	tmpDir := filepath.Join(global.Config().TempDir, "Proxy")
+	files.EnsureDir(tmpDir, "0755")
+	tmpFile := filepath.Join(tmpDir, common.RandomString(32))
+	out, err := os.Create(tmpFile)
+	if err != nil {
+		requestLog(c).Error("ProxyCreate:", err)
+		c.AbortWithStatus(500)
+		return true
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	if resp.ContentLength >= 0 {
+		c.Header("Content-Length", fmt.Sprint(resp.ContentLength))
+	}
+	c.Header("Server", "Simple-File-Server")
+	setFileHeaders(c, rel)
+	c.Status(200)
+	// keep filling the cache even if the client goes away
+	n, err := io.Copy(out, io.TeeReader(resp.Body, &tolerantWriter{w: c.Writer}))
+	out.Close()
+	if err != nil || (resp.ContentLength >= 0 && n != resp.ContentLength) {
+		requestLog(c).Warn("ProxyFetchIncomplete:", rel, " ", err)
+		os.Remove(tmpFile)
+		return true
+	}
+	files.EnsureDir(filepath.Dir(fullPath), "0755")
+	if err := os.Rename(tmpFile, fullPath); err != nil {
+		requestLog(c).Error("ProxyStore:", err)
+		os.Remove(tmpFile)
+		return true
+	}
+	if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		os.Chtimes(fullPath, lastModified, lastModified)
+	}
+	meta.Save(rel, meta.FileMeta{
+		ContentType: contentType,
+		Proxied:     true,
+		CachedAt:    now,
+		AccessedAt:  now,
+	})
+	return true
+}
+
+// touchProxied records the access time used for LRU eviction, at most once a minute per file
+func touchProxied(rel string, m meta.FileMeta, now int64) {
+	if now-m.AccessedAt < 60 {
+		return
+	}
+	m.AccessedAt = now
+	meta.Save(rel, m)
+}
+
+// tolerantWriter swallows write errors so a disconnected client does not abort the copy
+type tolerantWriter struct {
+	w      io.Writer
+	failed bool
+}
+
+func (t *tolerantWriter) Write(p []byte) (int, error) {
+	if !t.failed {
+		if _, err := t.w.Write(p); err != nil {
+			t.failed = true
+		}
+	}
+	return len(p), nil
+}
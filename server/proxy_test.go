@@ -0,0 +1,71 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"simple-file-server/lib/defs"
+	"simple-file-server/lib/meta"
+	"sync/atomic"
+	"testing"
+)
+
+func TestProxyServe(t *testing.T) {
+	var fetches atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if r.URL.Path != "/dir/a b.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/x-test")
+		w.Write([]byte("upstream"))
+	}))
+	defer upstream.Close()
+	root := t.TempDir()
+	useConfig(t, defs.Config{DataDir: root, TempDir: t.TempDir(), Proxy: defs.ProxyConfig{Upstream: upstream.URL + "/", NegativeTtl: 60, Timeout: 5}})
+	loadProxyClient()
+	serve := func(rel string) (bool, *httptest.ResponseRecorder) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/", nil)
+		served := proxyServe(c, rel, dataPath(rel))
+		c.Writer.WriteHeaderNow()
+		return served, w
+	}
+
+	served, w := serve("dir/a b.txt")
+	if !served || w.Code != 200 || w.Body.String() != "upstream" || w.Header().Get("Content-Type") != "text/x-test" {
+		t.Fatalf("first request: served %v, %d %q", served, w.Code, w.Body.String())
+	}
+	if data, _ := os.ReadFile(dataPath("dir/a b.txt")); string(data) != "upstream" {
+		t.Errorf("the cached file holds %q", data)
+	}
+	if m, _ := meta.Get("dir/a b.txt"); !m.Proxied || m.ContentType != "text/x-test" {
+		t.Errorf("metadata of the cached file = %+v", m)
+	}
+	// cached files are served locally while the ttl lasts, 0 keeps them forever
+	if served, _ := serve("dir/a b.txt"); served || fetches.Load() != 1 {
+		t.Errorf("second request: served %v after %d fetches", served, fetches.Load())
+	}
+
+	// a 404 of the upstream is remembered for negativeTtl
+	if served, w := serve("missing.txt"); !served || w.Code != 404 {
+		t.Errorf("missing file: served %v, %d", served, w.Code)
+	}
+	if served, w := serve("missing.txt"); !served || w.Code != 404 || fetches.Load() != 2 {
+		t.Errorf("missing file again: served %v, %d after %d fetches", served, w.Code, fetches.Load())
+	}
+
+	// files that were not fetched by the proxy are never touched
+	writeFiles(t, root, "local.txt")
+	if served, _ := serve("local.txt"); served || fetches.Load() != 2 {
+		t.Errorf("local file: served %v after %d fetches", served, fetches.Load())
+	}
+
+	upstream.Close()
+	if served, w := serve("other.txt"); !served || w.Code != 502 {
+		t.Errorf("unreachable upstream: served %v, %d", served, w.Code)
+	}
+}
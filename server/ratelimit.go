@@ -0,0 +1,105 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"io"
+	"math"
+	"net/http"
+	"simple-file-server/global"
+	"simple-file-server/lib/defs"
+	"simple-file-server/lib/response"
+	"simple-file-server/lib/throttle"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// limiters are rebuilt whenever the config they were built from changes, so SIGHUP applies new limits
+var limiters struct {
+	lock      sync.Mutex
+	rateLimit defs.RateLimitConfig
+	bandwidth defs.BandwidthConfig
+	perIp     *throttle.Group
+	perToken  *throttle.Group
+	global    *throttle.Limiter
+	built     bool
+}
+
+func currentLimiters() (*throttle.Group, *throttle.Group, *throttle.Limiter) {
+	limiters.lock.Lock()
+	defer limiters.lock.Unlock()
+	config := global.Config()
+	rateLimit := config.RateLimit
+	bandwidth := config.Bandwidth
+	if !limiters.built || limiters.rateLimit != rateLimit || limiters.bandwidth != bandwidth {
+		limiters.perIp, limiters.perToken, limiters.global = nil, nil, nil
+		if rateLimit.PerIp > 0 {
+			limiters.perIp = throttle.NewGroup(rateLimit.PerIp, rateLimit.PerIpBurst)
+		}
+		if rateLimit.PerToken > 0 {
+			limiters.perToken = throttle.NewGroup(rateLimit.PerToken, rateLimit.PerTokenBurst)
+		}
+		if bandwidth.Global > 0 {
+			limiters.global = throttle.NewLimiter(bandwidth.Global, bandwidth.Burst)
+		}
+		limiters.rateLimit = rateLimit
+		limiters.bandwidth = bandwidth
+		limiters.built = true
+	}
+	return limiters.perIp, limiters.perToken, limiters.global
+}
+
+// rateLimit rejects _admin requests over the per ip or per token rate with 429 and Retry-After
+func rateLimit(c *gin.Context) {
+	perIp, perToken, _ := currentLimiters()
+	if perIp != nil && !isUnixRequest(c) {
+		if ok, wait := perIp.Get(c.ClientIP()).Allow(1); !ok {
+			tooManyRequests(c, wait)
+			return
+		}
+	}
+	if perToken != nil {
+		if name := tokenName(c.GetHeader("admin-api-token")); name != "" {
+			if ok, wait := perToken.Get(name).Allow(1); !ok {
+				tooManyRequests(c, wait)
+				return
+			}
+		}
+	}
+	c.Next()
+}
+
+func tooManyRequests(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	response.GenerateWithStatus(c, http.StatusTooManyRequests, defs.CodeTooManyRequests, "Too many requests", nil)
+}
+
+// throttledWriter passes everything written to the response through the bandwidth limiters
+type throttledWriter struct {
+	gin.ResponseWriter
+	w io.Writer
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	return t.w.Write(p)
+}
+
+func (t *throttledWriter) WriteString(s string) (int, error) {
+	return t.w.Write([]byte(s))
+}
+
+// throttleDownload caps the response of c to the per connection and global bandwidth
+func throttleDownload(c *gin.Context) {
+	_, _, globalLimiter := currentLimiters()
+	var perConnection *throttle.Limiter
+	if bandwidth := global.Config().Bandwidth; bandwidth.PerConnection > 0 {
+		perConnection = throttle.NewLimiter(bandwidth.PerConnection, bandwidth.Burst)
+	}
+	if perConnection == nil && globalLimiter == nil {
+		return
+	}
+	c.Writer = &throttledWriter{
+		ResponseWriter: c.Writer,
+		w:              throttle.Writer(c.Writer, perConnection, globalLimiter),
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http/httptest"
+	"simple-file-server/lib/defs"
+	"strings"
+	"testing"
+	"time"
+)
+
+func limitedStatus(remoteAddr string, token string) (int, string) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/_admin/list", nil)
+	c.Request.RemoteAddr = remoteAddr
+	if token != "" {
+		c.Request.Header.Set("admin-api-token", token)
+	}
+	rateLimit(c)
+	c.Writer.WriteHeaderNow()
+	return w.Code, w.Header().Get("Retry-After")
+}
+
+func TestRateLimitPerIp(t *testing.T) {
+	useConfig(t, defs.Config{ApiToken: testToken, RateLimit: defs.RateLimitConfig{PerIp: 1, PerIpBurst: 2}})
+	for i := 0; i < 2; i++ {
+		if status, _ := limitedStatus("10.0.0.1:1234", ""); status != 200 {
+			t.Fatalf("request %d within the burst got %d", i+1, status)
+		}
+	}
+	if status, retryAfter := limitedStatus("10.0.0.1:1234", ""); status != 429 || retryAfter != "1" {
+		t.Errorf("request over the burst got %d with Retry-After %q, want 429 and 1", status, retryAfter)
+	}
+	if status, _ := limitedStatus("10.0.0.2:1234", ""); status != 200 {
+		t.Errorf("request from another ip got %d", status)
+	}
+}
+
+func TestRateLimitPerToken(t *testing.T) {
+	useConfig(t, defs.Config{ApiToken: testToken, RateLimit: defs.RateLimitConfig{PerToken: 1, PerTokenBurst: 1}})
+	if status, _ := limitedStatus("10.0.0.1:1234", testToken); status != 200 {
+		t.Fatalf("first request got %d", status)
+	}
+	// the token is limited whatever address it comes from
+	if status, _ := limitedStatus("10.0.0.2:1234", testToken); status != 429 {
+		t.Errorf("second request with the token got %d, want 429", status)
+	}
+	if status, _ := limitedStatus("10.0.0.2:1234", "unknown"); status != 200 {
+		t.Errorf("request with an unknown token got %d, unknown tokens are left to the auth check", status)
+	}
+}
+
+func TestThrottleDownload(t *testing.T) {
+	download := func() (*httptest.ResponseRecorder, time.Duration) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/a.txt", nil)
+		throttleDownload(c)
+		start := time.Now()
+		c.String(200, strings.Repeat("x", 3000))
+		return w, time.Since(start)
+	}
+	useConfig(t, defs.Config{})
+	if w, elapsed := download(); w.Body.Len() != 3000 || elapsed > 100*time.Millisecond {
+		t.Errorf("unlimited download wrote %d bytes in %v", w.Body.Len(), elapsed)
+	}
+	useConfig(t, defs.Config{Bandwidth: defs.BandwidthConfig{PerConnection: 10000, Burst: 1000}})
+	if w, elapsed := download(); w.Body.Len() != 3000 || elapsed < 150*time.Millisecond {
+		t.Errorf("download at 10000 bytes/s wrote %d bytes in %v", w.Body.Len(), elapsed)
+	}
+}
@@ -0,0 +1,14 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"simple-file-server/lib/response"
+	"simple-file-server/module"
+)
+
+func ActionReplication(c *gin.Context) {
+	if !checkServerToken(c) {
+		return
+	}
+	response.GenerateSuccessData(c, module.ReplicationBacklog())
+}
@@ -1,76 +1,214 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
 	"io"
+	"io/fs"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"simple-file-server/global"
+	"simple-file-server/lib/bucket"
 	"simple-file-server/lib/common"
+	"simple-file-server/lib/config"
 	"simple-file-server/lib/cron"
+	"simple-file-server/lib/defs"
+	sfserrors "simple-file-server/lib/errors"
 	"simple-file-server/lib/files"
+	"simple-file-server/lib/meta"
 	"simple-file-server/lib/response"
+	"simple-file-server/module"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func Start() {
 	cron.Run()
+	go watchCron(cronWatchInterval)
 	StartApi()
 }
 
 func StartApi() {
 
-	if global.CONFIG.Debug {
+	if global.Config().Debug {
 		log.SetLevel(log.DebugLevel)
 		log.Info("Debug mode enabled")
 	} else {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	r := gin.Default()
-
 	log.Info("Server starting")
 
+	tlsConfig, err := newTlsConfig()
+	if err != nil {
+		log.Fatal("Invalid tls config: ", err)
+	}
+
+	// create TempDir
+	files.EnsureDir(global.Config().DataDir, "0755")
+	files.EnsureDir(global.Config().TempDir, "0755")
+	files.EnsureDir(global.Config().TempDir+"/MultiPart", "0755")
+	loadProxyClient()
+
+	var servers []*http.Server
+	if global.Config().AdminListen == "" {
+		r := newEngine(global.Config().AccessLog)
+		registerAdminRoutes(r)
+		registerPublicRoutes(r)
+		servers = append(servers, startServer("Server", publicListen(), r, tlsConfig))
+	} else {
+		admin := newEngine(global.Config().AdminAccessLog)
+		registerAdminRoutes(admin)
+		admin.NoRoute(func(c *gin.Context) {
+			c.AbortWithStatus(404)
+		})
+		adminTlsConfig := tlsConfig
+		if tlsConfig != nil && tlsConfig.ClientCAs != nil {
+			// nothing public on this listener, so the certificate can be required in the handshake
+			adminTlsConfig = tlsConfig.Clone()
+			adminTlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		servers = append(servers, startServer("Admin", global.Config().AdminListen, admin, adminTlsConfig))
+
+		r := newEngine(global.Config().AccessLog)
+		registerPublicRoutes(r)
+		servers = append(servers, startServer("Server", publicListen(), r, tlsConfig))
+	}
+	waitSignal(servers)
+}
+
+func registerAdminRoutes(r *gin.Engine) {
+	// probes come from load balancers and kubelets, they have no client certificate and must never be rate limited
 	r.GET("_admin/ping", ActionPing)
-	r.POST("_admin/upload/multipart_init", ActionUploadMultipartInit)
-	r.POST("_admin/upload/multipart_upload", ActionUploadMultipartUpload)
-	r.POST("_admin/upload/multipart_end", ActionUploadMultipartEnd)
-	r.POST("_admin/upload/abort", ActionUploadAbort)
-	r.POST("_admin/upload", ActionUpload)
-	r.POST("_admin/move", ActionMove)
-	r.POST("_admin/delete", ActionDelete)
-	r.POST("_admin/has", ActionHas)
-	r.POST("_admin/size", ActionSize)
-	r.POST("_admin/get", ActionGet)
+	r.GET("_admin/ready", ActionReady)
+	g := r.Group("", requireClientCert, rateLimit)
+	g.GET("_admin/health", ActionHealth)
+	g.GET("_admin/openapi.json", ActionOpenApi)
+	g.POST("_admin/upload/multipart_init", ActionUploadMultipartInit)
+	g.POST("_admin/upload/multipart_upload", ActionUploadMultipartUpload)
+	g.POST("_admin/upload/multipart_end", ActionUploadMultipartEnd)
+	g.POST("_admin/upload/multipart_status", ActionUploadMultipartStatus)
+	g.POST("_admin/upload/abort", ActionUploadAbort)
+	g.POST("_admin/upload/ticket", ActionUploadTicket)
+	g.POST("_admin/upload/policy", ActionUploadPolicy)
+	g.POST("_admin/sign", ActionSign)
+	g.POST("_admin/upload", ActionUpload)
+	g.POST("_admin/move", ActionMove)
+	g.POST("_admin/delete", ActionDelete)
+	g.POST("_admin/mkdir", ActionMkdir)
+	g.POST("_admin/archive", ActionArchive)
+	g.POST("_admin/extract", ActionExtract)
+	g.POST("_admin/hash", ActionHash)
+	g.GET("_admin/scrub", ActionScrubReport)
+	g.GET("_admin/replication", ActionReplication)
+	g.POST("_admin/bucket/create", ActionBucketCreate)
+	g.GET("_admin/bucket/list", ActionBucketList)
+	g.POST("_admin/bucket/delete", ActionBucketDelete)
+	g.POST("_admin/has", ActionHas)
+	g.POST("_admin/size", ActionSize)
+	g.POST("_admin/list", ActionList)
+	g.GET("_admin/get", ActionGet)
+	g.POST("_admin/get", ActionGet)
+}
 
+func registerPublicRoutes(r *gin.Engine) {
+	r.POST("_upload", ActionPostUpload)
 	r.NoRoute(ActionServeFile)
+}
 
-	// create TempDir
-	files.EnsureDir(global.CONFIG.DataDir, "0755")
-	files.EnsureDir(global.CONFIG.TempDir, "0755")
-	files.EnsureDir(global.CONFIG.TempDir+"/MultiPart", "0755")
-
-	log.Info("Server listening on port ", global.CONFIG.Port)
-	r.Run(fmt.Sprintf(":%d", global.CONFIG.Port))
+// waitSignal reloads the config on SIGHUP and shuts down gracefully on SIGINT or SIGTERM
+func waitSignal(servers []*http.Server) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	for sig := range signals {
+		if sig == syscall.SIGHUP {
+			log.Info("Received SIGHUP, reloading config")
+			if err := config.Reload(); err != nil {
+				log.Error("Reload config failed: ", err)
+				continue
+			}
+			cron.Reload()
+			loadProxyClient()
+			continue
+		}
+		log.Info("Received ", sig, ", shutting down")
+		break
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(global.Config().ShutdownTimeout)*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				log.Warn("Server shutdown: ", err)
+			}
+		}(srv)
+	}
+	wg.Wait()
+	select {
+	case <-global.CRON.Stop().Done():
+	case <-ctx.Done():
+		log.Warn("Cron jobs still running, exiting anyway")
+	}
+	log.Info("Server stopped")
 }
 
 func ActionPing(c *gin.Context) {
 	response.GenerateSuccess(c, "ok")
 }
 
+// relPath normalizes a request path to a slash separated path relative to DataDir
+func relPath(path string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+path)), "/")
+}
+
+// dataPath maps a request path into DataDir, never escaping it
+func dataPath(path string) string {
+	return filepath.Join(global.Config().DataDir, filepath.Clean("/"+path))
+}
+
 func checkAdminToken(c *gin.Context) bool {
-	token := c.GetHeader("admin-api-token")
-	if token != global.CONFIG.ApiToken {
-		response.GenerateError(c, "Invalid token")
+	name := tokenName(c.GetHeader("admin-api-token"))
+	if name == "" {
+		response.GenerateErrorCode(c, defs.CodeInvalidToken, "Invalid token")
 		return false
 	}
+	c.Set("tokenName", name)
 	return true
 }
 
+// tokenName returns "admin" for apiToken, the name of one of the extra tokens, "<bucket>/<name>" for a bucket token
+// or "" for an unknown token
+func tokenName(token string) string {
+	if token == "" {
+		return ""
+	}
+	config := global.Config()
+	if token == config.ApiToken {
+		return "admin"
+	}
+	for _, t := range config.Tokens {
+		if t.Token == token {
+			return t.Name
+		}
+	}
+	if b, name, ok := bucket.ByToken(token); ok {
+		return b.Name + "/" + name
+	}
+	return ""
+}
+
 type FileDownloadInfo struct {
 	Id    string `json:"id"`
 	Url   string `json:"url"`
@@ -80,33 +218,65 @@ type FileDownloadInfo struct {
 }
 
 type MultipartMeta struct {
-	UploadID   string `json:"uploadId"`
-	FilePath   string `json:"filePath"`
-	TotalParts int    `json:"totalParts"`
-	TotalSize  int64  `json:"totalSize"`
+	UploadID    string `json:"uploadId"`
+	FilePath    string `json:"filePath"`
+	TotalParts  int    `json:"totalParts"`
+	TotalSize   int64  `json:"totalSize"`
+	ContentType string `json:"contentType"`
+	Mtime       int64  `json:"mtime"`
+	// Ticket is the upload ticket the upload was started with, the other calls need the same one
+	Ticket *UploadTicket `json:"ticket,omitempty"`
+}
+
+type multipartInitRequest struct {
+	FilePath    string `json:"filePath"`
+	TotalParts  int    `json:"totalParts"`
+	TotalSize   int64  `json:"totalSize"`
+	ContentType string `json:"contentType"`
+	Mtime       int64  `json:"mtime"`
 }
 
 func ActionUploadMultipartInit(c *gin.Context) {
-	if !checkAdminToken(c) {
+	if !checkUploadAuth(c) || !claimTicket(c) {
 		return
 	}
-	var req struct {
-		FilePath   string `json:"filePath"`
-		TotalParts int    `json:"totalParts"`
-		TotalSize  int64  `json:"totalSize"`
-	}
+	var req multipartInitRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.GenerateError(c, "Invalid request")
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	req.FilePath = relPath(scopePath(c, req.FilePath))
+	if !checkTicketPath(c, req.FilePath) {
+		return
+	}
+	if err := checkAccessFileWrite(c, req.FilePath); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+	layers := uploadLimits(c, req.FilePath)
+	if err := checkMultipart(layers, req.TotalParts, req.TotalSize); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+	if err := checkQuota(relPath(req.FilePath), req.TotalSize); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+	if err := checkUploadType(layers, req.FilePath, declaredType(req.ContentType)); err != nil {
+		response.GenerateBusinessError(c, err)
 		return
 	}
 	uploadID := common.RandomString(32)
 	meta := MultipartMeta{
-		UploadID:   uploadID,
-		FilePath:   req.FilePath,
-		TotalParts: req.TotalParts,
-		TotalSize:  req.TotalSize,
-	}
-	dir := global.CONFIG.TempDir + "/MultiPart/" + uploadID
+		UploadID:    uploadID,
+		FilePath:    req.FilePath,
+		TotalParts:  req.TotalParts,
+		TotalSize:   req.TotalSize,
+		ContentType: req.ContentType,
+		Mtime:       req.Mtime,
+		Ticket:      requestTicket(c),
+	}
+	dir := global.Config().TempDir + "/MultiPart/" + uploadID
 	files.EnsureDir(dir, "0755")
 	metaFile := dir + "/meta.json"
 	data, _ := json.Marshal(meta)
@@ -114,37 +284,80 @@ func ActionUploadMultipartInit(c *gin.Context) {
 	response.GenerateSuccessWithData(c, "ok", gin.H{"uploadId": uploadID})
 }
 
+// readMultipartMeta returns the meta and the temp directory of a multipart upload
+func readMultipartMeta(uploadID string) (MultipartMeta, string, *sfserrors.BusinessError) {
+	var meta MultipartMeta
+	if !common.IsAlphanumeric(uploadID) {
+		return meta, "", sfserrors.New(defs.CodeInvalidRequest, "Invalid uploadId")
+	}
+	dir := global.Config().TempDir + "/MultiPart/" + uploadID
+	data, err := os.ReadFile(dir + "/meta.json")
+	if err != nil {
+		return meta, "", sfserrors.New(defs.CodeUploadNotFound, "UploadIDNotFound")
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, "", sfserrors.Wrap(defs.CodeInternalError, "Invalid upload meta", err)
+	}
+	return meta, dir, nil
+}
+
 func ActionUploadMultipartUpload(c *gin.Context) {
-	if !checkAdminToken(c) {
+	if !checkUploadAuth(c) {
 		return
 	}
+	layers := tokenLimits(c)
+	limitBody(c, layers, func(limits defs.UploadLimits) int64 { return limits.MaxPartSize })
+	// parsing the form first, so an oversized body is reported as such
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		if isBodyTooLarge(err) {
+			response.GenerateBusinessError(c, sfserrors.New(defs.CodePartTooLarge, "Part too large"))
+			return
+		}
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid file")
+		return
+	}
+	defer file.Close()
 	uploadID := c.PostForm("uploadId")
 	partNumberStr := c.PostForm("partNumber")
 	partNumber, err := strconv.Atoi(partNumberStr)
 	if err != nil {
-		response.GenerateError(c, "Invalid partNumber")
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid partNumber")
 		return
 	}
 	// check uploadID and partNumber
 	if uploadID == "" || partNumber < 0 {
-		response.GenerateError(c, "Invalid uploadId or partNumber")
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid uploadId or partNumber")
 		return
 	}
-	file, _, err := c.Request.FormFile("file")
-	if err != nil {
-		response.GenerateError(c, "Invalid file")
+	meta, dir, bizErr := readMultipartMeta(uploadID)
+	if bizErr != nil {
+		response.GenerateBusinessError(c, bizErr)
 		return
 	}
-	defer file.Close()
-	dir := global.CONFIG.TempDir + "/MultiPart/" + uploadID
-	if !files.FileExists(dir) {
-		response.GenerateError(c, "UploadIDNotFound")
+	if !checkSessionTicket(c, meta) {
+		return
+	}
+	layers = uploadLimits(c, meta.FilePath)
+	if err := checkPartSize(layers, header.Size); err != nil {
+		response.GenerateBusinessError(c, err)
 		return
 	}
+	if err := checkMultipart(layers, partNumber, 0); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+	// the first part holds the bytes the content type is sniffed from
+	if partNumber == 1 && hasTypeRules(layers) {
+		if err := checkUploadType(layers, meta.FilePath, sniffContentType(file)); err != nil {
+			response.GenerateBusinessError(c, err)
+			return
+		}
+	}
 	partFile := dir + "/part" + strconv.Itoa(partNumber)
 	out, err := os.Create(partFile)
 	if err != nil {
-		response.GenerateError(c, "Failed to create part file")
+		response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to create part file", err))
 		return
 	}
 	defer out.Close()
@@ -152,36 +365,96 @@ func ActionUploadMultipartUpload(c *gin.Context) {
 	response.GenerateSuccess(c, "ok")
 }
 
-func ActionUploadMultipartEnd(c *gin.Context) {
-	if !checkAdminToken(c) {
+type uploadIdRequest struct {
+	UploadID string `json:"uploadId"`
+}
+
+func ActionUploadMultipartStatus(c *gin.Context) {
+	if !checkUploadAuth(c) {
+		return
+	}
+	var req uploadIdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	if req.UploadID == "" {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid uploadId")
+		return
+	}
+	meta, dir, bizErr := readMultipartMeta(req.UploadID)
+	if bizErr != nil {
+		response.GenerateBusinessError(c, bizErr)
+		return
+	}
+	if !checkSessionTicket(c, meta) {
 		return
 	}
-	var req struct {
-		UploadID string `json:"uploadId"`
+	parts := []gin.H{}
+	for i := 1; i <= meta.TotalParts; i++ {
+		info, err := os.Stat(dir + "/part" + strconv.Itoa(i))
+		if err != nil {
+			continue
+		}
+		parts = append(parts, gin.H{
+			"partNumber": i,
+			"size":       info.Size(),
+		})
+	}
+	response.GenerateSuccessWithData(c, "ok", gin.H{
+		"uploadId":   meta.UploadID,
+		"filePath":   unscopePath(c, meta.FilePath),
+		"totalParts": meta.TotalParts,
+		"totalSize":  meta.TotalSize,
+		"parts":      parts,
+	})
+}
+
+func ActionUploadMultipartEnd(c *gin.Context) {
+	if !checkUploadAuth(c) {
+		return
 	}
+	var req uploadIdRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.GenerateError(c, "Invalid request")
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
 		return
 	}
 	// check uploadID and partNumber
 	if req.UploadID == "" {
-		response.GenerateError(c, "Invalid uploadId or partNumber")
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid uploadId or partNumber")
 		return
 	}
-	dir := global.CONFIG.TempDir + "/MultiPart/" + req.UploadID
-	metaFile := dir + "/meta.json"
-	data, err := os.ReadFile(metaFile)
-	if err != nil {
-		response.GenerateError(c, "Meta not found")
+	meta, dir, bizErr := readMultipartMeta(req.UploadID)
+	if bizErr != nil {
+		response.GenerateBusinessError(c, bizErr)
 		return
 	}
-	var meta MultipartMeta
-	json.Unmarshal(data, &meta)
-	finalFile := filepath.Join(global.CONFIG.DataDir, meta.FilePath)
+	if !checkSessionTicket(c, meta) {
+		return
+	}
+	if err := checkAccessFileWrite(c, meta.FilePath); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+	var totalSize int64
+	for i := 1; i <= meta.TotalParts; i++ {
+		if info, err := os.Stat(dir + "/part" + strconv.Itoa(i)); err == nil {
+			totalSize += info.Size()
+		}
+	}
+	if err := checkMultipart(uploadLimits(c, meta.FilePath), meta.TotalParts, totalSize); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+	if err := checkQuota(relPath(meta.FilePath), totalSize); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+	finalFile := dataPath(meta.FilePath)
 	files.EnsureDir(filepath.Dir(finalFile), "0755")
 	out, err := os.Create(finalFile)
 	if err != nil {
-		response.GenerateError(c, "Failed to create final file")
+		response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to create final file", err))
 		return
 	}
 	defer out.Close()
@@ -189,154 +462,322 @@ func ActionUploadMultipartEnd(c *gin.Context) {
 		partFile := dir + "/part" + strconv.Itoa(i)
 		part, err := os.Open(partFile)
 		if err != nil {
-			response.GenerateError(c, "Part file missing")
+			response.GenerateErrorCode(c, defs.CodeIncompleteUpload, "Part file missing")
 			return
 		}
 		io.Copy(out, part)
 		part.Close()
 	}
+	setMtime(finalFile, meta.Mtime)
 	files.DeleteDir(dir)
+	saveContentType(meta.FilePath, meta.ContentType)
+	addUsage(relPath(meta.FilePath), totalSize)
+	emit(module.ReplicationEvent{Op: module.ReplicateUpload, Path: relPath(meta.FilePath)})
 	response.GenerateSuccessWithData(c, "ok", gin.H{
-		"filePath": meta.FilePath,
+		"filePath": unscopePath(c, meta.FilePath),
 	})
 }
 
 func ActionUpload(c *gin.Context) {
-	if !checkAdminToken(c) {
+	if !checkUploadAuth(c) || !claimTicket(c) {
 		return
 	}
-	file, _, err := c.Request.FormFile("file")
+	layers := tokenLimits(c)
+	limitBody(c, layers, func(limits defs.UploadLimits) int64 { return limits.MaxSize })
+	file, header, err := c.Request.FormFile("file")
 	if err != nil {
-		response.GenerateError(c, "Invalid file")
+		if isBodyTooLarge(err) {
+			response.GenerateBusinessError(c, sfserrors.New(defs.CodeFileTooLarge, "File too large"))
+			return
+		}
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid file")
 		return
 	}
 	defer file.Close()
 	filePath := c.PostForm("filePath")
 	if filePath == "" {
-		response.GenerateError(c, "filePath is required")
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "filePath is required")
 		return
 	}
-	filePath = filepath.Join(global.CONFIG.DataDir, filePath)
+	filePath = relPath(scopePath(c, filePath))
+	if !checkTicketPath(c, filePath) {
+		return
+	}
+	if err := checkAccessFileWrite(c, filePath); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+	layers = uploadLimits(c, filePath)
+	if err := checkUploadSize(layers, header.Size); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+	if err := checkQuota(relPath(filePath), header.Size); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+	sniffed := ""
+	if hasTypeRules(layers) {
+		sniffed = sniffContentType(file)
+	}
+	if err := checkUploadType(layers, filePath, sniffed, declaredType(header.Header.Get("Content-Type"))); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+	relFilePath := filePath
+	filePath = dataPath(filePath)
 	files.EnsureDir(filepath.Dir(filePath), "0755")
 	out, err := os.Create(filePath)
 	if err != nil {
-		response.GenerateError(c, "Failed to create file")
+		response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to create file", err))
 		return
 	}
 	defer out.Close()
 	io.Copy(out, file)
+	mtime, _ := strconv.ParseInt(c.PostForm("mtime"), 10, 64)
+	setMtime(filePath, mtime)
+	saveContentType(relFilePath, header.Header.Get("Content-Type"))
+	addUsage(relPath(relFilePath), header.Size)
+	emit(module.ReplicationEvent{Op: module.ReplicateUpload, Path: relPath(relFilePath)})
 	response.GenerateSuccessWithData(c, "ok", gin.H{
-		"filePath": filePath,
+		"filePath": unscopePath(c, relFilePath),
 	})
 }
 
+// setMtime sets the modification time given by the uploader as unix seconds, if any
+func setMtime(path string, mtime int64) {
+	if mtime <= 0 {
+		return
+	}
+	t := time.Unix(mtime, 0)
+	os.Chtimes(path, t, t)
+}
+
+// saveContentType records the content type given by the uploader, if any
+func saveContentType(path string, contentType string) {
+	if contentType == "" || contentType == "application/octet-stream" {
+		meta.Delete(relPath(path))
+		return
+	}
+	meta.Save(relPath(path), meta.FileMeta{ContentType: contentType})
+}
+
 func ActionServeFile(c *gin.Context) {
 	path := c.Request.URL.Path
 	if strings.HasPrefix(path, "/_admin/") {
 		c.AbortWithStatus(404)
 		return
 	}
-	fullPath := filepath.Join(global.CONFIG.DataDir, strings.TrimPrefix(path, "/"))
+	if filepath.Base(relPath(path)) == AccessFileName {
+		c.AbortWithStatus(404)
+		return
+	}
+	if site, sitePath, ok := matchSite(c); ok {
+		serveSite(c, site, sitePath)
+		return
+	}
+	if b, ok := bucket.ByHost(requestHost(c)); ok {
+		c.Set("hostBucket", b.Name)
+		path = "/" + b.Name + "/" + relPath(path)
+	}
+	if !checkAccess(c, relPath(path)) {
+		return
+	}
+	throttleDownload(c)
+	fullPath := dataPath(path)
+	if global.Config().Proxy.Upstream != "" && proxyServe(c, relPath(path), fullPath) {
+		return
+	}
 	if !files.FileExists(fullPath) {
 		c.AbortWithStatus(404)
 		return
 	}
 	info, err := os.Stat(fullPath)
+	if err == nil && info.IsDir() && indexEnabled(relPath(path)) {
+		serveDirectory(c, relPath(path), fullPath)
+		return
+	}
 	if err != nil || info.IsDir() {
 		c.AbortWithStatus(404)
 		return
 	}
-	mediaTypes := map[string]string{
-		".jpg":  "image/jpeg",
-		".jpeg": "image/jpeg",
-		".png":  "image/png",
-		".gif":  "image/gif",
-		".mp4":  "video/mp4",
-		".avi":  "video/x-msvideo",
-		".mp3":  "audio/mpeg",
-		".wav":  "audio/wav",
-		".webm": "video/webm",
-		".ogg":  "audio/ogg",
-		".pdf":  "application/pdf",
-		".txt":  "text/plain",
-		".html": "text/html",
-		".css":  "text/css",
-		".js":   "application/javascript",
-		".json": "application/json",
-		".xml":  "application/xml",
-		".zip":  "application/zip",
-	}
-	ext := strings.ToLower(filepath.Ext(fullPath))
-	if mt, ok := mediaTypes[ext]; ok {
+	serveFile(c, relPath(path), fullPath)
+}
+
+// serveFile sends a file of DataDir with its stored or guessed content type and the headers of its rules
+func serveFile(c *gin.Context, rel string, fullPath string) {
+	setFileHeaders(c, rel)
+	if m, ok := meta.Get(rel); ok && m.ContentType != "" {
+		c.Header("Content-Type", m.ContentType)
+	} else if mt := files.ContentType(fullPath); mt != "" {
 		c.Header("Content-Type", mt)
 	}
 	c.Header("Server", "Simple-File-Server")
 	c.File(fullPath)
 }
 
+type moveRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
 func ActionMove(c *gin.Context) {
 	if !checkAdminToken(c) {
 		return
 	}
-	var req struct {
-		From string `json:"from"`
-		To   string `json:"to"`
-	}
+	var req moveRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.GenerateError(c, "Invalid request")
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
 		return
 	}
-	fromPath := filepath.Join(global.CONFIG.DataDir, req.From)
-	toPath := filepath.Join(global.CONFIG.DataDir, req.To)
+	req.From, req.To = relPath(scopePath(c, req.From)), relPath(scopePath(c, req.To))
+	for _, rel := range []string{req.From, req.To} {
+		if err := checkAccessFileWrite(c, rel); err != nil {
+			response.GenerateBusinessError(c, err)
+			return
+		}
+	}
+	fromPath := dataPath(req.From)
+	toPath := dataPath(req.To)
 	if !files.FileExists(fromPath) {
-		response.GenerateError(c, "Source file not found")
+		response.GenerateErrorCode(c, defs.CodeNotFound, "Source file not found")
 		return
 	}
 	files.EnsureDir(filepath.Dir(toPath), "0755")
 	err := os.Rename(fromPath, toPath)
 	if err != nil {
-		response.GenerateError(c, "Failed to move file")
+		response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to move file", err))
 		return
 	}
+	meta.Move(relPath(req.From), relPath(req.To))
+	emit(module.ReplicationEvent{Op: module.ReplicateMove, Path: relPath(req.From), To: relPath(req.To)})
 	response.GenerateSuccess(c, "ok")
 }
 
+type deleteRequest struct {
+	Path      string `json:"path"`
+	Recursive bool   `json:"recursive"`
+	DryRun    bool   `json:"dryRun"`
+}
+
 func ActionDelete(c *gin.Context) {
 	if !checkAdminToken(c) {
 		return
 	}
-	var req struct {
-		Path string `json:"path"`
-	}
+	var req deleteRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.GenerateError(c, "Invalid request")
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
 		return
 	}
-	fullPath := filepath.Join(global.CONFIG.DataDir, req.Path)
-	if !files.FileExists(fullPath) {
-		response.GenerateError(c, "File not found")
+	req.Path = scopePath(c, req.Path)
+	if err := checkAccessFileWrite(c, relPath(req.Path)); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+	fullPath := dataPath(req.Path)
+	if fullPath == rootPath(c) {
+		response.GenerateErrorCode(c, defs.CodeForbidden, "Cannot delete root directory")
+		return
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		response.GenerateErrorCode(c, defs.CodeNotFound, "File not found")
 		return
 	}
-	err := os.Remove(fullPath)
+	if info.IsDir() && !req.Recursive {
+		entries, err := os.ReadDir(fullPath)
+		if err != nil {
+			response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to read directory", err))
+			return
+		}
+		if len(entries) > 0 {
+			response.GenerateErrorCode(c, defs.CodeDirectoryNotEmpty, "Directory not empty")
+			return
+		}
+	}
+	if req.DryRun {
+		paths := []string{}
+		err = filepath.WalkDir(fullPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(rootPath(c), path)
+			paths = append(paths, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to read directory", err))
+			return
+		}
+		response.GenerateSuccessWithData(c, "ok", gin.H{
+			"dryRun": true,
+			"paths":  paths,
+		})
+		return
+	}
+	if info.IsDir() {
+		err = os.RemoveAll(fullPath)
+	} else {
+		err = os.Remove(fullPath)
+	}
 	if err != nil {
-		response.GenerateError(c, "Failed to delete file")
+		response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to delete file", err))
 		return
 	}
+	meta.Delete(relPath(req.Path))
+	// a bucket token must not remove the directory of its bucket
+	files.PruneEmptyDirs(filepath.Dir(fullPath), rootPath(c))
+	emit(module.ReplicationEvent{Op: module.ReplicateDelete, Path: relPath(req.Path)})
 	response.GenerateSuccess(c, "ok")
 }
 
-func ActionHas(c *gin.Context) {
+type pathRequest struct {
+	Path string `json:"path"`
+}
+
+func ActionMkdir(c *gin.Context) {
 	if !checkAdminToken(c) {
 		return
 	}
-	var req struct {
-		Path string `json:"path"`
+	var req pathRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	if req.Path == "" {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "path is required")
+		return
+	}
+	rel := relPath(scopePath(c, req.Path))
+	if err := checkAccessFileWrite(c, rel); err != nil {
+		response.GenerateBusinessError(c, err)
+		return
+	}
+	fullPath := dataPath(rel)
+	if info, err := os.Stat(fullPath); err == nil && !info.IsDir() {
+		response.GenerateErrorCode(c, defs.CodeAlreadyExists, "File already exists")
+		return
+	}
+	if err := os.MkdirAll(fullPath, 0755); err != nil {
+		response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to create directory", err))
+		return
+	}
+	emit(module.ReplicationEvent{Op: module.ReplicateMkdir, Path: rel})
+	response.GenerateSuccessWithData(c, "ok", gin.H{
+		"path": unscopePath(c, rel),
+	})
+}
+
+func ActionHas(c *gin.Context) {
+	if !checkAdminToken(c) {
+		return
 	}
+	var req pathRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.GenerateError(c, "Invalid request")
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
 		return
 	}
-	fullPath := filepath.Join(global.CONFIG.DataDir, req.Path)
+	fullPath := dataPath(scopePath(c, req.Path))
 	exists := files.FileExists(fullPath)
 	response.GenerateSuccessWithData(c, "ok", exists)
 }
@@ -345,21 +786,19 @@ func ActionSize(c *gin.Context) {
 	if !checkAdminToken(c) {
 		return
 	}
-	var req struct {
-		Path string `json:"path"`
-	}
+	var req pathRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.GenerateError(c, "Invalid request")
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
 		return
 	}
-	fullPath := filepath.Join(global.CONFIG.DataDir, req.Path)
+	fullPath := dataPath(scopePath(c, req.Path))
 	if !files.FileExists(fullPath) {
-		response.GenerateError(c, "File not found")
+		response.GenerateErrorCode(c, defs.CodeNotFound, "File not found")
 		return
 	}
 	info, err := os.Stat(fullPath)
 	if err != nil {
-		response.GenerateError(c, "Failed to get file info")
+		response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to get file info", err))
 		return
 	}
 	response.GenerateSuccessWithData(c, "ok", gin.H{
@@ -367,48 +806,141 @@ func ActionSize(c *gin.Context) {
 	})
 }
 
+type ListItem struct {
+	Path  string `json:"path"`
+	Name  string `json:"name"`
+	IsDir bool   `json:"isDir"`
+	Size  int64  `json:"size"`
+	Mtime int64  `json:"mtime"`
+}
+
+type listRequest struct {
+	Path      string `json:"path"`
+	Recursive bool   `json:"recursive"`
+}
+
+func ActionList(c *gin.Context) {
+	if !checkAdminToken(c) {
+		return
+	}
+	var req listRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	root := rootPath(c)
+	fullPath := dataPath(scopePath(c, req.Path))
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		response.GenerateErrorCode(c, defs.CodeNotFound, "File not found")
+		return
+	}
+	items := []ListItem{}
+	add := func(path string, info os.FileInfo) {
+		rel, _ := filepath.Rel(root, path)
+		items = append(items, ListItem{
+			Path:  filepath.ToSlash(rel),
+			Name:  info.Name(),
+			IsDir: info.IsDir(),
+			Size:  info.Size(),
+			Mtime: info.ModTime().Unix(),
+		})
+	}
+	switch {
+	case !info.IsDir():
+		add(fullPath, info)
+	case req.Recursive:
+		filepath.Walk(fullPath, func(path string, info os.FileInfo, err error) error {
+			if err == nil && path != fullPath {
+				add(path, info)
+			}
+			return nil
+		})
+	default:
+		entries, _ := os.ReadDir(fullPath)
+		for _, entry := range entries {
+			if info, err := entry.Info(); err == nil {
+				add(filepath.Join(fullPath, entry.Name()), info)
+			}
+		}
+	}
+	response.GenerateSuccessWithData(c, "ok", items)
+}
+
+type getRequest struct {
+	Path string `json:"path" form:"path"`
+	Zip  bool   `json:"zip" form:"zip"`
+}
+
 func ActionGet(c *gin.Context) {
 	if !checkAdminToken(c) {
 		return
 	}
-	var req struct {
-		Path string `json:"path"`
+	var req getRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
+		return
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.AbortWithError(404, err)
+	req.Path = scopePath(c, req.Path)
+	fullPath := dataPath(req.Path)
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		response.GenerateErrorCode(c, defs.CodeNotFound, "File not found")
 		return
 	}
-	fullPath := filepath.Join(global.CONFIG.DataDir, req.Path)
-	if !files.FileExists(fullPath) {
-		c.AbortWithStatus(404)
+	if info.IsDir() {
+		if !req.Zip {
+			response.GenerateErrorCode(c, defs.CodeNotFound, "File not found")
+			return
+		}
+		name := filepath.Base(fullPath)
+		if fullPath == filepath.Clean(global.Config().DataDir) {
+			name = "data"
+		}
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".zip"))
+		c.Status(200)
+		if err := files.WriteZip(c.Writer, fullPath, filepath.Dir(fullPath)); err != nil {
+			requestLog(c).Error("ActionGet.WriteZip: ", err)
+		}
 		return
 	}
-	data, err := os.ReadFile(fullPath)
+	file, err := os.Open(fullPath)
 	if err != nil {
-		c.AbortWithError(500, err)
+		response.GenerateBusinessError(c, sfserrors.Wrap(defs.CodeInternalError, "Failed to open file", err))
 		return
 	}
-	c.Data(200, "application/octet-stream", data)
+	defer file.Close()
+	contentType := "application/octet-stream"
+	if m, ok := meta.Get(relPath(req.Path)); ok && m.ContentType != "" {
+		contentType = m.ContentType
+	} else if mt := files.ContentType(fullPath); mt != "" {
+		contentType = mt
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("ETag", files.ETag(info))
+	http.ServeContent(c.Writer, c.Request, info.Name(), info.ModTime(), file)
 }
 
 func ActionUploadAbort(c *gin.Context) {
-	if !checkAdminToken(c) {
+	if !checkUploadAuth(c) {
 		return
 	}
-	var req struct {
-		UploadID string `json:"uploadId"`
-	}
+	var req uploadIdRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.GenerateError(c, "Invalid request")
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
 		return
 	}
 	if req.UploadID == "" {
-		response.GenerateError(c, "Invalid uploadId")
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid uploadId")
+		return
+	}
+	meta, dir, bizErr := readMultipartMeta(req.UploadID)
+	if bizErr != nil {
+		response.GenerateBusinessError(c, bizErr)
 		return
 	}
-	dir := global.CONFIG.TempDir + "/MultiPart/" + req.UploadID
-	if !files.FileExists(dir) {
-		response.GenerateError(c, "UploadIDNotFound")
+	if !checkSessionTicket(c, meta) {
 		return
 	}
 	files.DeleteDir(dir)
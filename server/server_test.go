@@ -0,0 +1,286 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"simple-file-server/global"
+	"simple-file-server/lib/defs"
+	"simple-file-server/lib/meta"
+	"strings"
+	"testing"
+)
+
+const testToken = "admintoken-123456"
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// useConfig publishes config for the test and restores the previous one when it ends
+func useConfig(t *testing.T, config defs.Config) {
+	previous := *global.Config()
+	global.SetConfig(config)
+	t.Cleanup(func() {
+		global.SetConfig(previous)
+	})
+}
+
+// callAdmin posts body as json with the admin token to handler and decodes the response
+func callAdmin(t *testing.T, handler gin.HandlerFunc, body interface{}) (int, json.RawMessage) {
+	t.Helper()
+	return callWithToken(t, handler, testToken, body)
+}
+
+func callWithToken(t *testing.T, handler gin.HandlerFunc, token string, body interface{}) (int, json.RawMessage) {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/", bytes.NewReader(data))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.Header.Set("admin-api-token", token)
+	handler(c)
+	var res struct {
+		Code int             `json:"code"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+	}
+	return res.Code, res.Data
+}
+
+// useDataDir publishes a config with the admin token and an empty data and temp dir, it returns the data dir
+func useDataDir(t *testing.T) string {
+	root := t.TempDir()
+	useConfig(t, defs.Config{ApiToken: testToken, DataDir: root, TempDir: t.TempDir()})
+	return root
+}
+
+func writeFiles(t *testing.T, root string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		fullPath := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRelPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", ""},
+		{"/", ""},
+		{".", ""},
+		{"a/b.txt", "a/b.txt"},
+		{"/a/b.txt", "a/b.txt"},
+		{"a//b/./c/", "a/b/c"},
+		{"a/../b", "b"},
+		{"..", ""},
+		{"../etc/passwd", "etc/passwd"},
+		{"/../../etc/passwd", "etc/passwd"},
+		{"a/../../../b", "b"},
+	}
+	for _, tt := range tests {
+		if got := relPath(tt.path); got != tt.want {
+			t.Errorf("relPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestDataPath(t *testing.T) {
+	root := t.TempDir()
+	useConfig(t, defs.Config{DataDir: root})
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", root},
+		{"/", root},
+		{"a/b.txt", filepath.Join(root, "a", "b.txt")},
+		{"../b.txt", filepath.Join(root, "b.txt")},
+		{"/../../b.txt", filepath.Join(root, "b.txt")},
+		{"a/../../../b/c", filepath.Join(root, "b", "c")},
+		{"a/..", root},
+	}
+	for _, tt := range tests {
+		got := dataPath(tt.path)
+		if got != tt.want {
+			t.Errorf("dataPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+		if got != root && !strings.HasPrefix(got, root+string(filepath.Separator)) {
+			t.Errorf("dataPath(%q) = %q escapes %q", tt.path, got, root)
+		}
+	}
+}
+
+func TestActionDelete(t *testing.T) {
+	root := useDataDir(t)
+	writeFiles(t, root, "a/b/c.txt", "a/d.txt")
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(root, filepath.FromSlash(name)))
+		return err == nil
+	}
+
+	if code, _ := callAdmin(t, ActionDelete, gin.H{"path": "a"}); code == 0 || !exists("a/d.txt") {
+		t.Errorf("deleting a non empty directory without recursive answered %d", code)
+	}
+
+	code, data := callAdmin(t, ActionDelete, gin.H{"path": "a", "recursive": true, "dryRun": true})
+	var dryRun struct {
+		DryRun bool     `json:"dryRun"`
+		Paths  []string `json:"paths"`
+	}
+	json.Unmarshal(data, &dryRun)
+	want := []string{"a", "a/b", "a/b/c.txt", "a/d.txt"}
+	if code != 0 || !dryRun.DryRun || !reflect.DeepEqual(dryRun.Paths, want) {
+		t.Errorf("dry run answered %d %s, want the paths %q", code, data, want)
+	}
+	if !exists("a/b/c.txt") {
+		t.Error("dry run deleted a/b/c.txt")
+	}
+
+	// empty parents are pruned up to the data dir
+	if code, _ := callAdmin(t, ActionDelete, gin.H{"path": "a/b/c.txt"}); code != 0 {
+		t.Errorf("deleting a/b/c.txt answered %d", code)
+	}
+	if exists("a/b") || !exists("a/d.txt") {
+		t.Error("a/b was not pruned or a/d.txt was removed")
+	}
+
+	for _, path := range []string{"", "/", "..", "a/../..", "missing.txt"} {
+		if code, _ := callAdmin(t, ActionDelete, gin.H{"path": path, "recursive": true}); code == 0 {
+			t.Errorf("deleting %q succeeded", path)
+		}
+	}
+	if !exists("a/d.txt") {
+		t.Error("a/d.txt was removed by a failed delete")
+	}
+
+	if code, _ := callAdmin(t, ActionDelete, gin.H{"path": "a", "recursive": true}); code != 0 || exists("a") {
+		t.Errorf("recursive delete answered %d", code)
+	}
+	if !exists("") {
+		t.Error("the data dir was removed")
+	}
+}
+
+func TestActionMkdir(t *testing.T) {
+	root := useDataDir(t)
+	writeFiles(t, root, "file.txt")
+
+	code, data := callAdmin(t, ActionMkdir, gin.H{"path": "/x/../y//z/"})
+	var res struct {
+		Path string `json:"path"`
+	}
+	json.Unmarshal(data, &res)
+	if code != 0 || res.Path != "y/z" {
+		t.Errorf("mkdir answered %d %s, want the path y/z", code, data)
+	}
+	if info, err := os.Stat(filepath.Join(root, "y", "z")); err != nil || !info.IsDir() {
+		t.Error("y/z was not created")
+	}
+	if code, _ := callAdmin(t, ActionMkdir, gin.H{"path": "y/z"}); code != 0 {
+		t.Errorf("mkdir of an existing directory answered %d", code)
+	}
+
+	if code, _ := callAdmin(t, ActionMkdir, gin.H{"path": "file.txt"}); code == 0 {
+		t.Error("mkdir over a file succeeded")
+	}
+	if code, _ := callAdmin(t, ActionMkdir, gin.H{"path": ""}); code == 0 {
+		t.Error("mkdir without a path succeeded")
+	}
+	if code, _ := callAdmin(t, ActionMkdir, gin.H{"path": "../escaped"}); code != 0 {
+		t.Errorf("mkdir of ../escaped answered %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(root, "escaped")); err != nil {
+		t.Error("../escaped was not created in the data dir")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(root), "escaped")); err == nil {
+		t.Error("mkdir escaped the data dir")
+	}
+}
+
+// getAdmin runs handler on a GET of target with the admin token and the given headers
+func getAdmin(handler gin.HandlerFunc, target string, headers map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", target, nil)
+	c.Request.Header.Set("admin-api-token", testToken)
+	for key, value := range headers {
+		c.Request.Header.Set(key, value)
+	}
+	handler(c)
+	c.Writer.WriteHeaderNow()
+	return w
+}
+
+func TestActionGet(t *testing.T) {
+	root := useDataDir(t)
+	if err := os.MkdirAll(filepath.Join(root, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a", "b.txt"), []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := meta.Save("a/b.txt", meta.FileMeta{ContentType: "text/x-test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := getAdmin(ActionGet, "/?path=a/b.txt", nil)
+	if w.Code != 200 || w.Body.String() != "0123456789" {
+		t.Fatalf("get answered %d %q", w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/x-test" {
+		t.Errorf("Content-Type is %q, want the stored text/x-test", contentType)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Error("no ETag")
+	}
+
+	w = getAdmin(ActionGet, "/?path=a/b.txt", map[string]string{"Range": "bytes=2-4"})
+	if w.Code != 206 || w.Body.String() != "234" || w.Header().Get("Content-Range") != "bytes 2-4/10" {
+		t.Errorf("range answered %d %q %q", w.Code, w.Body.String(), w.Header().Get("Content-Range"))
+	}
+	w = getAdmin(ActionGet, "/?path=a/b.txt", map[string]string{"If-None-Match": etag})
+	if w.Code != 304 || w.Body.Len() != 0 {
+		t.Errorf("If-None-Match answered %d %q", w.Code, w.Body.String())
+	}
+
+	// a directory is only served as zip
+	for _, target := range []string{"/?path=missing.txt", "/?path=a"} {
+		if w := getAdmin(ActionGet, target, nil); w.Code != 404 {
+			t.Errorf("get %s answered %d", target, w.Code)
+		}
+	}
+
+	w = getAdmin(ActionGet, "/?path=a&zip=true", nil)
+	if w.Code != 200 || w.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("zip answered %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"a/", "a/b.txt"}) {
+		t.Errorf("zip holds %q", names)
+	}
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"simple-file-server/global"
+	"simple-file-server/lib/defs"
+	"simple-file-server/lib/files"
+	"strings"
+)
+
+// matchSite finds the site of a request, a matching host beats a prefix only site and longer prefixes beat shorter ones.
+// sitePath is the url path inside the site, starting with "/".
+func matchSite(c *gin.Context) (defs.SiteConfig, string, bool) {
+	host := requestHost(c)
+	urlPath := "/" + relPath(c.Request.URL.Path)
+	if strings.HasSuffix(c.Request.URL.Path, "/") && urlPath != "/" {
+		urlPath += "/"
+	}
+	var best defs.SiteConfig
+	bestPath := ""
+	bestScore := -1
+	for _, site := range global.Config().Sites {
+		score := 0
+		if site.Host != "" {
+			if !strings.EqualFold(site.Host, host) {
+				continue
+			}
+			score += 1000
+		}
+		sitePath := urlPath
+		if prefix := relPath(site.Prefix); prefix != "" {
+			if urlPath != "/"+prefix && !strings.HasPrefix(urlPath, "/"+prefix+"/") {
+				continue
+			}
+			sitePath = strings.TrimPrefix(urlPath, "/"+prefix)
+			score += pathDepth(prefix)
+		}
+		if score > bestScore {
+			best, bestPath, bestScore = site, sitePath, score
+		}
+	}
+	return best, bestPath, bestScore >= 0
+}
+
+// requestHost is the Host header without the port
+func requestHost(c *gin.Context) string {
+	host := c.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host
+}
+
+func siteRoot(site defs.SiteConfig) string {
+	if site.Root != "" {
+		return relPath(site.Root)
+	}
+	return relPath(site.Prefix)
+}
+
+func siteIndexDocument(site defs.SiteConfig) string {
+	if site.IndexDocument != "" {
+		return site.IndexDocument
+	}
+	return IndexDocument
+}
+
+// serveSite serves sitePath of a static website: redirects first, then the file, the index document of a directory,
+// the SPA fallback or the error document
+func serveSite(c *gin.Context, site defs.SiteConfig, sitePath string) {
+	if sitePath == "" {
+		// "/prefix" without the slash, relative links in the index document need it
+		c.Redirect(http.StatusMovedPermanently, "/"+relPath(c.Request.URL.Path)+"/")
+		return
+	}
+	if target, status, ok := siteRedirect(site, sitePath); ok {
+		c.Redirect(status, target)
+		return
+	}
+	root := siteRoot(site)
+	rel := relPath(root + "/" + sitePath)
+	if path.Base(rel) == AccessFileName {
+		c.AbortWithStatus(404)
+		return
+	}
+	if !checkAccess(c, rel) {
+		return
+	}
+	throttleDownload(c)
+	fullPath := dataPath(rel)
+	info, err := os.Stat(fullPath)
+	if err == nil && info.IsDir() {
+		if !strings.HasSuffix(sitePath, "/") {
+			c.Redirect(http.StatusMovedPermanently, "/"+relPath(c.Request.URL.Path)+"/")
+			return
+		}
+		rel = relPath(rel + "/" + siteIndexDocument(site))
+		fullPath = dataPath(rel)
+		info, err = os.Stat(fullPath)
+	}
+	if err == nil && !info.IsDir() {
+		setSiteCacheControl(c, site, strings.TrimPrefix(rel, root+"/"))
+		serveFile(c, rel, fullPath)
+		return
+	}
+	if site.Spa {
+		index := relPath(root + "/" + siteIndexDocument(site))
+		if files.FileExists(dataPath(index)) {
+			setSiteCacheControl(c, site, siteIndexDocument(site))
+			serveFile(c, index, dataPath(index))
+			return
+		}
+	}
+	if site.ErrorDocument != "" {
+		errorDocument := relPath(root + "/" + site.ErrorDocument)
+		if data, err := os.ReadFile(dataPath(errorDocument)); err == nil {
+			contentType := files.ContentType(errorDocument)
+			if contentType == "" {
+				contentType = "text/html; charset=utf-8"
+			}
+			c.Header("Server", "Simple-File-Server")
+			c.Data(http.StatusNotFound, contentType, data)
+			c.Abort()
+			return
+		}
+	}
+	c.AbortWithStatus(404)
+}
+
+// siteRedirect returns the target of the first redirect rule matching sitePath
+func siteRedirect(site defs.SiteConfig, sitePath string) (string, int, bool) {
+	for _, rule := range site.Redirects {
+		target := ""
+		if strings.HasSuffix(rule.From, "*") {
+			prefix := strings.TrimSuffix(rule.From, "*")
+			if !strings.HasPrefix(sitePath, prefix) {
+				continue
+			}
+			target = strings.Replace(rule.To, "*", strings.TrimPrefix(sitePath, prefix), 1)
+		} else if sitePath == rule.From {
+			target = rule.To
+		} else {
+			continue
+		}
+		// targets inside the site are relative to its prefix
+		if strings.HasPrefix(target, "/") && relPath(site.Prefix) != "" {
+			target = "/" + relPath(site.Prefix) + target
+		}
+		status := rule.Status
+		if status == 0 {
+			status = http.StatusMovedPermanently
+		}
+		return target, status, true
+	}
+	return "", 0, false
+}
+
+func setSiteCacheControl(c *gin.Context, site defs.SiteConfig, sitePath string) {
+	for _, rule := range site.CacheControl {
+		ok, _ := path.Match(rule.Pattern, sitePath)
+		if !ok && !strings.Contains(rule.Pattern, "/") {
+			ok, _ = path.Match(rule.Pattern, path.Base(sitePath))
+		}
+		if ok {
+			c.Header("Cache-Control", rule.Value)
+			return
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http/httptest"
+	"simple-file-server/lib/defs"
+	"testing"
+)
+
+func TestServeSite(t *testing.T) {
+	root := t.TempDir()
+	useConfig(t, defs.Config{DataDir: root, TempDir: t.TempDir(), Sites: []defs.SiteConfig{
+		{Host: "www.example.com", Root: "www", Spa: true, CacheControl: []defs.CacheRule{{Pattern: "*.css", Value: "max-age=60"}}},
+		{Prefix: "docs", ErrorDocument: "404.html", Redirects: []defs.RedirectRule{{From: "/old/*", To: "/new/*"}}},
+		{Prefix: "docs/api", Root: "api-docs"},
+	}})
+	writeFiles(t, root, "www/index.html", "www/css/style.css", "docs/index.html", "docs/404.html", "docs/guide/index.html", "api-docs/index.html", "other.txt")
+
+	tests := []struct {
+		host     string
+		path     string
+		status   int
+		body     string
+		location string
+	}{
+		{"www.example.com:8080", "/css/style.css", 200, "www/css/style.css", ""},
+		{"www.example.com", "/", 200, "www/index.html", ""},
+		// a missing file of a single page app is answered by its index document
+		{"www.example.com", "/app/route", 200, "www/index.html", ""},
+		{"other.example.com", "/other.txt", 200, "other.txt", ""},
+		{"", "/docs", 301, "", "/docs/"},
+		{"", "/docs/", 200, "docs/index.html", ""},
+		{"", "/docs/guide", 301, "", "/docs/guide/"},
+		{"", "/docs/guide/", 200, "docs/guide/index.html", ""},
+		{"", "/docs/missing.html", 404, "docs/404.html", ""},
+		{"", "/docs/old/a.html", 301, "", "/docs/new/a.html"},
+		// the longest prefix wins
+		{"", "/docs/api/", 200, "api-docs/index.html", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.host != "" {
+			req.Host = tt.host
+		}
+		w := getPublic(req)
+		if w.Code != tt.status || tt.body != "" && w.Body.String() != tt.body || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s%s = %d %q to %q, want %d %q to %q", tt.host, tt.path, w.Code, w.Body.String(), w.Header().Get("Location"), tt.status, tt.body, tt.location)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/css/style.css", nil)
+	req.Host = "www.example.com"
+	if cacheControl := getPublic(req).Header().Get("Cache-Control"); cacheControl != "max-age=60" {
+		t.Errorf("Cache-Control of style.css = %q", cacheControl)
+	}
+}
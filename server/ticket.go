@@ -0,0 +1,162 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"simple-file-server/global"
+	"simple-file-server/lib/common"
+	"simple-file-server/lib/defs"
+	"simple-file-server/lib/response"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UploadTicket lets a browser upload once below Prefix without the admin token.
+// Only the hash of the ticket is kept, in memory until it is used or expires.
+type UploadTicket struct {
+	Hash string `json:"hash"`
+	// TokenName is the token the ticket was minted with, its upload limits apply
+	TokenName string `json:"tokenName"`
+	Prefix    string `json:"prefix"`
+	MaxSize   int64  `json:"maxSize"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+var tickets = struct {
+	sync.Mutex
+	m map[string]*UploadTicket
+}{m: map[string]*UploadTicket{}}
+
+func hashTicket(ticket string) string {
+	sum := sha256.Sum256([]byte(ticket))
+	return hex.EncodeToString(sum[:])
+}
+
+// allows tells whether path is below the prefix of the ticket
+func (t *UploadTicket) allows(path string) bool {
+	rel := relPath(path)
+	return rel == t.Prefix || strings.HasPrefix(rel, t.Prefix+"/")
+}
+
+type ticketRequest struct {
+	Prefix  string `json:"prefix"`
+	MaxSize int64  `json:"maxSize"`
+	Ttl     int64  `json:"ttl"`
+}
+
+func ActionUploadTicket(c *gin.Context) {
+	if !checkAdminToken(c) {
+		return
+	}
+	var req ticketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	prefix := relPath(scopePath(c, req.Prefix))
+	if prefix == "" {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "prefix is required")
+		return
+	}
+	if req.MaxSize < 0 {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "Invalid maxSize")
+		return
+	}
+	if req.Ttl == 0 {
+		req.Ttl = 300
+	}
+	if req.Ttl < 0 || req.Ttl > global.Config().TicketMaxTtl {
+		response.GenerateErrorCode(c, defs.CodeInvalidRequest, "ttl must be between 1 and ticketMaxTtl")
+		return
+	}
+	ticket := common.SecureToken(16)
+	t := &UploadTicket{
+		Hash:      hashTicket(ticket),
+		TokenName: c.GetString("tokenName"),
+		Prefix:    prefix,
+		MaxSize:   req.MaxSize,
+		ExpiresAt: time.Now().Unix() + req.Ttl,
+	}
+	tickets.Lock()
+	now := time.Now().Unix()
+	for hash, old := range tickets.m {
+		if old.ExpiresAt < now {
+			delete(tickets.m, hash)
+		}
+	}
+	tickets.m[t.Hash] = t
+	tickets.Unlock()
+	response.GenerateSuccessData(c, gin.H{
+		"ticket":    ticket,
+		"prefix":    unscopePath(c, t.Prefix),
+		"maxSize":   t.MaxSize,
+		"expiresAt": t.ExpiresAt,
+	})
+}
+
+// checkUploadAuth accepts the admin token or an upload-ticket header,
+// the ticket itself is checked later by claimTicket or checkSessionTicket
+func checkUploadAuth(c *gin.Context) bool {
+	if isTicketRequest(c) {
+		return true
+	}
+	return checkAdminToken(c)
+}
+
+func isTicketRequest(c *gin.Context) bool {
+	return c.GetHeader("upload-ticket") != "" && c.GetHeader("admin-api-token") == ""
+}
+
+// claimTicket uses up the ticket of a ticket request, a failed upload needs a new ticket
+func claimTicket(c *gin.Context) bool {
+	if !isTicketRequest(c) {
+		return true
+	}
+	hash := hashTicket(c.GetHeader("upload-ticket"))
+	tickets.Lock()
+	t, ok := tickets.m[hash]
+	delete(tickets.m, hash)
+	tickets.Unlock()
+	if !ok || t.ExpiresAt < time.Now().Unix() {
+		response.GenerateErrorCode(c, defs.CodeInvalidTicket, "Invalid or expired ticket")
+		return false
+	}
+	setTicket(c, t)
+	return true
+}
+
+// checkSessionTicket lets a ticket request continue the multipart upload started with the same ticket
+func checkSessionTicket(c *gin.Context, meta MultipartMeta) bool {
+	if !isTicketRequest(c) {
+		return true
+	}
+	if meta.Ticket == nil || meta.Ticket.Hash != hashTicket(c.GetHeader("upload-ticket")) {
+		response.GenerateErrorCode(c, defs.CodeInvalidTicket, "Invalid ticket")
+		return false
+	}
+	setTicket(c, meta.Ticket)
+	return true
+}
+
+func setTicket(c *gin.Context, t *UploadTicket) {
+	c.Set("ticket", t)
+	c.Set("tokenName", t.TokenName)
+}
+
+func requestTicket(c *gin.Context) *UploadTicket {
+	if t, ok := c.Get("ticket"); ok {
+		return t.(*UploadTicket)
+	}
+	return nil
+}
+
+// checkTicketPath refuses paths outside the prefix of the ticket, admin requests pass
+func checkTicketPath(c *gin.Context, path string) bool {
+	if t := requestTicket(c); t != nil && !t.allows(path) {
+		response.GenerateErrorCode(c, defs.CodePathNotAllowed, "Path not allowed by ticket")
+		return false
+	}
+	return true
+}
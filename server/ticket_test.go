@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"simple-file-server/global"
+	"testing"
+)
+
+func TestUploadTicketAllows(t *testing.T) {
+	ticket := &UploadTicket{Prefix: "uploads/user1"}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"uploads/user1", true},
+		{"uploads/user1/a.txt", true},
+		{"/uploads/user1/a/b.txt", true},
+		{"uploads/user1/./a.txt", true},
+		{"uploads/user10/a.txt", false},
+		{"uploads/user1.txt", false},
+		{"uploads", false},
+		{"", false},
+		{"uploads/user1/../user2/a.txt", false},
+		{"uploads/user1/../../uploads/user1/a.txt", true},
+		{"../uploads/user1/a.txt", true},
+	}
+	for _, tt := range tests {
+		if got := ticket.allows(tt.path); got != tt.want {
+			t.Errorf("allows(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestTicketUpload(t *testing.T) {
+	root := useDataDir(t)
+	config := *global.Config()
+	config.TicketMaxTtl = 3600
+	useConfig(t, config)
+	mint := func(body gin.H) string {
+		code, data := callAdmin(t, ActionUploadTicket, body)
+		if code != 0 {
+			return ""
+		}
+		var res struct {
+			Ticket string `json:"ticket"`
+		}
+		json.Unmarshal(data, &res)
+		return res.Ticket
+	}
+	upload := func(ticket string, filePath string, content string) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = multipartRequest(t, map[string]string{"filePath": filePath}, "a.txt", content)
+		c.Request.Header.Set("upload-ticket", ticket)
+		ActionUpload(c)
+		var res struct {
+			Code int `json:"code"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return res.Code
+	}
+
+	ticket := mint(gin.H{"prefix": "uploads/user1", "maxSize": 5, "ttl": 60})
+	if ticket == "" {
+		t.Fatal("minting a ticket failed")
+	}
+	if code := upload(ticket, "uploads/user1/a.txt", "123"); code != 0 {
+		t.Fatalf("upload with the ticket = %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(root, "uploads", "user1", "a.txt")); err != nil {
+		t.Error("the upload was not stored")
+	}
+	// a ticket is used up by the first upload
+	if code := upload(ticket, "uploads/user1/b.txt", "123"); code == 0 {
+		t.Error("a used ticket was accepted again")
+	}
+	if code := upload(mint(gin.H{"prefix": "uploads/user1", "maxSize": 5}), "uploads/user2/a.txt", "123"); code == 0 {
+		t.Error("a ticket uploaded outside its prefix")
+	}
+	if code := upload(mint(gin.H{"prefix": "uploads/user1", "maxSize": 5}), "uploads/user1/c.txt", "123456"); code == 0 {
+		t.Error("a ticket uploaded more than its maxSize")
+	}
+	if code := upload("unknown", "uploads/user1/d.txt", "1"); code == 0 {
+		t.Error("an unknown ticket was accepted")
+	}
+
+	for _, body := range []gin.H{{"prefix": ""}, {"prefix": "a", "ttl": 7200}, {"prefix": "a", "maxSize": -1}} {
+		if mint(body) != "" {
+			t.Errorf("minting a ticket with %v succeeded", body)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"simple-file-server/global"
+	"simple-file-server/lib/defs"
+	"simple-file-server/lib/response"
+	"sync"
+	"time"
+)
+
+// certReloader serves the certificate from disk and reloads it when the files change
+type certReloader struct {
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	modTime  time.Time
+	lock     sync.RWMutex
+}
+
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	go r.watch(10 * time.Second)
+	return r, nil
+}
+
+func (r *certReloader) filesModTime() time.Time {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+func (r *certReloader) load() error {
+	modTime := r.filesModTime()
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.lock.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.lock.Unlock()
+	return nil
+}
+
+func (r *certReloader) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		r.lock.RLock()
+		modTime := r.modTime
+		r.lock.RUnlock()
+		if !r.filesModTime().After(modTime) {
+			continue
+		}
+		// the key and the certificate may be replaced one after the other, keep the old pair until both match
+		if err := r.load(); err != nil {
+			log.Warn("Reload certificate failed: ", err)
+			continue
+		}
+		log.Info("Certificate reloaded")
+	}
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.cert, nil
+}
+
+// newTlsConfig returns nil when TLS is not configured
+func newTlsConfig() (*tls.Config, error) {
+	config := global.Config().Tls
+	if config.CertFile == "" && config.KeyFile == "" {
+		return nil, nil
+	}
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.New("tls.certFile and tls.keyFile must both be set")
+	}
+	reloader, err := newCertReloader(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if config.ClientCaFile != "" {
+		data, err := os.ReadFile(config.ClientCaFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("no certificate found in tls.clientCaFile")
+		}
+		tlsConfig.ClientCAs = pool
+		// public routes stay reachable without a certificate, _admin routes check it
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// requireClientCert rejects admin requests without a verified client certificate when mutual TLS is configured
+func requireClientCert(c *gin.Context) {
+	if global.Config().Tls.ClientCaFile == "" || isUnixRequest(c) {
+		return
+	}
+	if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+		response.GenerateErrorCode(c, defs.CodeForbidden, "Client certificate required")
+		return
+	}
+	c.Next()
+}
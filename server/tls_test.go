@@ -0,0 +1,148 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/gin-gonic/gin"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"simple-file-server/lib/defs"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate for name to certFile and its key to keyFile
+func writeCert(t *testing.T, certFile string, keyFile string, name string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+}
+
+func certName(t *testing.T, r *certReloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if _, err := newCertReloader(certFile, keyFile); err == nil {
+		t.Error("newCertReloader without files returned no error")
+	}
+
+	writeCert(t, certFile, keyFile, "one")
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := certName(t, r); name != "one" {
+		t.Errorf("certificate is for %q, want one", name)
+	}
+
+	// a broken pair keeps serving the loaded certificate
+	os.WriteFile(keyFile, []byte("broken"), 0600)
+	if err := r.load(); err == nil {
+		t.Error("load of a broken key returned no error")
+	}
+	if name := certName(t, r); name != "one" {
+		t.Errorf("certificate is for %q after a failed load, want one", name)
+	}
+
+	writeCert(t, certFile, keyFile, "two")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	if !r.filesModTime().After(r.modTime) {
+		t.Error("the replaced files are not newer than the loaded certificate")
+	}
+	if err := r.load(); err != nil {
+		t.Fatal(err)
+	}
+	if name := certName(t, r); name != "two" {
+		t.Errorf("certificate is for %q after the reload, want two", name)
+	}
+}
+
+func TestNewTlsConfig(t *testing.T) {
+	useConfig(t, defs.Config{})
+	if config, err := newTlsConfig(); config != nil || err != nil {
+		t.Errorf("newTlsConfig without tls = %v, %v, want nil", config, err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCert(t, certFile, keyFile, "server")
+	useConfig(t, defs.Config{Tls: defs.TlsConfig{CertFile: certFile}})
+	if _, err := newTlsConfig(); err == nil {
+		t.Error("newTlsConfig without keyFile returned no error")
+	}
+
+	// the certificate itself is a valid client ca
+	useConfig(t, defs.Config{Tls: defs.TlsConfig{CertFile: certFile, KeyFile: keyFile, ClientCaFile: certFile}})
+	config, err := newTlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.ClientCAs == nil || config.ClientAuth != tls.VerifyClientCertIfGiven || config.MinVersion != tls.VersionTLS12 {
+		t.Errorf("newTlsConfig = %+v", config)
+	}
+
+	useConfig(t, defs.Config{Tls: defs.TlsConfig{CertFile: certFile, KeyFile: keyFile, ClientCaFile: keyFile}})
+	if _, err := newTlsConfig(); err == nil {
+		t.Error("newTlsConfig with a key as clientCaFile returned no error")
+	}
+}
+
+func TestRequireClientCert(t *testing.T) {
+	check := func() int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/_admin/list", nil)
+		requireClientCert(c)
+		if !c.IsAborted() {
+			return 0
+		}
+		c.Writer.WriteHeaderNow()
+		return w.Code
+	}
+	useConfig(t, defs.Config{})
+	if status := check(); status != 0 {
+		t.Errorf("request without mutual tls was rejected with %d", status)
+	}
+	useConfig(t, defs.Config{Tls: defs.TlsConfig{ClientCaFile: "ca.pem"}})
+	if status := check(); status != 403 {
+		t.Errorf("request without a client certificate got %d, want 403", status)
+	}
+}